@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/zgsm-ai/codebase-indexer/internal/handler"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// headerFlags 可重复的 -H 参数，格式为 Name:Value
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	return ""
+}
+
+func (h headerFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("invalid header %q, expected Name:Value", value)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(val))
+	return nil
+}
+
+// runExplain 输出请求会命中的路由、策略、规则、转发地址与请求头，不发送任何请求
+// 用法: explain -f etc/conf.yaml GET /codebase-indexer/api/v1/search/semantic -H X-Costrict-Version:1.0
+func runExplain(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: explain [-f config] [-H Name:Value]... [-d body] [-json] METHOD PATH")
+		fs.PrintDefaults()
+	}
+	file := fs.String("f", "etc/conf.yaml", "the config file")
+	headers := headerFlags{}
+	fs.Var(headers, "H", "request header as Name:Value, repeatable")
+	body := fs.String("d", "", "request body")
+	clientIP := fs.String("client-ip", "127.0.0.1", "address of the connecting client")
+	asJSON := fs.Bool("json", false, "print the explanation as JSON")
+
+	// 参数与选项可以交替出现，如 GET /path -H Name:Value
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	c, err := loadConfig(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *file, err)
+		return 1
+	}
+	if c.ProxyConfig == nil {
+		fmt.Fprintf(os.Stderr, "%s: proxy_config not configured\n", *file)
+		return 1
+	}
+
+	var bodyReader io.Reader
+	if *body != "" {
+		bodyReader = strings.NewReader(*body)
+	}
+	host := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	r, err := http.NewRequest(strings.ToUpper(positional[0]), "http://"+host+positional[1], bodyReader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid request: %v\n", err)
+		return 2
+	}
+	r.Header = http.Header(headers)
+	r.RemoteAddr = net.JoinHostPort(*clientIP, "0")
+
+	explainer := handler.NewExplainer(c.ProxyConfig)
+	defer explainer.Close()
+	e, err := explainer.Explain(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "explain failed: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(e)
+		return 0
+	}
+	printExplanation(e)
+	return 0
+}
+
+// printExplanation 按字段输出转发决策，未命中的字段不输出
+func printExplanation(e *handler.Explanation) {
+	line := func(name, value string) {
+		if value != "" {
+			fmt.Printf("%-10s %s\n", name+":", value)
+		}
+	}
+	line("Request", e.Method+" "+e.Path)
+	line("Route", e.Route)
+	if e.ClientID != "" {
+		line("Client ID", fmt.Sprintf("%s (%s)", e.ClientID, e.ClientIDSource))
+	}
+	line("Shadow", e.Shadow)
+	line("Split", e.Split)
+	line("Rule", e.Rule)
+	line("Strategy", e.Strategy)
+	line("Fallback", strings.Join(e.Fallback, " -> "))
+	line("URL", e.URL)
+
+	if len(e.Headers) > 0 {
+		fmt.Println("Headers:")
+		names := make([]string, 0, len(e.Headers))
+		for name := range e.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range e.Headers[name] {
+				fmt.Printf("  %s: %s\n", name, value)
+			}
+		}
+	}
+	if len(e.Notes) > 0 {
+		fmt.Println("Notes:")
+		for _, note := range e.Notes {
+			fmt.Printf("  - %s\n", note)
+		}
+	}
+}
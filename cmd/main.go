@@ -5,16 +5,35 @@ import (
 	"flag"
 	"github.com/zeromicro/go-zero/core/conf"
 	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/proc"
 	"github.com/zeromicro/go-zero/rest"
 	"github.com/zgsm-ai/codebase-indexer/internal/config"
 	"github.com/zgsm-ai/codebase-indexer/internal/handler"
+	"github.com/zgsm-ai/codebase-indexer/internal/middleware"
 	"github.com/zgsm-ai/codebase-indexer/internal/svc"
 	"net/http"
+	"os"
+	"time"
 )
 
 var configFile = flag.String("f", "etc/conf.yaml", "the config file")
 
+// forceQuitMargin 排空超时后留给关闭转发连接的时间，超过后进程被强制退出
+const forceQuitMargin = 5 * time.Second
+
+// commands 子命令：validate 校验配置，explain 解析请求的转发决策，replay 回放录制的流量，不带子命令时启动服务
+var commands = map[string]func(args []string) int{
+	"validate": runValidate,
+	"explain":  runExplain,
+	"replay":   runReplay,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
 	flag.Parse()
 
 	var c config.Config
@@ -35,6 +54,18 @@ func main() {
 		panic(err)
 	}
 
+	// 优雅停机：收到 SIGTERM 后健康检查先返回未就绪，readiness_delay 后停止接收新请求，
+	// 进行中的请求最多等待 drain_timeout，之后取消剩余请求并关闭转发连接
+	shutdown := svcCtx.Drainer.Config()
+	proc.Setup(proc.ShutdownConf{
+		WrapUpTime: shutdown.ReadinessDelay,
+		WaitTime:   shutdown.ReadinessDelay + shutdown.DrainTimeout + forceQuitMargin,
+	})
+	proc.AddWrapUpListener(svcCtx.Drainer.BeginShutdown)
+	proc.AddShutdownListener(svcCtx.Drainer.Drain)
+
+	server.Use(svcCtx.Drainer.Handle)
+	server.Use(middleware.NewRequestIDMiddleware().Handle)
 	handler.RegisterHandlers(server, svcCtx)
 
 	logx.Infof("==>Started server at %s:%d", c.Host, c.Port)
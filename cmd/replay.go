@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/zgsm-ai/codebase-indexer/internal/logic"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// runReplay 将录制文件中的请求依次发送到目标地址并与录制的响应比对，存在不一致或失败时返回 1
+// 用法: replay -f logs/record.jsonl -target http://localhost:8080 -H "Authorization: Bearer xxx"
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	file := fs.String("f", "", "the recording file (.jsonl or .har)")
+	target := fs.String("target", "", "base URL the recorded requests are sent to")
+	headers := headerFlags{}
+	fs.Var(headers, "H", "header as Name:Value overriding recorded headers, repeatable")
+	ignore := fs.String("ignore", "requestId,timestamp", "comma separated JSON fields ignored when comparing responses")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of each replayed request")
+	asJSON := fs.Bool("json", false, "print results as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" || *target == "" {
+		fmt.Fprintln(os.Stderr, "Usage: replay -f recording -target URL [-H Name:Value]... [-ignore fields] [-json]")
+		return 2
+	}
+
+	exchanges, err := logic.LoadRecording(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *file, err)
+		return 1
+	}
+
+	var ignoreFields []string
+	for _, field := range strings.Split(*ignore, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			ignoreFields = append(ignoreFields, field)
+		}
+	}
+	replayer := logic.NewReplayLogic(*target, http.Header(headers), ignoreFields, *timeout)
+	defer replayer.Close()
+
+	counts := make(map[string]int)
+	encoder := json.NewEncoder(os.Stdout)
+	for _, exchange := range exchanges {
+		result := replayer.Replay(context.Background(), exchange)
+		counts[result.Result]++
+		if *asJSON {
+			encoder.Encode(result)
+			continue
+		}
+		printReplayResult(result)
+	}
+
+	failed := len(exchanges) - counts[logic.ReplayResultMatch] - counts[logic.ReplayResultSkipped]
+	if !*asJSON {
+		fmt.Printf("%s: %d requests, %d match, %d failed, %d skipped\n", *file, len(exchanges), counts[logic.ReplayResultMatch], failed, counts[logic.ReplayResultSkipped])
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printReplayResult 输出单条回放结果
+func printReplayResult(result logic.ReplayResult) {
+	line := fmt.Sprintf("%-15s %s %s", result.Result, result.Method, result.Path)
+	switch result.Result {
+	case logic.ReplayResultStatusMismatch:
+		line += fmt.Sprintf(" (recorded %d, got %d)", result.RecordedStatus, result.Status)
+	case logic.ReplayResultBodyMismatch:
+		line += fmt.Sprintf(" (first difference at %s)", result.DiffPath)
+	case logic.ReplayResultError, logic.ReplayResultSkipped:
+		line += fmt.Sprintf(" (%s)", result.Error)
+	}
+	if result.RequestID != "" {
+		line += " requestId=" + result.RequestID
+	}
+	fmt.Println(line)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/logic"
+	"os"
+)
+
+// runValidate 加载配置并执行 Config.Validate 与 lint 检查，有 error 时返回 1，-strict 时 warning 也返回 1
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	file := fs.String("f", "etc/conf.yaml", "the config file")
+	strict := fs.Bool("strict", false, "treat warnings as errors")
+	asJSON := fs.Bool("json", false, "print issues as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	c, err := loadConfig(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *file, err)
+		return 1
+	}
+
+	issues := logic.NewLintLogic(c.ProxyConfig).Lint()
+	var errors, warnings int
+	for _, issue := range issues {
+		if issue.Severity == logic.LintError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+
+	if *asJSON {
+		if issues == nil {
+			issues = []logic.LintIssue{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(map[string]interface{}{"file": *file, "issues": issues})
+	} else {
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		fmt.Printf("%s: %d errors, %d warnings\n", *file, errors, warnings)
+	}
+
+	if errors > 0 || (*strict && warnings > 0) {
+		return 1
+	}
+	return 0
+}
+
+// loadConfig 加载配置文件，加载时执行 Config.Validate 并填充默认值，子命令不输出日志
+func loadConfig(file string) (config.Config, error) {
+	logx.Disable()
+	var c config.Config
+	err := conf.Load(file, &c, conf.UseEnv())
+	return c, err
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleConfig = "../etc/conf.yaml"
+
+func TestValidateSampleConfig(t *testing.T) {
+	assert.Equal(t, 0, runValidate([]string{"-f", sampleConfig, "-strict"}))
+}
+
+func TestLoadSampleConfig(t *testing.T) {
+	c, err := loadConfig(sampleConfig)
+	require.NoError(t, err)
+	require.NotNil(t, c.ProxyConfig)
+
+	pm := c.ProxyConfig.PortManager
+	assert.Equal(t, "http://127.0.0.1:31226", pm.URL)
+	assert.Equal(t, 10*time.Second, pm.Timeout)
+	assert.Equal(t, 5*time.Minute, pm.CacheExp)
+	assert.Equal(t, 10, pm.MaxIdleConns)
+	assert.Equal(t, 5, pm.MaxIdleConnsPerHost)
+	assert.Equal(t, 30*time.Second, pm.IdleConnTimeout)
+}
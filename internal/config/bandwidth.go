@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 默认带宽限制配置
+const (
+	DefaultBandwidthBurst     = 64 * 1024
+	DefaultUploadRateHeader   = "X-Costrict-Upload-Rate"
+	DefaultDownloadRateHeader = "X-Costrict-Download-Rate"
+)
+
+// BandwidthConfig 带宽限制，速率单位为字节/秒，路由配置覆盖全局配置，0 表示沿用上一层配置，全局为 0 时不限速
+// 上传（转发到上游的请求体）与下载（写回客户端的响应体）分别按令牌桶限速，同一路由内同一 clientId 的并发请求共享令牌桶，没有 clientId 的请求共享路由级令牌桶
+// 客户端可以通过请求头覆盖所属 clientId 的速率，但不超过 max_upload / max_download，同一 clientId 的并发请求合计不超过该速率；未配置上限时忽略请求头
+type BandwidthConfig struct {
+	Upload         int64  `json:"upload,optional" yaml:"upload"`                   // 上传速率上限
+	Download       int64  `json:"download,optional" yaml:"download"`               // 下载速率上限
+	Burst          int64  `json:"burst,optional" yaml:"burst"`                     // 令牌桶容量，即允许突发的字节数，默认 64KB
+	MaxUpload      int64  `json:"max_upload,optional" yaml:"max_upload"`           // 客户端通过请求头可覆盖的上传速率上限
+	MaxDownload    int64  `json:"max_download,optional" yaml:"max_download"`       // 客户端通过请求头可覆盖的下载速率上限
+	UploadHeader   string `json:"upload_header,optional" yaml:"upload_header"`     // 客户端请求上传速率的请求头，仅全局配置生效
+	DownloadHeader string `json:"download_header,optional" yaml:"download_header"` // 客户端请求下载速率的请求头，仅全局配置生效
+}
+
+// validate 验证带宽限制配置
+func (c *BandwidthConfig) validate() error {
+	if c.Upload < 0 || c.Download < 0 || c.Burst < 0 || c.MaxUpload < 0 || c.MaxDownload < 0 {
+		return errors.New("rates cannot be negative")
+	}
+	return nil
+}
+
+// setDefaults 填充全局带宽限制默认值
+func (c *BandwidthConfig) setDefaults() {
+	if c.Burst == 0 {
+		c.Burst = DefaultBandwidthBurst
+	}
+	if c.UploadHeader == "" {
+		c.UploadHeader = DefaultUploadRateHeader
+	}
+	if c.DownloadHeader == "" {
+		c.DownloadHeader = DefaultDownloadRateHeader
+	}
+	c.UploadHeader = http.CanonicalHeaderKey(c.UploadHeader)
+	c.DownloadHeader = http.CanonicalHeaderKey(c.DownloadHeader)
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 默认请求体限制，与 go-zero MaxBytes 默认配置一致
+const (
+	DefaultMaxBodySize             = 100 * 1024 * 1024
+	DefaultMaxDecompressedBodySize = 100 * 1024 * 1024
+)
+
+// BodyConfig 请求体与响应体限制，路由配置覆盖全局配置，0 表示沿用上一层配置
+// 注意：请求体还受 go-zero MaxBytes 限制，超过 MaxBytes 的 max_size 不会生效
+type BodyConfig struct {
+	MaxSize             int64            `json:"max_size,optional" yaml:"max_size"`                           // 请求体最大字节数
+	Methods             map[string]int64 `json:"methods,optional" yaml:"methods"`                             // 按请求方法覆盖 max_size，如 POST
+	MaxDecompressedSize int64            `json:"max_decompressed_size,optional" yaml:"max_decompressed_size"` // 压缩请求体（gzip、deflate、br、zstd）解压后的最大字节数
+	ContentTypes        []string         `json:"content_types,optional" yaml:"content_types"`                 // 允许的请求 Content-Type，支持 application/*，为空时不限制
+	MaxResponseSize     int64            `json:"max_response_size,optional" yaml:"max_response_size"`         // 响应体最大字节数，为 0 时不限制
+}
+
+// validate 验证请求体限制配置
+func (c *BodyConfig) validate() error {
+	if c.MaxSize < 0 || c.MaxDecompressedSize < 0 || c.MaxResponseSize < 0 {
+		return fmt.Errorf("sizes cannot be negative")
+	}
+	for method, size := range c.Methods {
+		if method != strings.ToUpper(method) {
+			return fmt.Errorf("methods.%s: method must be upper case", method)
+		}
+		if size < 0 {
+			return fmt.Errorf("methods.%s: size cannot be negative", method)
+		}
+	}
+	for i, contentType := range c.ContentTypes {
+		parts := strings.Split(contentType, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || parts[0] == "*" {
+			return fmt.Errorf("content_types[%d] invalid media type: %s", i, contentType)
+		}
+		c.ContentTypes[i] = strings.ToLower(contentType)
+	}
+	return nil
+}
+
+// setDefaults 填充全局请求体限制默认值
+func (c *BodyConfig) setDefaults() {
+	if c.MaxSize == 0 {
+		c.MaxSize = DefaultMaxBodySize
+	}
+	if c.MaxDecompressedSize == 0 {
+		c.MaxDecompressedSize = DefaultMaxDecompressedBodySize
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// clientId 提取来源
+const (
+	ClientIDFromQuery  = "query"  // 查询参数
+	ClientIDFromHeader = "header" // 请求头
+	ClientIDFromCookie = "cookie" // Cookie
+	ClientIDFromPath   = "path"   // 路径参数
+	ClientIDFromBody   = "body"   // JSON 请求体，按 JSON Pointer 取值
+	ClientIDFromForm   = "form"   // multipart 或 urlencoded 表单字段
+	ClientIDFromJWT    = "jwt"    // JWT 中的 claim
+)
+
+// DefaultClientIDExtractors 未配置时的默认提取顺序，与原有行为兼容：JSON 请求体、查询参数、请求头
+var DefaultClientIDExtractors = []ClientIDExtractorConfig{
+	{Source: ClientIDFromBody, Pointer: "/clientId"},
+	{Source: ClientIDFromQuery, Name: "clientId"},
+	{Source: ClientIDFromHeader, Name: "clientId"},
+}
+
+// ClientIDExtractorConfig clientId 提取规则，按顺序尝试，取第一个非空值
+type ClientIDExtractorConfig struct {
+	Source  string `json:"source" yaml:"source"`            // query、header、cookie、path、body、form、jwt
+	Name    string `json:"name,optional" yaml:"name"`       // 参数、请求头、Cookie、表单字段或路径参数的名称；jwt 为 claim 名称，支持点号分隔
+	Pointer string `json:"pointer,optional" yaml:"pointer"` // body 的 JSON Pointer，如 /clientId、/client/id
+	Pattern string `json:"pattern,optional" yaml:"pattern"` // path 的路径模式，如 /api/v1/clients/:clientId/*rest
+	Header  string `json:"header,optional" yaml:"header"`   // jwt 所在的请求头，默认 Authorization，支持 Bearer 前缀
+}
+
+// validate 验证 clientId 提取规则
+func (c *ClientIDExtractorConfig) validate() error {
+	switch c.Source {
+	case ClientIDFromQuery, ClientIDFromHeader, ClientIDFromCookie, ClientIDFromForm, ClientIDFromJWT:
+		if c.Name == "" {
+			return fmt.Errorf("name is required for %s extractor", c.Source)
+		}
+	case ClientIDFromPath:
+		if c.Name == "" || c.Pattern == "" {
+			return fmt.Errorf("name and pattern are required for path extractor")
+		}
+		pattern, err := utils.CompilePathPattern(c.Pattern)
+		if err != nil {
+			return err
+		}
+		if pattern.SubexpIndex(c.Name) < 0 {
+			return fmt.Errorf("pattern %s has no parameter %s", c.Pattern, c.Name)
+		}
+	case ClientIDFromBody:
+		if c.Pointer != "" && !strings.HasPrefix(c.Pointer, "/") {
+			return fmt.Errorf("pointer must be empty or start with '/': %s", c.Pointer)
+		}
+	default:
+		return fmt.Errorf("unsupported source: %s", c.Source)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 压缩编码
+const (
+	EncodingGzip    = "gzip"
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+	EncodingDeflate = "deflate"
+)
+
+// DefaultCompressionMinSize 默认压缩的最小响应体大小
+const DefaultCompressionMinSize = 1024
+
+// DefaultCompressionEncodings 默认的响应压缩编码，按优先级排列
+var DefaultCompressionEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+
+// DefaultCompressionContentTypes 默认压缩的响应 Content-Type
+var DefaultCompressionContentTypes = []string{"text/*", "application/json", "application/problem+json", "application/javascript", "application/xml"}
+
+// CompressionConfig 网关侧压缩配置，路由配置覆盖全局配置
+type CompressionConfig struct {
+	Enabled           bool     `json:"enabled,optional" yaml:"enabled"`                       // 按客户端 Accept-Encoding 压缩响应
+	Disabled          bool     `json:"disabled,optional" yaml:"disabled"`                     // 关闭全局开启的响应压缩，仅路由配置生效
+	Encodings         []string `json:"encodings,optional" yaml:"encodings"`                   // 可用的编码，按优先级排列：br、zstd、gzip
+	MinSize           int64    `json:"min_size,optional" yaml:"min_size"`                     // 小于该字节数的响应不压缩，默认 1024
+	ContentTypes      []string `json:"content_types,optional" yaml:"content_types"`           // 压缩的响应 Content-Type，支持 text/*
+	DecompressRequest bool     `json:"decompress_request,optional" yaml:"decompress_request"` // 将压缩的请求体解压后再转发，用于不支持压缩上传的后端
+}
+
+// validate 验证压缩配置
+func (c *CompressionConfig) validate() error {
+	for i, encoding := range c.Encodings {
+		switch encoding {
+		case EncodingGzip, EncodingBrotli, EncodingZstd:
+		default:
+			return fmt.Errorf("encodings[%d] unsupported encoding: %s", i, encoding)
+		}
+	}
+	if c.MinSize < 0 {
+		return fmt.Errorf("min_size cannot be negative")
+	}
+	for i, contentType := range c.ContentTypes {
+		parts := strings.Split(contentType, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || parts[0] == "*" {
+			return fmt.Errorf("content_types[%d] invalid media type: %s", i, contentType)
+		}
+		c.ContentTypes[i] = strings.ToLower(contentType)
+	}
+	return nil
+}
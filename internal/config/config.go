@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/zeromicro/go-zero/rest"
 )
@@ -11,7 +12,8 @@ type Config struct {
 	Auth struct {
 		UserInfoHeader string
 	}
-	ProxyConfig *ProxyConfig `json:"proxy_config" yaml:"proxy_config"`
+	ProxyConfig      *ProxyConfig           `json:"proxy_config" yaml:"proxy_config"`
+	GracefulShutdown GracefulShutdownConfig `json:"graceful_shutdown,optional" yaml:"graceful_shutdown"` // 优雅停机配置
 }
 
 // Validate 实现 Validator 接口
@@ -19,7 +21,13 @@ func (c Config) Validate() error {
 	if len(c.Name) == 0 {
 		return errors.New("name 不能为空")
 	}
+	if err := c.GracefulShutdown.validate(); err != nil {
+		return fmt.Errorf("graceful_shutdown %w", err)
+	}
 	if c.ProxyConfig != nil {
+		if c.ProxyConfig.UserInfoHeader == "" {
+			c.ProxyConfig.UserInfoHeader = c.Auth.UserInfoHeader
+		}
 		if err := c.ProxyConfig.Validate(); err != nil {
 			return err
 		}
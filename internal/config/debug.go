@@ -0,0 +1,65 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 调试模式
+const (
+	DebugModeDryRun = "dry-run" // 不转发，直接返回转发决策
+	DebugModeTrace  = "trace"   // 正常转发，转发决策写入响应头
+)
+
+// 默认调试配置
+const (
+	DefaultDebugHeader         = "X-Costrict-Debug"
+	DefaultDebugQueryParam     = "costrict_debug"
+	DefaultDebugSecretHeader   = "X-Costrict-Debug-Secret"
+	DefaultDebugResponseHeader = "X-Costrict-Debug-Decision"
+)
+
+// DebugConfig 调试模式配置
+// 请求携带调试请求头或查询参数（值为 dry-run 或 trace）且通过身份校验时，返回或附带转发决策
+type DebugConfig struct {
+	Enabled        bool     `json:"enabled,optional" yaml:"enabled"`                 // 是否启用调试模式
+	Header         string   `json:"header,optional" yaml:"header"`                   // 调试请求头
+	QueryParam     string   `json:"query_param,optional" yaml:"query_param"`         // 调试查询参数
+	Secret         string   `json:"secret,optional" yaml:"secret"`                   // 共享密钥，通过 secret_header 携带
+	SecretHeader   string   `json:"secret_header,optional" yaml:"secret_header"`     // 携带共享密钥的请求头
+	AllowedUsers   []string `json:"allowed_users,optional" yaml:"allowed_users"`     // 允许调试的用户，取自用户信息请求头
+	ResponseHeader string   `json:"response_header,optional" yaml:"response_header"` // trace 模式下写入转发决策的响应头
+}
+
+// validate 验证调试配置并填充默认值
+func (c *DebugConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Secret == "" && len(c.AllowedUsers) == 0 {
+		return errors.New("secret or allowed_users is required when enabled is true")
+	}
+	for i, user := range c.AllowedUsers {
+		if user == "" {
+			return fmt.Errorf("allowed_users[%d] cannot be empty", i)
+		}
+	}
+	c.setDefaults()
+	return nil
+}
+
+// setDefaults 填充调试配置默认值
+func (c *DebugConfig) setDefaults() {
+	if c.Header == "" {
+		c.Header = DefaultDebugHeader
+	}
+	if c.QueryParam == "" {
+		c.QueryParam = DefaultDebugQueryParam
+	}
+	if c.SecretHeader == "" {
+		c.SecretHeader = DefaultDebugSecretHeader
+	}
+	if c.ResponseHeader == "" {
+		c.ResponseHeader = DefaultDebugResponseHeader
+	}
+}
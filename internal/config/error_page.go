@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+)
+
+// 错误页类型
+const (
+	ErrorPageStatic   = "static"   // 返回配置的固定响应体
+	ErrorPageRedirect = "redirect" // 将请求转发到另一个目标，如共享的 forward_url
+	ErrorPageTemplate = "template" // 按模板渲染响应体
+)
+
+// ErrorPageWildcard 匹配全部错误码
+const ErrorPageWildcard = "*"
+
+// ErrorPageConfig 路由级错误页，按错误码替换默认的错误响应
+type ErrorPageConfig struct {
+	Codes       []string `json:"codes" yaml:"codes"`                        // 适用的错误码，* 匹配全部
+	Type        string   `json:"type" yaml:"type"`                          // static、redirect、template
+	Status      int      `json:"status,optional" yaml:"status"`             // 响应状态码，默认使用错误码对应的状态码
+	ContentType string   `json:"content_type,optional" yaml:"content_type"` // 响应类型，默认 application/json
+	Body        string   `json:"body,optional" yaml:"body"`                 // static 的响应体或 template 的模板
+	URL         string   `json:"url,optional" yaml:"url"`                   // redirect 的目标地址
+}
+
+// validate 验证错误页配置
+func (c *ErrorPageConfig) validate() error {
+	if len(c.Codes) == 0 {
+		return fmt.Errorf("codes is required")
+	}
+	for _, code := range c.Codes {
+		if code != ErrorPageWildcard && !errs.Code(code).Known() {
+			return fmt.Errorf("unknown error code: %s", code)
+		}
+	}
+	if c.Status != 0 && (c.Status < 100 || c.Status > 599) {
+		return fmt.Errorf("invalid status: %d", c.Status)
+	}
+
+	switch c.Type {
+	case ErrorPageStatic, ErrorPageTemplate:
+		if c.Body == "" {
+			return fmt.Errorf("body is required for %s error page", c.Type)
+		}
+	case ErrorPageRedirect:
+		if u, err := url.Parse(c.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("url invalid: %s", c.URL)
+		}
+	default:
+		return fmt.Errorf("invalid type: %s, must be %s, %s or %s", c.Type, ErrorPageStatic, ErrorPageRedirect, ErrorPageTemplate)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import "fmt"
+
+// 错误响应格式
+const (
+	ErrorFormatEnvelope = "envelope" // response.Response 信封
+	ErrorFormatProblem  = "problem"  // RFC 7807 application/problem+json
+)
+
+// ErrorsConfig 错误响应配置
+type ErrorsConfig struct {
+	Format          string `json:"format,optional" yaml:"format"`                       // envelope(默认)、problem
+	ProblemTypeBase string `json:"problem_type_base,optional" yaml:"problem_type_base"` // problem 格式中 type 字段的前缀，为空时使用 about:blank
+}
+
+// validate 验证错误响应配置并填充默认值
+func (c *ErrorsConfig) validate() error {
+	if c.Format == "" {
+		c.Format = ErrorFormatEnvelope
+	}
+	switch c.Format {
+	case ErrorFormatEnvelope, ErrorFormatProblem:
+		return nil
+	default:
+		return fmt.Errorf("invalid format: %s, must be %s or %s", c.Format, ErrorFormatEnvelope, ErrorFormatProblem)
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+)
+
+// 回退链中的转发策略
+const (
+	StrategyDynamic = "dynamic" // 通过端口管理器转发到用户隧道
+	StrategyStatic  = "static"  // 转发到共享的 forward_url
+	StrategyError   = "error"   // 返回错误，只能位于链尾
+)
+
+// DefaultFallbackOn 默认触发回退的错误码：隧道缺失或不可达
+var DefaultFallbackOn = []string{
+	string(errs.CodeTunnelNotFound),
+	string(errs.CodeTunnelManagerUnavailable),
+	string(errs.CodeUpstreamRefused),
+	string(errs.CodeTargetUnreachable),
+}
+
+// FallbackConfig 路由级回退链
+// 请求先使用按 X-Costrict-Version 选出的策略，失败且错误码命中 on 时依次尝试链中其后的策略
+type FallbackConfig struct {
+	Chain []string `json:"chain,optional" yaml:"chain"` // 策略顺序，如 [dynamic, static, error]
+	On    []string `json:"on,optional" yaml:"on"`       // 触发回退的错误码，默认为 DefaultFallbackOn
+}
+
+// validate 验证回退链配置并填充默认值
+func (c *FallbackConfig) validate(hasForwardURL bool) error {
+	if len(c.Chain) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(c.Chain))
+	for i, strategy := range c.Chain {
+		switch strategy {
+		case StrategyDynamic:
+		case StrategyStatic:
+			if !hasForwardURL {
+				return fmt.Errorf("chain[%d]: %s requires forward_url", i, strategy)
+			}
+		case StrategyError:
+			if i != len(c.Chain)-1 {
+				return fmt.Errorf("chain[%d]: %s must be the last strategy", i, strategy)
+			}
+		default:
+			return fmt.Errorf("chain[%d]: invalid strategy %s, must be %s, %s or %s", i, strategy, StrategyDynamic, StrategyStatic, StrategyError)
+		}
+		if seen[strategy] {
+			return fmt.Errorf("chain[%d]: duplicated strategy %s", i, strategy)
+		}
+		seen[strategy] = true
+	}
+
+	if len(c.On) == 0 {
+		c.On = DefaultFallbackOn
+	}
+	for _, code := range c.On {
+		if !errs.Code(code).Known() {
+			return fmt.Errorf("on: unknown error code %s", code)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultFaultHeader 默认的故障注入请求头
+const DefaultFaultHeader = "X-Costrict-Fault"
+
+// FaultAllRules 故障注入请求头取该值时尝试全部规则
+const FaultAllRules = "all"
+
+// FaultInjectionConfig 故障注入配置，用于验证 IDE 插件在网关与隧道异常时的表现
+// 仅对携带故障注入请求头的请求生效：请求头取值为规则名称（逗号分隔）时只尝试这些规则，为 all 时尝试全部规则
+// 规则按顺序匹配，命中第一条且被抽中时注入该规则的故障，故障只影响携带请求头的请求本身
+type FaultInjectionConfig struct {
+	Enabled bool              `json:"enabled,optional" yaml:"enabled"` // 是否启用故障注入
+	Header  string            `json:"header,optional" yaml:"header"`   // 故障注入请求头，转发前移除
+	Rules   []FaultRuleConfig `json:"rules,optional" yaml:"rules"`     // 按顺序匹配的故障规则
+}
+
+// FaultRuleConfig 单条故障规则，过滤条件之间为“与”，未配置的条件视为匹配
+// 多种故障可以组合：先等待 delay，再按 abort 或 reset 结束请求，否则按 bandwidth 与 partial_bytes 限制响应
+type FaultRuleConfig struct {
+	Name         string        `json:"name" yaml:"name"`                            // 规则名称，供故障注入请求头选择
+	Percentage   float64       `json:"percentage,optional" yaml:"percentage"`       // 注入故障的请求比例（0-100），默认 100
+	Routes       []string      `json:"routes,optional" yaml:"routes"`               // 路由名称或 path_prefix
+	Strategies   []string      `json:"strategies,optional" yaml:"strategies"`       // 转发策略: dynamic, static, url, route
+	ClientIDs    []string      `json:"client_ids,optional" yaml:"client_ids"`       // clientId
+	Delay        time.Duration `json:"delay,optional" yaml:"delay"`                 // 转发前增加的延迟
+	Abort        int           `json:"abort,optional" yaml:"abort"`                 // 不转发，直接以该状态码响应（400-599）
+	Reset        bool          `json:"reset,optional" yaml:"reset"`                 // 不转发，直接重置客户端连接
+	Bandwidth    int64         `json:"bandwidth,optional" yaml:"bandwidth"`         // 响应体写出速率上限，单位字节/秒
+	PartialBytes int64         `json:"partial_bytes,optional" yaml:"partial_bytes"` // 只写出响应体的前 partial_bytes 字节后断开连接
+}
+
+// validate 验证故障注入配置并填充默认值
+func (c *FaultInjectionConfig) validate(cfg *ProxyConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Rules) == 0 {
+		return errors.New("rules cannot be empty when enabled is true")
+	}
+	names := make(map[string]bool, len(c.Rules))
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rules[%d] name is required", i)
+		}
+		if rule.Name == FaultAllRules {
+			return fmt.Errorf("rules[%d] name %s is reserved", i, FaultAllRules)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rules[%d] duplicated name: %s", i, rule.Name)
+		}
+		names[rule.Name] = true
+		if err := rule.validate(cfg); err != nil {
+			return fmt.Errorf("rules[%d] %w", i, err)
+		}
+	}
+	if c.Header == "" {
+		c.Header = DefaultFaultHeader
+	}
+	return nil
+}
+
+// validate 验证单条故障规则并填充默认值
+func (r *FaultRuleConfig) validate(cfg *ProxyConfig) error {
+	if r.Percentage < 0 || r.Percentage > 100 {
+		return fmt.Errorf("percentage must be between 0 and 100, got %v", r.Percentage)
+	}
+	if r.Percentage == 0 {
+		r.Percentage = 100
+	}
+	for i, route := range r.Routes {
+		if !cfg.hasRoute(route) {
+			return fmt.Errorf("routes[%d] references unknown route: %s", i, route)
+		}
+	}
+	for i, strategy := range r.Strategies {
+		switch strategy {
+		case TargetTypeDynamic, TargetTypeStatic, TargetTypeURL, TargetTypeRoute:
+		default:
+			return fmt.Errorf("strategies[%d] invalid strategy %s, must be %s, %s, %s or %s", i, strategy, TargetTypeDynamic, TargetTypeStatic, TargetTypeURL, TargetTypeRoute)
+		}
+	}
+	if r.Delay < 0 {
+		return errors.New("delay cannot be negative")
+	}
+	if r.Abort != 0 && (r.Abort < http.StatusBadRequest || r.Abort > 599) {
+		return fmt.Errorf("abort must be an HTTP status between 400 and 599, got %d", r.Abort)
+	}
+	if r.Abort != 0 && r.Reset {
+		return errors.New("abort and reset cannot be both set")
+	}
+	if r.Bandwidth < 0 {
+		return errors.New("bandwidth cannot be negative")
+	}
+	if r.PartialBytes < 0 {
+		return errors.New("partial_bytes cannot be negative")
+	}
+	if r.Delay == 0 && r.Abort == 0 && !r.Reset && r.Bandwidth == 0 && r.PartialBytes == 0 {
+		return errors.New("at least one of delay, abort, reset, bandwidth or partial_bytes is required")
+	}
+	return nil
+}
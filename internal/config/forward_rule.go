@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// ForwardRulesConfig 转发规则引擎配置
+// 规则按顺序匹配，命中第一条即按其目标转发，全部未命中时使用 Default
+type ForwardRulesConfig struct {
+	Enabled bool                `json:"enabled,optional" yaml:"enabled"` // 是否启用规则引擎
+	Rules   []ForwardRuleConfig `json:"rules,optional" yaml:"rules"`     // 按顺序匹配的规则
+	Default ForwardTargetConfig `json:"default,optional" yaml:"default"` // 未命中任何规则时的目标
+}
+
+// ForwardRuleConfig 单条转发规则
+type ForwardRuleConfig struct {
+	Name    string              `json:"name,optional" yaml:"name"`       // 规则名称，用于日志
+	Match   RuleMatchConfig     `json:"match" yaml:"match"`              // 匹配条件，多个条件之间为“与”
+	Target  ForwardTargetConfig `json:"target" yaml:"target"`            // 转发目标
+	Headers HeaderPolicyConfig  `json:"headers,optional" yaml:"headers"` // 命中该规则时的头策略
+}
+
+// RuleMatchConfig 规则匹配条件，未配置的条件视为匹配
+type RuleMatchConfig struct {
+	Path          string             `json:"path,optional" yaml:"path"`                     // 路径模式
+	PathType      string             `json:"path_type,optional" yaml:"path_type"`           // 路径匹配方式: exact, prefix, glob, regex
+	Methods       []string           `json:"methods,optional" yaml:"methods"`               // HTTP 方法
+	Headers       []ValueMatchConfig `json:"headers,optional" yaml:"headers"`               // 请求头条件
+	Query         []ValueMatchConfig `json:"query,optional" yaml:"query"`                   // 查询参数条件
+	Version       string             `json:"version,optional" yaml:"version"`               // 版本范围，如 ">=2.3.0 <3.0.0"
+	VersionHeader string             `json:"version_header,optional" yaml:"version_header"` // 版本所在请求头，默认 X-Costrict-Version
+}
+
+// ValueMatchConfig 请求头或查询参数的值匹配条件
+type ValueMatchConfig struct {
+	Name  string `json:"name" yaml:"name"`            // 请求头或参数名
+	Op    string `json:"op,optional" yaml:"op"`       // 比较方式: exists, absent, equals, not_equals, prefix, suffix, contains, regex
+	Value string `json:"value,optional" yaml:"value"` // 比较值
+}
+
+// ForwardTargetConfig 转发目标
+type ForwardTargetConfig struct {
+	Type  string `json:"type" yaml:"type"`            // 目标类型: url, dynamic, static, route
+	URL   string `json:"url,optional" yaml:"url"`     // type=url 时的完整转发地址
+	Route string `json:"route,optional" yaml:"route"` // type=route 时引用的路由名称
+}
+
+// 路径匹配方式常量
+const (
+	PathMatchExact  = "exact"
+	PathMatchPrefix = "prefix"
+	PathMatchGlob   = "glob"
+	PathMatchRegex  = "regex"
+)
+
+// 值比较方式常量
+const (
+	ValueOpExists    = "exists"
+	ValueOpAbsent    = "absent"
+	ValueOpEquals    = "equals"
+	ValueOpNotEquals = "not_equals"
+	ValueOpPrefix    = "prefix"
+	ValueOpSuffix    = "suffix"
+	ValueOpContains  = "contains"
+	ValueOpRegex     = "regex"
+)
+
+// 转发目标类型常量
+const (
+	TargetTypeURL     = "url"     // 转发到固定地址
+	TargetTypeDynamic = "dynamic" // 通过端口管理器转发到隧道
+	TargetTypeStatic  = "static"  // 转发到 forward_url
+	TargetTypeRoute   = "route"   // 转发到命名路由
+)
+
+// DefaultVersionHeader 默认的版本请求头
+const DefaultVersionHeader = "X-Costrict-Version"
+
+// validate 验证规则引擎配置并填充默认值
+func (c *ForwardRulesConfig) validate(p *ProxyConfig) error {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if err := rule.Match.validate(); err != nil {
+			return fmt.Errorf("forward_rules.rules[%d] %w", i, err)
+		}
+		if err := rule.Headers.validate(); err != nil {
+			return fmt.Errorf("forward_rules.rules[%d].headers %w", i, err)
+		}
+		if err := rule.Target.validate(p); err != nil {
+			return fmt.Errorf("forward_rules.rules[%d].target %w", i, err)
+		}
+	}
+	if c.Default.Type == "" {
+		return errors.New("forward_rules.default is required when forward_rules.enabled is true")
+	}
+	if err := c.Default.validate(p); err != nil {
+		return fmt.Errorf("forward_rules.default %w", err)
+	}
+	return nil
+}
+
+// validate 验证匹配条件并填充默认值
+func (m *RuleMatchConfig) validate() error {
+	if m.PathType == "" {
+		m.PathType = PathMatchExact
+	}
+	switch m.PathType {
+	case PathMatchExact, PathMatchPrefix, PathMatchGlob:
+	case PathMatchRegex:
+		if _, err := regexp.Compile(m.Path); err != nil {
+			return fmt.Errorf("match.path invalid regex: %w", err)
+		}
+	default:
+		return fmt.Errorf("match.path_type invalid: %s", m.PathType)
+	}
+
+	for i, method := range m.Methods {
+		m.Methods[i] = strings.ToUpper(method)
+	}
+	for i := range m.Headers {
+		if err := m.Headers[i].validate(); err != nil {
+			return fmt.Errorf("match.headers[%d] %w", i, err)
+		}
+	}
+	for i := range m.Query {
+		if err := m.Query[i].validate(); err != nil {
+			return fmt.Errorf("match.query[%d] %w", i, err)
+		}
+	}
+
+	if m.Version != "" {
+		if _, err := utils.ParseSemverRange(m.Version); err != nil {
+			return fmt.Errorf("match.version %w", err)
+		}
+		if m.VersionHeader == "" {
+			m.VersionHeader = DefaultVersionHeader
+		}
+	}
+	return nil
+}
+
+// validate 验证值匹配条件并填充默认值
+func (v *ValueMatchConfig) validate() error {
+	if v.Name == "" {
+		return errors.New("name is required")
+	}
+	if v.Op == "" {
+		if v.Value == "" {
+			v.Op = ValueOpExists
+		} else {
+			v.Op = ValueOpEquals
+		}
+	}
+	switch v.Op {
+	case ValueOpExists, ValueOpAbsent, ValueOpEquals, ValueOpNotEquals, ValueOpPrefix, ValueOpSuffix, ValueOpContains:
+	case ValueOpRegex:
+		if _, err := regexp.Compile(v.Value); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid op: %s", v.Op)
+	}
+	return nil
+}
+
+// validate 验证转发目标
+func (t *ForwardTargetConfig) validate(p *ProxyConfig) error {
+	switch t.Type {
+	case TargetTypeURL:
+		u, err := url.Parse(t.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid url: %s", t.URL)
+		}
+	case TargetTypeDynamic:
+	case TargetTypeStatic:
+		if p.ForwardURL == "" {
+			return errors.New("type static requires forward_url")
+		}
+	case TargetTypeRoute:
+		if p.FindRoute(t.Route) == nil {
+			return fmt.Errorf("route not found: %s", t.Route)
+		}
+	default:
+		return fmt.Errorf("invalid type: %s", t.Type)
+	}
+	return nil
+}
+
+// FindRoute 按名称查找路由
+func (c *ProxyConfig) FindRoute(name string) *RouteConfig {
+	if name == "" {
+		return nil
+	}
+	for i := range c.Routes {
+		if c.Routes[i].Name == name {
+			return &c.Routes[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// 转发头处理模式
+const (
+	ForwardedModeAppend  = "append"  // 在可信的已有值之后追加本跳信息
+	ForwardedModeReplace = "replace" // 丢弃已有值，只保留解析出的客户端信息
+	ForwardedModeDrop    = "drop"    // 不向上游发送任何转发头
+)
+
+// ForwardedHeadersConfig X-Forwarded-* 与 RFC 7239 Forwarded 头配置
+type ForwardedHeadersConfig struct {
+	Mode           string   `json:"mode,optional" yaml:"mode"`                       // append(默认)、replace、drop
+	TrustedProxies []string `json:"trusted_proxies,optional" yaml:"trusted_proxies"` // 可信代理的 CIDR 或 IP，仅信任来自这些地址的已有转发头
+	RFC7239        bool     `json:"rfc7239,optional" yaml:"rfc7239"`                 // 是否同时发送 RFC 7239 Forwarded 头
+	PreserveHost   bool     `json:"preserve_host,optional" yaml:"preserve_host"`     // 是否向上游保留原始 Host
+}
+
+// validate 验证转发头配置并填充默认值
+func (c *ForwardedHeadersConfig) validate() error {
+	if c.Mode == "" {
+		c.Mode = ForwardedModeAppend
+	}
+	switch c.Mode {
+	case ForwardedModeAppend, ForwardedModeReplace, ForwardedModeDrop:
+	default:
+		return fmt.Errorf("invalid mode: %s, must be %s, %s or %s", c.Mode, ForwardedModeAppend, ForwardedModeReplace, ForwardedModeDrop)
+	}
+
+	for i, cidr := range c.TrustedProxies {
+		if _, err := ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("trusted_proxies[%d] %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ParseCIDR 解析 CIDR，单个 IP 视为 /32 或 /128
+func ParseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP or CIDR: %s", s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP or CIDR: %s", s)
+	}
+	return ipNet, nil
+}
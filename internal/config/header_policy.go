@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"net/textproto"
+
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// 请求头模板变量
+const (
+	HeaderVarClientIP  = "client_ip"  // 客户端 IP
+	HeaderVarUser      = "user"       // JWT 中的用户名
+	HeaderVarClientID  = "client_id"  // 请求中的 clientId
+	HeaderVarRequestID = "request_id" // 请求 ID
+)
+
+// HeaderPolicyConfig 请求头与响应头策略
+type HeaderPolicyConfig struct {
+	Request  HeaderOpsConfig `json:"request,optional" yaml:"request"`   // 转发请求头操作
+	Response HeaderOpsConfig `json:"response,optional" yaml:"response"` // 响应头操作
+}
+
+// HeaderOpsConfig 头操作，按 remove → rename → set → add 的顺序执行
+// set 与 add 的值为模板，支持 {client_ip}、{user}、{client_id}、{request_id}
+type HeaderOpsConfig struct {
+	Remove []string          `json:"remove,optional" yaml:"remove"` // 删除的头，支持 * 通配
+	Rename map[string]string `json:"rename,optional" yaml:"rename"` // 重命名，原名称: 新名称
+	Set    map[string]string `json:"set,optional" yaml:"set"`       // 覆盖写入
+	Add    map[string]string `json:"add,optional" yaml:"add"`       // 追加写入，保留已有值
+}
+
+// IsEmpty 判断策略是否未配置任何操作
+func (p HeaderPolicyConfig) IsEmpty() bool {
+	return p.Request.isEmpty() && p.Response.isEmpty()
+}
+
+func (o HeaderOpsConfig) isEmpty() bool {
+	return len(o.Remove) == 0 && len(o.Rename) == 0 && len(o.Set) == 0 && len(o.Add) == 0
+}
+
+// validate 验证头策略
+func (p HeaderPolicyConfig) validate() error {
+	if err := p.Request.validate(); err != nil {
+		return fmt.Errorf("request %w", err)
+	}
+	if err := p.Response.validate(); err != nil {
+		return fmt.Errorf("response %w", err)
+	}
+	return nil
+}
+
+func (o HeaderOpsConfig) validate() error {
+	for from, to := range o.Rename {
+		if from == "" || to == "" {
+			return fmt.Errorf("rename %q -> %q: header name cannot be empty", from, to)
+		}
+		if textproto.CanonicalMIMEHeaderKey(from) == textproto.CanonicalMIMEHeaderKey(to) {
+			return fmt.Errorf("rename %s: source and target are the same header", from)
+		}
+	}
+
+	for _, values := range []map[string]string{o.Set, o.Add} {
+		for name, tpl := range values {
+			if name == "" {
+				return fmt.Errorf("header name cannot be empty")
+			}
+			for _, param := range utils.PathTemplateParams(tpl) {
+				switch param {
+				case HeaderVarClientIP, HeaderVarUser, HeaderVarClientID, HeaderVarRequestID:
+				default:
+					return fmt.Errorf("header %s references unknown variable {%s}", name, param)
+				}
+			}
+		}
+	}
+	return nil
+}
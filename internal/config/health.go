@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 默认就绪检查配置
+const (
+	DefaultHealthTimeout = 2 * time.Second
+	DefaultHealthPath    = "/"
+)
+
+// HealthConfig 就绪检查配置，用于 /readyz
+// 检查端口管理器是否可达，以及每个 required 路由是否至少有一个可达的目标
+type HealthConfig struct {
+	Timeout         time.Duration `json:"timeout,optional" yaml:"timeout"`                     // 单项检查超时
+	Path            string        `json:"path,optional" yaml:"path"`                           // 探测目标时请求的路径，响应状态码小于 500 视为可达
+	SkipPortManager bool          `json:"skip_port_manager,optional" yaml:"skip_port_manager"` // 不检查端口管理器，用于未启用动态转发的部署
+	PortManagerPath string        `json:"port_manager_path,optional" yaml:"port_manager_path"` // 探测端口管理器时请求的路径，默认同 path
+}
+
+// validate 验证就绪检查配置
+func (c *HealthConfig) validate() error {
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("path must start with '/': %s", c.Path)
+	}
+	if c.PortManagerPath != "" && !strings.HasPrefix(c.PortManagerPath, "/") {
+		return fmt.Errorf("port_manager_path must start with '/': %s", c.PortManagerPath)
+	}
+	return nil
+}
+
+// setDefaults 填充就绪检查默认值
+func (c *HealthConfig) setDefaults() {
+	if c.Timeout == 0 {
+		c.Timeout = DefaultHealthTimeout
+	}
+	if c.Path == "" {
+		c.Path = DefaultHealthPath
+	}
+	if c.PortManagerPath == "" {
+		c.PortManagerPath = c.Path
+	}
+}
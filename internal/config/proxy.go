@@ -13,49 +13,90 @@ type ProxyConfig struct {
 	Routes         []RouteConfig     `json:"routes" yaml:"routes"` // 路由规则数组
 	Rewrite        RewriteConfig     `json:"rewrite" yaml:"rewrite"`
 	Headers        HeadersConfig     `json:"headers" yaml:"headers"`
-	PortManagerURL string            `json:"port_manager_url" yaml:"port_manager_url"` // 端口管理器URL
-	DynamicPort    bool              `json:"dynamic_port" yaml:"dynamic_port"`         // 是否启用动态端口
-	PortManager    PortManagerConfig `json:"port_manager" yaml:"port_manager"`         // 端口管理器配置
-	ForwardURL     string            `json:"forward_url" yaml:"forward_url"`           // 转发地址
+	PortManagerURL string            `json:"port_manager_url,optional" yaml:"port_manager_url"` // 端口管理器URL
+	DynamicPort    bool              `json:"dynamic_port,optional" yaml:"dynamic_port"`         // 是否启用动态端口
+	PortManager    PortManagerConfig `json:"port_manager" yaml:"port_manager"`                  // 端口管理器配置
+	ForwardURL     string            `json:"forward_url" yaml:"forward_url"`                    // 转发地址
 	// 基于请求头的转发配置
-	HeaderBasedForward HeaderBasedForwardConfig `json:"header_based_forward" yaml:"header_based_forward"` // 基于请求头的转发配置
+	HeaderBasedForward HeaderBasedForwardConfig  `json:"header_based_forward,optional" yaml:"header_based_forward"` // 基于请求头的转发配置
+	FanOut             FanOutConfig              `json:"fan_out,optional" yaml:"fan_out"`                           // 多后端聚合检索配置
+	ForwardRules       ForwardRulesConfig        `json:"forward_rules,optional" yaml:"forward_rules"`               // 转发规则引擎配置
+	TrafficSplit       TrafficSplitConfig        `json:"traffic_split,optional" yaml:"traffic_split"`               // 按权重分流配置
+	Shadow             ShadowConfig              `json:"shadow,optional" yaml:"shadow"`                             // 影子流量配置
+	Errors             ErrorsConfig              `json:"errors,optional" yaml:"errors"`                             // 错误响应格式配置
+	Timeouts           TimeoutsConfig            `json:"timeouts,optional" yaml:"timeouts"`                         // 转发超时配置
+	Body               BodyConfig                `json:"body,optional" yaml:"body"`                                 // 请求体与响应体限制
+	Bandwidth          BandwidthConfig           `json:"bandwidth,optional" yaml:"bandwidth"`                       // 上传与下载带宽限制
+	Compression        CompressionConfig         `json:"compression,optional" yaml:"compression"`                   // 网关侧压缩配置
+	ClientID           []ClientIDExtractorConfig `json:"client_id,optional" yaml:"client_id"`                       // clientId 提取规则，按顺序尝试，默认依次查找 JSON 请求体、查询参数与请求头
+	Health             HealthConfig              `json:"health,optional" yaml:"health"`                             // 就绪检查配置
+	Transport          TransportConfig           `json:"transport,optional" yaml:"transport"`                       // 转发连接池配置
+	Debug              DebugConfig               `json:"debug,optional" yaml:"debug"`                               // 调试模式配置
+	Record             RecordConfig              `json:"record,optional" yaml:"record"`                             // 流量录制配置
+	FaultInjection     FaultInjectionConfig      `json:"fault_injection,optional" yaml:"fault_injection"`           // 故障注入配置
+	UserInfoHeader     string                    `json:"user_info_header,optional" yaml:"user_info_header"`         // 用户信息请求头，默认取 Auth.UserInfoHeader
+}
+
+// FanOutConfig 多后端聚合检索配置
+// 将一次检索请求并行发送到多个 clientId（通过端口管理器）和/或静态目标，按得分合并结果
+// 检索请求通过查询参数或 JSON 请求体携带 client_ids_param 时聚合，否则按普通路由转发
+type FanOutConfig struct {
+	Enabled        bool          `json:"enabled,optional" yaml:"enabled"`                   // 是否启用聚合检索
+	Path           string        `json:"path,optional" yaml:"path"`                         // 检索路径，携带 clientId 列表的请求在该路径上聚合，各后端也使用该路径
+	ClientIDsParam string        `json:"client_ids_param,optional" yaml:"client_ids_param"` // 携带 clientId 列表的参数名
+	Targets        []string      `json:"targets,optional" yaml:"targets"`                   // 静态目标地址
+	Timeout        time.Duration `json:"timeout,optional" yaml:"timeout"`                   // 单个后端的超时时间
+	MaxBackends    int           `json:"max_backends,optional" yaml:"max_backends"`         // 单次请求最多扇出的后端数量
 }
 
 // HeaderBasedForwardConfig 基于请求头的转发配置
 type HeaderBasedForwardConfig struct {
-	Enabled    bool                           `json:"enabled" yaml:"enabled"`         // 是否启用基于请求头的转发
-	HeaderName string                         `json:"header_name" yaml:"header_name"` // 请求头名称
-	Paths      []HeaderBasedForwardPathConfig `json:"paths" yaml:"paths"`             // 多路径配置数组
+	Enabled    bool                           `json:"enabled,optional" yaml:"enabled"`         // 是否启用基于请求头的转发
+	HeaderName string                         `json:"header_name,optional" yaml:"header_name"` // 请求头名称
+	Paths      []HeaderBasedForwardPathConfig `json:"paths,optional" yaml:"paths"`             // 多路径配置数组
 }
 
 // HeaderBasedForwardPathConfig 基于请求头的转发路径配置
 type HeaderBasedForwardPathConfig struct {
-	Path             string `json:"path" yaml:"path"`                             // 目标路径
-	WithHeaderURL    string `json:"with_header_url" yaml:"with_header_url"`       // 有请求头时的转发地址
-	WithoutHeaderURL string `json:"without_header_url" yaml:"without_header_url"` // 无请求头时的转发地址
+	Path             string             `json:"path,optional" yaml:"path"`                             // 目标路径
+	WithHeaderURL    string             `json:"with_header_url,optional" yaml:"with_header_url"`       // 有请求头时的转发地址
+	WithoutHeaderURL string             `json:"without_header_url,optional" yaml:"without_header_url"` // 无请求头时的转发地址
+	Headers          HeaderPolicyConfig `json:"headers,optional" yaml:"headers"`                       // 该路径的头策略
 }
 
 // PortManagerConfig 端口管理器配置
 type PortManagerConfig struct {
-	URL                 string        `json:"url" yaml:"url"`                                         // 端口管理器URL
-	ForwardURL          string        `json:"forward_url" yaml:"forward_url"`                         // 转发地址
-	Timeout             time.Duration `json:"timeout" yaml:"timeout"`                                 // 请求超时时间
-	CacheExp            time.Duration `json:"cache_exp" yaml:"cache_exp"`                             // 缓存过期时间
-	MaxIdleConns        int           `json:"max_idle_conns" yaml:"max_idle_conns"`                   // 最大空闲连接数
-	MaxIdleConnsPerHost int           `json:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host"` // 每个主机的最大空闲连接数
-	IdleConnTimeout     time.Duration `json:"idle_conn_timeout" yaml:"idle_conn_timeout"`             // 空闲连接超时时间
+	URL                 string        `json:"url,optional" yaml:"url"`                                         // 端口管理器URL
+	ForwardURL          string        `json:"forward_url,optional" yaml:"forward_url"`                         // 转发地址
+	Timeout             time.Duration `json:"timeout,optional" yaml:"timeout"`                                 // 请求超时时间
+	CacheExp            time.Duration `json:"cache_exp,optional" yaml:"cache_exp"`                             // 缓存过期时间
+	MaxIdleConns        int           `json:"max_idle_conns,optional" yaml:"max_idle_conns"`                   // 最大空闲连接数
+	MaxIdleConnsPerHost int           `json:"max_idle_conns_per_host,optional" yaml:"max_idle_conns_per_host"` // 每个主机的最大空闲连接数
+	IdleConnTimeout     time.Duration `json:"idle_conn_timeout,optional" yaml:"idle_conn_timeout"`             // 空闲连接超时时间
 }
 
 // RouteConfig 路由配置
 type RouteConfig struct {
-	PathPrefix string       `json:"path_prefix" yaml:"path_prefix"` // 路径前缀
-	Target     TargetConfig `json:"target" yaml:"target"`           // 目标服务配置
+	Name           string                    `json:"name,optional" yaml:"name"`                       // 路由名称，供转发规则引用
+	PathPrefix     string                    `json:"path_prefix" yaml:"path_prefix"`                  // 路径前缀
+	Target         TargetConfig              `json:"target" yaml:"target"`                            // 目标服务配置
+	Headers        HeaderPolicyConfig        `json:"headers,optional" yaml:"headers"`                 // 路由级头策略，在全局策略之后执行
+	ErrorPages     []ErrorPageConfig         `json:"error_pages,optional" yaml:"error_pages"`         // 路由级错误页，按顺序匹配错误码
+	Fallback       FallbackConfig            `json:"fallback,optional" yaml:"fallback"`               // 路由级回退链
+	Timeouts       TimeoutsConfig            `json:"timeouts,optional" yaml:"timeouts"`               // 路由级超时，覆盖全局超时
+	Body           BodyConfig                `json:"body,optional" yaml:"body"`                       // 路由级请求体与响应体限制，覆盖全局限制
+	Bandwidth      BandwidthConfig           `json:"bandwidth,optional" yaml:"bandwidth"`             // 路由级带宽限制，覆盖全局限制
+	Compression    CompressionConfig         `json:"compression,optional" yaml:"compression"`         // 路由级压缩配置，覆盖全局配置
+	BodyTransforms []BodyTransformConfig     `json:"body_transforms,optional" yaml:"body_transforms"` // 路由级 JSON 请求体转换，按顺序执行
+	ClientID       []ClientIDExtractorConfig `json:"client_id,optional" yaml:"client_id"`             // 路由级 clientId 提取规则，配置后替换全局规则
+	Required       bool                      `json:"required,optional" yaml:"required"`               // 就绪检查要求该路由至少有一个可达的目标
+	Transport      TransportConfig           `json:"transport,optional" yaml:"transport"`             // 路由目标的连接池配置，覆盖全局配置
 }
 
 // TargetConfig 目标服务配置
 type TargetConfig struct {
 	URL     string        `json:"url" yaml:"url"`
-	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	Timeout time.Duration `json:"timeout,optional" yaml:"timeout"` // 路由总超时，未配置时沿用 timeouts.default.total
 }
 
 // UnmarshalYAML 自定义YAML解析方法，支持直接解析时间字符串（如"30s"）
@@ -77,9 +118,6 @@ func (t *TargetConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			return fmt.Errorf("invalid timeout format: %v", err)
 		}
 		t.Timeout = d
-	} else {
-		// 默认超时时间
-		t.Timeout = 30 * time.Second
 	}
 
 	return nil
@@ -87,21 +125,44 @@ func (t *TargetConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 // RewriteConfig 路径重写配置
 type RewriteConfig struct {
-	Enabled bool          `json:"enabled" yaml:"enabled"`
-	Rules   []RewriteRule `json:"rules" yaml:"rules"`
+	Enabled       bool          `json:"enabled" yaml:"enabled"`
+	StripPrefixes []string      `json:"strip_prefixes,optional" yaml:"strip_prefixes"` // rewrite模式下转发前移除的路径前缀，取第一个匹配项，默认为 /api/v1/proxy 与 /proxy
+	Rules         []RewriteRule `json:"rules" yaml:"rules"`
 }
 
 // RewriteRule 重写规则
+// To 与 SetQuery 的值为模板，{name} 引用 pattern 的命名参数或 regex 的捕获组（名称或序号），
+// {query.name} 引用查询参数，被引用的查询参数会从转发请求中移除
 type RewriteRule struct {
-	From string `json:"from" yaml:"from"`
-	To   string `json:"to" yaml:"to"`
+	Type     string            `json:"type,optional" yaml:"type"`           // 规则类型: prefix(默认), regex, pattern
+	From     string            `json:"from" yaml:"from"`                    // 前缀、正则表达式或路径模式
+	To       string            `json:"to" yaml:"to"`                        // 重写后的路径
+	SetQuery map[string]string `json:"set_query,optional" yaml:"set_query"` // 追加到转发请求的查询参数
 }
 
+// 重写规则类型常量
+const (
+	RewriteTypePrefix  = "prefix"  // 前缀替换
+	RewriteTypeRegex   = "regex"   // 正则表达式
+	RewriteTypePattern = "pattern" // 命名参数路径模式，如 /api/v1/:resource/*rest
+)
+
+// RewriteQueryParamPrefix 模板中引用查询参数的前缀
+const RewriteQueryParamPrefix = "query."
+
 // HeadersConfig Header配置
 type HeadersConfig struct {
-	PassThrough bool              `json:"pass_through" yaml:"pass_through"`
-	Exclude     []string          `json:"exclude" yaml:"exclude"`
-	Override    map[string]string `json:"override" yaml:"override"`
+	PassThrough bool                   `json:"pass_through" yaml:"pass_through"` // 为 false 时仅转发内容协商相关的请求头
+	Exclude     []string               `json:"exclude" yaml:"exclude"`
+	Override    map[string]string      `json:"override" yaml:"override"`
+	Request     HeaderOpsConfig        `json:"request,optional" yaml:"request"`     // 全局转发请求头操作
+	Response    HeaderOpsConfig        `json:"response,optional" yaml:"response"`   // 全局响应头操作
+	Forwarded   ForwardedHeadersConfig `json:"forwarded,optional" yaml:"forwarded"` // X-Forwarded-* 与 Forwarded 头处理
+}
+
+// Policy 返回全局头策略
+func (h HeadersConfig) Policy() HeaderPolicyConfig {
+	return HeaderPolicyConfig{Request: h.Request, Response: h.Response}
 }
 
 // 代理模式常量
@@ -155,7 +216,50 @@ func (c *ProxyConfig) Validate() error {
 		return errors.New("at least one route is required when dynamic_port is disabled")
 	}
 
+	routeNames := make(map[string]struct{}, len(c.Routes))
 	for i, route := range c.Routes {
+		if route.Name != "" {
+			if _, exists := routeNames[route.Name]; exists {
+				return fmt.Errorf("route[%d] duplicated name: %s", i, route.Name)
+			}
+			routeNames[route.Name] = struct{}{}
+		}
+		if err := route.Headers.validate(); err != nil {
+			return fmt.Errorf("route[%d] headers %w", i, err)
+		}
+		if err := c.Routes[i].Timeouts.validate(); err != nil {
+			return fmt.Errorf("route[%d] timeouts %w", i, err)
+		}
+		if err := c.Routes[i].Body.validate(); err != nil {
+			return fmt.Errorf("route[%d] body %w", i, err)
+		}
+		if err := c.Routes[i].Bandwidth.validate(); err != nil {
+			return fmt.Errorf("route[%d] bandwidth %w", i, err)
+		}
+		if err := c.Routes[i].Compression.validate(); err != nil {
+			return fmt.Errorf("route[%d] compression %w", i, err)
+		}
+		for j := range route.BodyTransforms {
+			if err := route.BodyTransforms[j].validate(); err != nil {
+				return fmt.Errorf("route[%d] body_transforms[%d] %w", i, j, err)
+			}
+		}
+		if err := c.Routes[i].Transport.validate(); err != nil {
+			return fmt.Errorf("route[%d] transport %w", i, err)
+		}
+		for j := range route.ClientID {
+			if err := route.ClientID[j].validate(); err != nil {
+				return fmt.Errorf("route[%d] client_id[%d] %w", i, j, err)
+			}
+		}
+		if err := c.Routes[i].Fallback.validate(c.ForwardURL != ""); err != nil {
+			return fmt.Errorf("route[%d] fallback %w", i, err)
+		}
+		for j := range route.ErrorPages {
+			if err := c.Routes[i].ErrorPages[j].validate(); err != nil {
+				return fmt.Errorf("route[%d] error_pages[%d] %w", i, j, err)
+			}
+		}
 		if route.PathPrefix == "" {
 			return fmt.Errorf("route[%d] path_prefix is required", i)
 		}
@@ -165,9 +269,6 @@ func (c *ProxyConfig) Validate() error {
 		if _, err := url.Parse(route.Target.URL); err != nil {
 			return fmt.Errorf("route[%d] invalid target URL: %w", i, err)
 		}
-		if route.Target.Timeout <= 0 {
-			c.Routes[i].Target.Timeout = 30 * time.Second
-		}
 	}
 
 	// full_path模式下禁用rewrite
@@ -175,11 +276,57 @@ func (c *ProxyConfig) Validate() error {
 		c.Rewrite.Enabled = false
 	}
 
-	for _, rule := range c.Rewrite.Rules {
-		if rule.From == "" {
-			return errors.New("rewrite rule 'from' cannot be empty")
+	if err := c.Rewrite.validate(); err != nil {
+		return err
+	}
+	c.Rewrite.setDefaults()
+
+	if err := c.Headers.Policy().validate(); err != nil {
+		return fmt.Errorf("headers %w", err)
+	}
+	if err := c.Headers.Forwarded.validate(); err != nil {
+		return fmt.Errorf("headers.forwarded %w", err)
+	}
+	if err := c.Timeouts.validate(); err != nil {
+		return fmt.Errorf("timeouts %w", err)
+	}
+	c.Timeouts.setDefaults()
+	if err := c.Body.validate(); err != nil {
+		return fmt.Errorf("body %w", err)
+	}
+	c.Body.setDefaults()
+	if err := c.Bandwidth.validate(); err != nil {
+		return fmt.Errorf("bandwidth %w", err)
+	}
+	c.Bandwidth.setDefaults()
+	if err := c.Compression.validate(); err != nil {
+		return fmt.Errorf("compression %w", err)
+	}
+	if err := c.Transport.validate(); err != nil {
+		return fmt.Errorf("transport %w", err)
+	}
+	c.Transport.setDefaults()
+	for i := range c.ClientID {
+		if err := c.ClientID[i].validate(); err != nil {
+			return fmt.Errorf("client_id[%d] %w", i, err)
 		}
 	}
+	if err := c.Errors.validate(); err != nil {
+		return fmt.Errorf("errors %w", err)
+	}
+	if err := c.Health.validate(); err != nil {
+		return fmt.Errorf("health %w", err)
+	}
+	c.Health.setDefaults()
+	if err := c.Debug.validate(); err != nil {
+		return fmt.Errorf("debug %w", err)
+	}
+	if err := c.Record.validate(c); err != nil {
+		return fmt.Errorf("record %w", err)
+	}
+	if err := c.FaultInjection.validate(c); err != nil {
+		return fmt.Errorf("fault_injection %w", err)
+	}
 
 	// 验证基于请求头的转发配置
 	if c.HeaderBasedForward.Enabled {
@@ -199,9 +346,66 @@ func (c *ProxyConfig) Validate() error {
 			if pathConfig.WithoutHeaderURL == "" {
 				return fmt.Errorf("header_based_forward.paths[%d].without_header_url is required", i)
 			}
+			if err := pathConfig.Headers.validate(); err != nil {
+				return fmt.Errorf("header_based_forward.paths[%d].headers %w", i, err)
+			}
+		}
+	}
+
+	// 验证转发规则引擎配置
+	if c.ForwardRules.Enabled {
+		if err := c.ForwardRules.validate(c); err != nil {
+			return err
 		}
 	}
 
+	// 验证分流配置
+	if c.TrafficSplit.Enabled {
+		if err := c.TrafficSplit.validate(c); err != nil {
+			return err
+		}
+	}
+
+	// 验证影子流量配置
+	if c.Shadow.Enabled {
+		if err := c.Shadow.validate(); err != nil {
+			return err
+		}
+	}
+
+	// 验证聚合检索配置，端口管理器以 port_manager.url 为准，动态端口模式下已由 port_manager_url 补全
+	if c.FanOut.Enabled {
+		if err := c.FanOut.validate(c.PortManager.URL != ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate 验证聚合检索配置并填充默认值
+func (f *FanOutConfig) validate(hasPortManager bool) error {
+	if f.Path == "" {
+		f.Path = "/codebase-indexer/api/v1/search/semantic"
+	}
+	if f.ClientIDsParam == "" {
+		f.ClientIDsParam = "clientIds"
+	}
+	if f.Timeout <= 0 {
+		f.Timeout = 10 * time.Second
+	}
+	if f.MaxBackends <= 0 {
+		f.MaxBackends = 20
+	}
+	if len(f.Targets) == 0 && !hasPortManager {
+		return errors.New("fan_out.targets is required when no port manager is configured")
+	}
+	for i, target := range f.Targets {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("fan_out.targets[%d] invalid URL: %s", i, target)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 录制文件格式
+const (
+	RecordFormatJSONL = "jsonl" // 每行一条请求/响应，持续追加
+	RecordFormatHAR   = "har"   // HTTP Archive 1.2，持续追加，停机时补全文件尾
+)
+
+// RecordConfig 流量录制配置
+// 将命中过滤条件的请求与响应写入录制文件，供 replay 命令回放到本地构建的索引服务
+type RecordConfig struct {
+	Enabled       bool     `json:"enabled,optional" yaml:"enabled"`               // 是否启用流量录制
+	File          string   `json:"file,optional" yaml:"file"`                     // 录制文件
+	Format        string   `json:"format,optional" yaml:"format"`                 // 录制文件格式: jsonl(默认), har
+	Routes        []string `json:"routes,optional" yaml:"routes"`                 // 录制的路由，取路由名称或 path_prefix，为空时录制全部路由
+	ClientIDs     []string `json:"client_ids,optional" yaml:"client_ids"`         // 仅录制这些 clientId 的请求
+	Users         []string `json:"users,optional" yaml:"users"`                   // 仅录制这些用户的请求，取自用户信息请求头
+	MaxBodyBytes  int64    `json:"max_body_bytes,optional" yaml:"max_body_bytes"` // 请求体与响应体录制的最大字节数，超出部分截断
+	MaxEntries    int      `json:"max_entries,optional" yaml:"max_entries"`       // 最多录制的条数，达到后停止录制
+	QueueSize     int      `json:"queue_size,optional" yaml:"queue_size"`         // 待写入队列长度，队列满时直接丢弃
+	RedactHeaders []string `json:"redact_headers,optional" yaml:"redact_headers"` // 除凭据类请求头外额外隐藏取值的请求头
+}
+
+// validate 验证流量录制配置并填充默认值
+func (c *RecordConfig) validate(cfg *ProxyConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.File == "" {
+		return errors.New("file is required when enabled is true")
+	}
+	switch c.Format {
+	case "", RecordFormatJSONL, RecordFormatHAR:
+	default:
+		return fmt.Errorf("unsupported format: %s", c.Format)
+	}
+	for i, route := range c.Routes {
+		if !cfg.hasRoute(route) {
+			return fmt.Errorf("routes[%d] references unknown route: %s", i, route)
+		}
+	}
+	if c.MaxBodyBytes < 0 {
+		return errors.New("max_body_bytes cannot be negative")
+	}
+	if c.MaxEntries < 0 {
+		return errors.New("max_entries cannot be negative")
+	}
+	c.setDefaults()
+	return nil
+}
+
+// setDefaults 填充流量录制默认值
+func (c *RecordConfig) setDefaults() {
+	if c.Format == "" {
+		c.Format = RecordFormatJSONL
+	}
+	if c.MaxBodyBytes == 0 {
+		c.MaxBodyBytes = 1024 * 1024
+	}
+	if c.MaxEntries == 0 {
+		c.MaxEntries = 10000
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1000
+	}
+}
+
+// hasRoute 是否存在名称或 path_prefix 为 name 的路由
+func (c *ProxyConfig) hasRoute(name string) bool {
+	for _, route := range c.Routes {
+		if route.Name == name || route.PathPrefix == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// DefaultStripPrefixes 未配置 strip_prefixes 时移除的代理前缀，与此前固定去除的前缀一致
+var DefaultStripPrefixes = []string{"/api/v1/proxy", "/proxy"}
+
+// validate 验证路径重写配置，检查不可达与冲突的规则
+func (c *RewriteConfig) validate() error {
+	for i, prefix := range c.StripPrefixes {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("rewrite.strip_prefixes[%d] must start with '/': %s", i, prefix)
+		}
+	}
+
+	literals := make([]string, len(c.Rules))
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.From == "" {
+			return errors.New("rewrite rule 'from' cannot be empty")
+		}
+		if rule.Type == "" {
+			rule.Type = RewriteTypePrefix
+		}
+
+		literal, captures, err := rule.compile()
+		if err != nil {
+			return fmt.Errorf("rewrite.rules[%d] %w", i, err)
+		}
+		if err := rule.validateTemplates(captures); err != nil {
+			return fmt.Errorf("rewrite.rules[%d] %w", i, err)
+		}
+		literals[i] = literal
+
+		// 前面的前缀规则覆盖了本规则的全部匹配范围时，本规则永远不会生效
+		for j := 0; j < i; j++ {
+			prev := c.Rules[j]
+			if prev.Type == rule.Type && prev.From == rule.From {
+				return fmt.Errorf("rewrite.rules[%d] conflicts with rewrite.rules[%d]: duplicate %s %s", i, j, rule.Type, rule.From)
+			}
+			if prev.Type == RewriteTypePrefix && literal != "" && strings.HasPrefix(literal, prev.From) {
+				return fmt.Errorf("rewrite.rules[%d] (%s) is unreachable: shadowed by prefix rule rewrite.rules[%d] (%s)", i, rule.From, j, prev.From)
+			}
+		}
+	}
+	return nil
+}
+
+// setDefaults 未配置 strip_prefixes 时使用默认代理前缀
+func (c *RewriteConfig) setDefaults() {
+	if len(c.StripPrefixes) == 0 {
+		c.StripPrefixes = append([]string(nil), DefaultStripPrefixes...)
+	}
+}
+
+// compile 编译规则，返回规则匹配路径的固定前缀（无法确定时为空）与可引用的捕获组
+func (r *RewriteRule) compile() (string, map[string]struct{}, error) {
+	var re *regexp.Regexp
+	var err error
+	switch r.Type {
+	case RewriteTypePrefix:
+		return r.From, nil, nil
+	case RewriteTypeRegex:
+		re, err = regexp.Compile(r.From)
+	case RewriteTypePattern:
+		re, err = utils.CompilePathPattern(r.From)
+	default:
+		return "", nil, fmt.Errorf("invalid type: %s", r.Type)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid from %s: %w", r.From, err)
+	}
+
+	captures := make(map[string]struct{}, re.NumSubexp()*2)
+	for i, name := range re.SubexpNames() {
+		captures[strconv.Itoa(i)] = struct{}{}
+		if name != "" {
+			captures[name] = struct{}{}
+		}
+	}
+
+	// 未锚定的正则可匹配路径任意位置，不存在固定前缀
+	literal := ""
+	if r.Type == RewriteTypePattern || strings.HasPrefix(r.From, "^") {
+		literal, _ = re.LiteralPrefix()
+	}
+	return literal, captures, nil
+}
+
+// validateTemplates 检查模板引用的参数均已定义
+func (r *RewriteRule) validateTemplates(captures map[string]struct{}) error {
+	templates := []string{r.To}
+	for key, value := range r.SetQuery {
+		if key == "" {
+			return errors.New("set_query key cannot be empty")
+		}
+		templates = append(templates, value)
+	}
+
+	for _, tpl := range templates {
+		for _, param := range utils.PathTemplateParams(tpl) {
+			if strings.HasPrefix(param, RewriteQueryParamPrefix) {
+				if param == RewriteQueryParamPrefix {
+					return fmt.Errorf("template %s references an empty query parameter", tpl)
+				}
+				continue
+			}
+			if _, ok := captures[param]; !ok {
+				return fmt.Errorf("template %s references undefined parameter {%s}", tpl, param)
+			}
+		}
+	}
+	return nil
+}
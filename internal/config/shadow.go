@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ShadowConfig 影子流量配置
+// 按采样比例将请求副本异步发送到候选后端，并与主后端响应比对
+type ShadowConfig struct {
+	Enabled      bool               `json:"enabled,optional" yaml:"enabled"`               // 是否启用影子流量
+	Rules        []ShadowRuleConfig `json:"rules,optional" yaml:"rules"`                   // 影子规则，按顺序匹配
+	QueueSize    int                `json:"queue_size,optional" yaml:"queue_size"`         // 待发送队列长度，队列满时直接丢弃
+	Workers      int                `json:"workers,optional" yaml:"workers"`               // 并发发送的工作协程数
+	Timeout      time.Duration      `json:"timeout,optional" yaml:"timeout"`               // 影子请求超时时间
+	MaxBodyBytes int64              `json:"max_body_bytes,optional" yaml:"max_body_bytes"` // 参与比对的请求体与响应体最大字节数
+	DiffFile     string             `json:"diff_file,optional" yaml:"diff_file"`           // 比对结果输出文件（JSONL）
+	IgnoreFields []string           `json:"ignore_fields,optional" yaml:"ignore_fields"`   // 比对 JSON 时忽略的字段名
+}
+
+// ShadowRuleConfig 单条影子规则
+type ShadowRuleConfig struct {
+	Name       string          `json:"name" yaml:"name"`                        // 规则名称，用于指标与日志
+	Match      RuleMatchConfig `json:"match" yaml:"match"`                      // 匹配条件
+	URL        string          `json:"url" yaml:"url"`                          // 影子目标基础地址，原始路径与查询参数会拼接在其后
+	SampleRate float64         `json:"sample_rate,optional" yaml:"sample_rate"` // 采样比例，0~1
+}
+
+// validate 验证影子流量配置并填充默认值
+func (c *ShadowConfig) validate() error {
+	if len(c.Rules) == 0 {
+		return errors.New("shadow.rules is required when shadow.enabled is true")
+	}
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("shadow.rules[%d].name is required", i)
+		}
+		if err := rule.Match.validate(); err != nil {
+			return fmt.Errorf("shadow.rules[%d] %w", i, err)
+		}
+		if u, err := url.Parse(rule.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("shadow.rules[%d].url invalid: %s", i, rule.URL)
+		}
+		if rule.SampleRate < 0 || rule.SampleRate > 1 {
+			return fmt.Errorf("shadow.rules[%d].sample_rate must be between 0 and 1", i)
+		}
+	}
+	c.SetDefaults()
+	return nil
+}
+
+// SetDefaults 填充影子流量的默认值
+func (c *ShadowConfig) SetDefaults() {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1000
+	}
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = 10 * 1024 * 1024
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 默认优雅停机时间
+const (
+	DefaultReadinessDelay = 5 * time.Second
+	DefaultDrainTimeout   = 30 * time.Second
+)
+
+// GracefulShutdownConfig 优雅停机配置
+// 收到 SIGTERM 后：健康检查先返回未就绪，readiness_delay 后停止接收新请求，
+// 进行中的请求最多再等待 drain_timeout，之后取消剩余请求并关闭所有转发连接
+type GracefulShutdownConfig struct {
+	ReadinessDelay time.Duration `json:"readiness_delay,optional" yaml:"readiness_delay"` // 健康检查返回未就绪到停止接收新请求的间隔，留给负载均衡摘除实例
+	DrainTimeout   time.Duration `json:"drain_timeout,optional" yaml:"drain_timeout"`     // 停止接收新请求后等待进行中请求完成的最长时间
+}
+
+// validate 验证优雅停机配置
+func (c *GracefulShutdownConfig) validate() error {
+	if c.ReadinessDelay < 0 || c.DrainTimeout < 0 {
+		return fmt.Errorf("durations cannot be negative")
+	}
+	return nil
+}
+
+// WithDefaults 返回填充默认值后的优雅停机配置
+func (c GracefulShutdownConfig) WithDefaults() GracefulShutdownConfig {
+	if c.ReadinessDelay == 0 {
+		c.ReadinessDelay = DefaultReadinessDelay
+	}
+	if c.DrainTimeout == 0 {
+		c.DrainTimeout = DefaultDrainTimeout
+	}
+	return c
+}
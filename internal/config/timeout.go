@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 默认超时
+const (
+	DefaultConnectTimeout      = 10 * time.Second
+	DefaultTLSTimeout          = 10 * time.Second
+	DefaultTotalTimeout        = 30 * time.Second
+	DefaultClientTimeoutHeader = "X-Request-Timeout"
+)
+
+// TimeoutPhasesConfig 各阶段超时，0 表示沿用上一层配置
+type TimeoutPhasesConfig struct {
+	Connect        time.Duration `json:"connect,optional" yaml:"connect"`                 // 建立 TCP 连接
+	TLS            time.Duration `json:"tls,optional" yaml:"tls"`                         // TLS 握手
+	ResponseHeader time.Duration `json:"response_header,optional" yaml:"response_header"` // 请求发送完毕到收到响应头
+	Total          time.Duration `json:"total,optional" yaml:"total"`                     // 整个请求，包括读取响应体
+}
+
+// TimeoutsConfig 超时配置
+// 全局配置与路由配置逐层覆盖：全局 default → 路由 target.timeout → 全局 methods → 路由 default → 路由 methods
+type TimeoutsConfig struct {
+	Default      TimeoutPhasesConfig            `json:"default,optional" yaml:"default"`             // 默认超时
+	Methods      map[string]TimeoutPhasesConfig `json:"methods,optional" yaml:"methods"`             // 按请求方法覆盖，如 POST
+	ClientHeader string                         `json:"client_header,optional" yaml:"client_header"` // 客户端请求更短超时的请求头，仅全局配置生效
+	MaxClient    time.Duration                  `json:"max_client,optional" yaml:"max_client"`       // 客户端请求超时的上限，仅全局配置生效
+}
+
+// validate 验证超时配置
+func (c *TimeoutsConfig) validate() error {
+	if err := c.Default.validate(); err != nil {
+		return fmt.Errorf("default %w", err)
+	}
+	for method, phases := range c.Methods {
+		if method != strings.ToUpper(method) {
+			return fmt.Errorf("methods.%s: method must be upper case", method)
+		}
+		if err := phases.validate(); err != nil {
+			return fmt.Errorf("methods.%s %w", method, err)
+		}
+	}
+	if c.MaxClient < 0 {
+		return fmt.Errorf("max_client cannot be negative")
+	}
+	return nil
+}
+
+// setDefaults 填充全局超时默认值
+func (c *TimeoutsConfig) setDefaults() {
+	if c.Default.Connect == 0 {
+		c.Default.Connect = DefaultConnectTimeout
+	}
+	if c.Default.TLS == 0 {
+		c.Default.TLS = DefaultTLSTimeout
+	}
+	if c.Default.Total == 0 {
+		c.Default.Total = DefaultTotalTimeout
+	}
+	if c.ClientHeader == "" {
+		c.ClientHeader = DefaultClientTimeoutHeader
+	}
+	c.ClientHeader = http.CanonicalHeaderKey(c.ClientHeader)
+}
+
+func (p TimeoutPhasesConfig) validate() error {
+	if p.Connect < 0 || p.TLS < 0 || p.ResponseHeader < 0 || p.Total < 0 {
+		return fmt.Errorf("timeouts cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// TrafficSplitConfig 按权重分流配置，用于灰度发布新后端
+type TrafficSplitConfig struct {
+	Enabled bool          `json:"enabled,optional" yaml:"enabled"` // 是否启用分流
+	Splits  []SplitConfig `json:"splits,optional" yaml:"splits"`   // 分流规则，按顺序匹配
+}
+
+// SplitConfig 单条分流规则
+type SplitConfig struct {
+	Name     string                  `json:"name" yaml:"name"`              // 分流名称，用于指标与日志
+	Match    RuleMatchConfig         `json:"match" yaml:"match"`            // 匹配条件
+	Sticky   string                  `json:"sticky,optional" yaml:"sticky"` // 粘性分配方式: none, user, client_id
+	Backends []WeightedBackendConfig `json:"backends" yaml:"backends"`      // 按权重分配的后端
+	Mirror   MirrorConfig            `json:"mirror,optional" yaml:"mirror"` // 镜像目标，响应会被丢弃
+}
+
+// WeightedBackendConfig 带权重的后端
+type WeightedBackendConfig struct {
+	Name   string              `json:"name" yaml:"name"`     // 后端名称，用于指标与日志
+	Weight int                 `json:"weight" yaml:"weight"` // 权重，按占总权重的比例分配流量
+	Target ForwardTargetConfig `json:"target" yaml:"target"` // 转发目标
+}
+
+// MirrorConfig 镜像配置，将请求副本发送到影子目标
+type MirrorConfig struct {
+	URL string `json:"url,optional" yaml:"url"` // 影子目标基础地址，原始路径与查询参数会拼接在其后
+}
+
+// 粘性分配方式常量
+const (
+	StickyNone     = "none"
+	StickyUser     = "user"
+	StickyClientID = "client_id"
+)
+
+// validate 验证分流配置并填充默认值
+func (c *TrafficSplitConfig) validate(p *ProxyConfig) error {
+	names := make(map[string]struct{}, len(c.Splits))
+	for i := range c.Splits {
+		split := &c.Splits[i]
+		if split.Name == "" {
+			return fmt.Errorf("traffic_split.splits[%d].name is required", i)
+		}
+		if _, exists := names[split.Name]; exists {
+			return fmt.Errorf("traffic_split.splits[%d] duplicated name: %s", i, split.Name)
+		}
+		names[split.Name] = struct{}{}
+
+		if err := split.Match.validate(); err != nil {
+			return fmt.Errorf("traffic_split.splits[%d] %w", i, err)
+		}
+
+		switch split.Sticky {
+		case "":
+			split.Sticky = StickyNone
+		case StickyNone, StickyUser, StickyClientID:
+		default:
+			return fmt.Errorf("traffic_split.splits[%d].sticky invalid: %s", i, split.Sticky)
+		}
+
+		if len(split.Backends) == 0 {
+			return fmt.Errorf("traffic_split.splits[%d].backends is required", i)
+		}
+		totalWeight := 0
+		for j := range split.Backends {
+			backend := &split.Backends[j]
+			if backend.Name == "" {
+				return fmt.Errorf("traffic_split.splits[%d].backends[%d].name is required", i, j)
+			}
+			if backend.Weight < 0 {
+				return fmt.Errorf("traffic_split.splits[%d].backends[%d].weight must not be negative", i, j)
+			}
+			if err := backend.Target.validate(p); err != nil {
+				return fmt.Errorf("traffic_split.splits[%d].backends[%d].target %w", i, j, err)
+			}
+			totalWeight += backend.Weight
+		}
+		if totalWeight == 0 {
+			return fmt.Errorf("traffic_split.splits[%d] total weight must be positive", i)
+		}
+
+		if split.Mirror.URL != "" {
+			if u, err := url.Parse(split.Mirror.URL); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("traffic_split.splits[%d].mirror.url invalid: %s", i, split.Mirror.URL)
+			}
+		}
+	}
+	if len(c.Splits) == 0 {
+		return errors.New("traffic_split.splits is required when traffic_split.enabled is true")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// JSON 请求体转换操作
+const (
+	TransformSet           = "set"            // 设置字段
+	TransformRename        = "rename"         // 重命名字段
+	TransformRemove        = "remove"         // 删除字段
+	TransformCopy          = "copy"           // 在请求体、请求头与查询参数之间复制值
+	TransformNormalizePath = "normalize_path" // 将路径字段统一为 / 分隔符
+)
+
+// 转换中值的来源与目标位置，格式为 <位置>:<名称>，如 header:X-Client-Id、query:clientId、body:clientId
+const (
+	TransformLocationBody   = "body"
+	TransformLocationHeader = "header"
+	TransformLocationQuery  = "query"
+)
+
+// BodyTransformConfig 路由级 JSON 请求体转换，按顺序执行，仅对 JSON 对象请求体生效
+// 字段名使用点号分隔嵌套字段，如 repo.codebasePath
+type BodyTransformConfig struct {
+	Op        string `json:"op" yaml:"op"`                          // set、rename、remove、copy、normalize_path
+	Field     string `json:"field,optional" yaml:"field"`           // set、rename、remove、normalize_path 操作的字段
+	Value     string `json:"value,optional" yaml:"value"`           // set 的值，支持 {client_ip}、{request_id}
+	To        string `json:"to,optional" yaml:"to"`                 // rename 的新字段；copy 的目标位置
+	From      string `json:"from,optional" yaml:"from"`             // copy 的来源位置
+	IfMissing bool   `json:"if_missing,optional" yaml:"if_missing"` // set、copy 仅在目标不存在时写入
+}
+
+// validate 验证请求体转换配置
+func (c *BodyTransformConfig) validate() error {
+	switch c.Op {
+	case TransformSet:
+		if c.Field == "" {
+			return fmt.Errorf("field is required for %s", c.Op)
+		}
+		for _, param := range utils.PathTemplateParams(c.Value) {
+			if param != HeaderVarClientIP && param != HeaderVarRequestID {
+				return fmt.Errorf("value references unknown variable {%s}", param)
+			}
+		}
+	case TransformRename:
+		if c.Field == "" || c.To == "" {
+			return fmt.Errorf("field and to are required for %s", c.Op)
+		}
+	case TransformRemove, TransformNormalizePath:
+		if c.Field == "" {
+			return fmt.Errorf("field is required for %s", c.Op)
+		}
+	case TransformCopy:
+		if _, _, err := ParseTransformLocation(c.From); err != nil {
+			return fmt.Errorf("from %w", err)
+		}
+		if _, _, err := ParseTransformLocation(c.To); err != nil {
+			return fmt.Errorf("to %w", err)
+		}
+		if c.From == c.To {
+			return fmt.Errorf("from and to cannot be the same")
+		}
+	default:
+		return fmt.Errorf("unsupported op: %s", c.Op)
+	}
+	return nil
+}
+
+// ParseTransformLocation 解析 <位置>:<名称> 格式的位置
+func ParseTransformLocation(location string) (string, string, error) {
+	kind, name, ok := strings.Cut(location, ":")
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid location %q, expected body:<field>, header:<name> or query:<name>", location)
+	}
+	switch kind {
+	case TransformLocationBody, TransformLocationHeader, TransformLocationQuery:
+		return kind, name, nil
+	default:
+		return "", "", fmt.Errorf("unsupported location %q", kind)
+	}
+}
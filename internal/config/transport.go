@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 默认连接池配置，与原有转发客户端一致
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 10
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultDialTimeout         = 30 * time.Second
+	DefaultTCPKeepAlive        = 30 * time.Second
+)
+
+// TransportConfig 转发连接池配置，路由配置覆盖全局配置，0 表示沿用上一层配置
+// 配置了 transport 的路由目标使用独立的连接池，其余上游（动态隧道、转发规则中的 URL 等）共用全局连接池
+type TransportConfig struct {
+	MaxIdleConns        int           `json:"max_idle_conns,optional" yaml:"max_idle_conns"`                   // 连接池最大空闲连接数
+	MaxIdleConnsPerHost int           `json:"max_idle_conns_per_host,optional" yaml:"max_idle_conns_per_host"` // 每个主机的最大空闲连接数
+	MaxConnsPerHost     int           `json:"max_conns_per_host,optional" yaml:"max_conns_per_host"`           // 每个主机的最大连接数，0 表示不限制
+	IdleConnTimeout     time.Duration `json:"idle_conn_timeout,optional" yaml:"idle_conn_timeout"`             // 空闲连接保留时间
+	DialTimeout         time.Duration `json:"dial_timeout,optional" yaml:"dial_timeout"`                       // 建立连接的超时上限，timeouts.connect 更短时以其为准
+	TCPKeepAlive        time.Duration `json:"tcp_keep_alive,optional" yaml:"tcp_keep_alive"`                   // TCP keepalive 探测间隔，负数表示关闭
+	DisableKeepAlives   bool          `json:"disable_keep_alives,optional" yaml:"disable_keep_alives"`         // 关闭 HTTP keep-alive，每个请求使用新连接
+}
+
+// validate 验证连接池配置
+func (c *TransportConfig) validate() error {
+	if c.MaxIdleConns < 0 || c.MaxIdleConnsPerHost < 0 || c.MaxConnsPerHost < 0 {
+		return fmt.Errorf("connection counts cannot be negative")
+	}
+	if c.IdleConnTimeout < 0 || c.DialTimeout < 0 {
+		return fmt.Errorf("durations cannot be negative")
+	}
+	return nil
+}
+
+// setDefaults 填充全局连接池默认值
+func (c *TransportConfig) setDefaults() {
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost == 0 {
+		c.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = DefaultDialTimeout
+	}
+	if c.TCPKeepAlive == 0 {
+		c.TCPKeepAlive = DefaultTCPKeepAlive
+	}
+}
+
+// IsZero 是否未配置任何连接池参数
+func (c TransportConfig) IsZero() bool {
+	return c == TransportConfig{}
+}
+
+// Merge 返回用 route 中非零字段覆盖后的连接池配置
+func (c TransportConfig) Merge(route TransportConfig) TransportConfig {
+	if route.MaxIdleConns != 0 {
+		c.MaxIdleConns = route.MaxIdleConns
+	}
+	if route.MaxIdleConnsPerHost != 0 {
+		c.MaxIdleConnsPerHost = route.MaxIdleConnsPerHost
+	}
+	if route.MaxConnsPerHost != 0 {
+		c.MaxConnsPerHost = route.MaxConnsPerHost
+	}
+	if route.IdleConnTimeout != 0 {
+		c.IdleConnTimeout = route.IdleConnTimeout
+	}
+	if route.DialTimeout != 0 {
+		c.DialTimeout = route.DialTimeout
+	}
+	if route.TCPKeepAlive != 0 {
+		c.TCPKeepAlive = route.TCPKeepAlive
+	}
+	if route.DisableKeepAlives {
+		c.DisableKeepAlives = true
+	}
+	return c
+}
@@ -0,0 +1,161 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Code 代理错误码
+type Code string
+
+// 代理错误码，每个错误码对应固定的 HTTP 状态码，见 catalogue
+const (
+	CodeBadRequest               Code = "PROXY_BAD_REQUEST"
+	CodeClientIDMissing          Code = "PROXY_CLIENT_ID_MISSING"
+	CodeRouteNotFound            Code = "PROXY_ROUTE_NOT_FOUND"
+	CodeTunnelNotFound           Code = "PROXY_TUNNEL_NOT_FOUND"
+	CodeMethodNotAllowed         Code = "PROXY_METHOD_NOT_ALLOWED"
+	CodeBodyTooLarge             Code = "PROXY_BODY_TOO_LARGE"
+	CodeUnsupportedMediaType     Code = "PROXY_UNSUPPORTED_MEDIA_TYPE"
+	CodeURLTooLong               Code = "PROXY_URL_TOO_LONG"
+	CodeHeadersTooLarge          Code = "PROXY_HEADERS_TOO_LARGE"
+	CodeInternal                 Code = "PROXY_INTERNAL_ERROR"
+	CodeNotConfigured            Code = "PROXY_NOT_CONFIGURED"
+	CodeUpstreamRefused          Code = "PROXY_UPSTREAM_REFUSED"
+	CodeUpstreamFailed           Code = "PROXY_UPSTREAM_FAILED"
+	CodeResponseTooLarge         Code = "PROXY_RESPONSE_TOO_LARGE"
+	CodeTargetUnreachable        Code = "PROXY_TARGET_UNREACHABLE"
+	CodeTunnelManagerUnavailable Code = "PROXY_TUNNEL_MANAGER_UNAVAILABLE"
+	CodeShuttingDown             Code = "PROXY_SHUTTING_DOWN"
+	CodeTimeout                  Code = "PROXY_TIMEOUT"
+)
+
+// catalogueEntry 错误码目录项
+type catalogueEntry struct {
+	status  int    // HTTP 状态码
+	number  int    // response.Response 信封中使用的数字错误码
+	message string // 默认错误信息
+}
+
+var catalogue = map[Code]catalogueEntry{
+	CodeBadRequest:               {http.StatusBadRequest, 40000, "Invalid request format"},
+	CodeClientIDMissing:          {http.StatusBadRequest, 40001, "Client id is required"},
+	CodeRouteNotFound:            {http.StatusNotFound, 40400, "No route matches the request"},
+	CodeTunnelNotFound:           {http.StatusNotFound, 40401, "No tunnel found for the client"},
+	CodeMethodNotAllowed:         {http.StatusMethodNotAllowed, 40500, "HTTP method not allowed"},
+	CodeBodyTooLarge:             {http.StatusRequestEntityTooLarge, 41300, "Request body too large"},
+	CodeURLTooLong:               {http.StatusRequestURITooLong, 41400, "Request URL too long"},
+	CodeUnsupportedMediaType:     {http.StatusUnsupportedMediaType, 41500, "Unsupported request content type"},
+	CodeHeadersTooLarge:          {http.StatusRequestHeaderFieldsTooLarge, 43100, "Request headers too large"},
+	CodeInternal:                 {http.StatusInternalServerError, 50000, "Internal server error"},
+	CodeNotConfigured:            {http.StatusNotImplemented, 50100, "Proxy not configured"},
+	CodeUpstreamRefused:          {http.StatusBadGateway, 50200, "Upstream refused the connection"},
+	CodeUpstreamFailed:           {http.StatusBadGateway, 50201, "Failed to forward request to upstream"},
+	CodeResponseTooLarge:         {http.StatusBadGateway, 50202, "Upstream response too large"},
+	CodeTargetUnreachable:        {http.StatusServiceUnavailable, 50300, "Target service is unreachable"},
+	CodeTunnelManagerUnavailable: {http.StatusServiceUnavailable, 50301, "Tunnel manager is unavailable"},
+	CodeShuttingDown:             {http.StatusServiceUnavailable, 50302, "Proxy is shutting down"},
+	CodeTimeout:                  {http.StatusGatewayTimeout, 50400, "Request timeout"},
+}
+
+// Known 判断错误码是否在目录中
+func (c Code) Known() bool {
+	_, ok := catalogue[c]
+	return ok
+}
+
+// Status 返回错误码对应的 HTTP 状态码，未知错误码视为 500
+func (c Code) Status() int {
+	if entry, ok := catalogue[c]; ok {
+		return entry.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Number 返回错误码在 response.Response 信封中使用的数字错误码
+func (c Code) Number() int {
+	if entry, ok := catalogue[c]; ok {
+		return entry.number
+	}
+	return catalogue[CodeInternal].number
+}
+
+// Message 返回错误码的默认错误信息
+func (c Code) Message() string {
+	if entry, ok := catalogue[c]; ok {
+		return entry.message
+	}
+	return catalogue[CodeInternal].message
+}
+
+// ProxyError 代理错误
+type ProxyError struct {
+	Code      Code      `json:"code"`
+	Message   string    `json:"message"`
+	Details   string    `json:"details,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewProxyError 按错误码创建代理错误，details 为具体原因
+func NewProxyError(code Code, details string) *ProxyError {
+	return &ProxyError{
+		Code:      code,
+		Message:   code.Message(),
+		Details:   details,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// NewProxyErrorf 按错误码创建代理错误，details 按格式生成
+func NewProxyErrorf(code Code, format string, args ...interface{}) *ProxyError {
+	return NewProxyError(code, fmt.Sprintf(format, args...))
+}
+
+// Error 实现error接口
+func (e *ProxyError) Error() string {
+	if e.Details == "" {
+		return e.Message
+	}
+	return e.Message + ": " + e.Details
+}
+
+// Status 返回对应的 HTTP 状态码
+func (e *ProxyError) Status() int {
+	return e.Code.Status()
+}
+
+// AsProxyError 将任意错误转换为代理错误，非代理错误视为内部错误
+func AsProxyError(err error) *ProxyError {
+	var proxyErr *ProxyError
+	if errors.As(err, &proxyErr) {
+		return proxyErr
+	}
+	return NewProxyError(CodeInternal, err.Error())
+}
+
+// FromUpstream 按失败类型将转发上游时的传输错误归类为代理错误
+func FromUpstream(err error) *ProxyError {
+	var proxyErr *ProxyError
+	if errors.As(err, &proxyErr) {
+		return proxyErr
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return NewProxyError(CodeTimeout, err.Error())
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return NewProxyError(CodeUpstreamRefused, err.Error())
+	}
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	if errors.As(err, &dnsErr) || (errors.As(err, &opErr) && opErr.Op == "dial") {
+		return NewProxyError(CodeTargetUnreachable, err.Error())
+	}
+	return NewProxyError(CodeUpstreamFailed, err.Error())
+}
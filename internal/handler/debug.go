@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// serveDebug 处理调试请求：dry-run 不转发，直接返回转发决策；trace 正常转发，转发决策写入响应头
+// 转发决策中的端口为端口管理器实际分配的端口，凭据类请求头的取值会被隐藏
+func (h *SmartProxyHandler) serveDebug(w http.ResponseWriter, r *http.Request, route config.RouteConfig, mode string) {
+	e := newExplanation(r)
+	e.Route = routeName(route)
+	e.resolvePort = true
+	if err := h.explainRequest(e, r); err != nil {
+		logx.WithContext(r.Context()).Errorf("Failed to explain debug request: %v", err)
+		h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeInternal, "failed to explain request: %v", err))
+		return
+	}
+	e.Headers = h.debug.Redact(e.Headers)
+
+	if mode == config.DebugModeDryRun {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	h.ServeHTTP(&debugWriter{ResponseWriter: w, r: r, header: h.debug.ResponseHeader(), decision: e}, r)
+}
+
+// debugWriter 写出响应头前将转发决策写入调试响应头
+// 分流随机选择后端或触发回退时，按实际转发结果更新转发决策
+type debugWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	header      string
+	decision    *Explanation
+	wroteHeader bool
+}
+
+// WriteHeader 写入转发决策后写出状态码
+func (w *debugWriter) WriteHeader(statusCode int) {
+	w.writeDecision()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write 未显式写入状态码时先写入转发决策
+func (w *debugWriter) Write(data []byte) (int, error) {
+	w.writeDecision()
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush 透传 Flush，保证流式响应可用
+func (w *debugWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 使用
+func (w *debugWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *debugWriter) writeDecision() {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	e := w.decision
+	header := w.Header()
+	if split := header.Get(splitHeader); split != "" && split != e.Split {
+		e.note("request was served by split backend %s, url and headers were resolved for %s", split, e.Split)
+		e.Split = split
+	}
+	if failed := header.Get(proxy.FallbackFromHeader); failed != "" {
+		e.note("%s failed, request was served by fallback strategy %s", failed, header.Get(proxy.StrategyHeader))
+	}
+
+	decision, err := json.Marshal(e)
+	if err != nil {
+		logx.WithContext(w.r.Context()).Errorf("Failed to encode debug decision: %v", err)
+		return
+	}
+	header.Set(w.header, string(decision))
+}
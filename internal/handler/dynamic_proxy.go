@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,52 +11,65 @@ import (
 
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
 	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
 )
 
 // DynamicProxyHandler 动态代理处理器
 type DynamicProxyHandler struct {
-	portManager *proxy.PortManager
-	proxyConfig *config.ProxyConfig
+	portManager  *proxy.PortManager
+	proxyConfig  *config.ProxyConfig
+	headerPolicy *proxy.HeaderPolicy
+	forwarded    *proxy.ForwardedHeaders
+	errWriter    *proxy.ErrorWriter
+	client       *http.Client
+	timeouts     *proxy.TimeoutBudget
+	clientID     *proxy.ClientIDExtractor
 }
 
 // NewDynamicProxyHandler 创建动态代理处理器
-func NewDynamicProxyHandler(cfg *config.ProxyConfig) *DynamicProxyHandler {
-	var portManager *proxy.PortManager
-
-	// 优先使用新的端口管理器配置
-	portManager = proxy.NewPortManagerWithConfig(cfg.PortManager)
+// 转发到隧道端口的请求使用 transports 的全局连接池，portManager 由调用方创建并负责关闭
+func NewDynamicProxyHandler(cfg *config.ProxyConfig, portManager *proxy.PortManager, transports *proxy.TransportRegistry) *DynamicProxyHandler {
+	forwarded, err := proxy.NewForwardedHeaders(cfg.Headers.Forwarded)
+	logx.Must(err)
 
 	return &DynamicProxyHandler{
-		portManager: portManager,
-		proxyConfig: cfg,
+		portManager:  portManager,
+		proxyConfig:  cfg,
+		headerPolicy: proxy.NewHeaderPolicy(cfg.UserInfoHeader, cfg.Headers.Policy()),
+		forwarded:    forwarded,
+		errWriter:    proxy.NewErrorWriter(cfg.Errors),
+		client:       transports.Client(),
+		timeouts:     proxy.NewTimeoutBudget(cfg.Timeouts, 0, config.TimeoutsConfig{}),
+		clientID:     proxy.NewClientIDExtractor(cfg.ClientID, nil),
 	}
 }
 
 // ServeHTTP 处理动态代理请求
 func (h *DynamicProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = proxy.WithDefaultClientIDExtractor(r, h.clientID)
 	ctx := r.Context()
 
-	logx.Infof("Received dynamic proxy request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	logx.WithContext(r.Context()).Infof("Received dynamic proxy request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
 
 	// 读取请求体
 	var body []byte
 	if r.Method != "GET" {
-		// 使用 io.ReadAll 读取完整的请求体，但限制最大大小为 10MB 防止内存问题
-		const maxBodySize = 100 * 1024 * 1024 // 10MB
-		limitReader := io.LimitReader(r.Body, maxBodySize)
+		// 使用 io.ReadAll 读取完整的请求体，但按路由与请求方法的请求体上限限制最大大小防止内存问题
+		maxBodySize := bodySizeLimit(r, h.proxyConfig)
+		limitReader := io.LimitReader(r.Body, maxBodySize+1)
 		var err error
 		body, err = io.ReadAll(limitReader)
 		if err != nil {
-			logx.Errorf("Failed to read request body: %v", err)
-			h.sendError(w, fmt.Sprintf("Failed to read request body: %v", err), http.StatusBadRequest)
+			logx.WithContext(r.Context()).Errorf("Failed to read request body: %v", err)
+			h.errWriter.Write(w, r, proxy.BodyReadError(err))
 			return
 		}
 
 		// 检查是否超出限制
-		if len(body) >= maxBodySize {
-			logx.Errorf("Request body too large, exceeds %d bytes", maxBodySize)
-			h.sendError(w, fmt.Sprintf("Request body too large, exceeds %d bytes", maxBodySize), http.StatusRequestEntityTooLarge)
+		if int64(len(body)) > maxBodySize {
+			logx.WithContext(r.Context()).Errorf("Request body too large, exceeds %d bytes", maxBodySize)
+			h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeBodyTooLarge, "request body exceeds %d bytes", maxBodySize))
 			return
 		}
 
@@ -63,66 +77,42 @@ func (h *DynamicProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		r.Body.Close()
 		r.Body = io.NopCloser(bytes.NewReader(body))
 
-		logx.Infof("Read request body: %d bytes", len(body))
+		logx.WithContext(r.Context()).Infof("Read request body: %d bytes", len(body))
 	}
 
-	// 从请求获取端口信息（GET请求从params获取，其他请求从body获取）
-	portResp, err := h.portManager.GetPortFromHeaders(ctx, r.Method, r.Header, r.URL.Query(), body)
+	// 从请求获取端口信息，clientId 按提取规则查找
+	portResp, err := h.portManager.GetPortForRequest(ctx, r)
 	if err != nil {
-		logx.Errorf("Failed to get port: %v", err)
-		h.sendError(w, fmt.Sprintf("Failed to get port: %v", err), http.StatusBadRequest)
+		logx.WithContext(r.Context()).Errorf("Failed to get port: %v", err)
+		h.errWriter.Write(w, r, err)
 		return
 	}
 
-	logx.Infof("Forwarding portResp to: %v", portResp)
+	logx.WithContext(r.Context()).Infof("Forwarding portResp to: %v", portResp)
 
 	// 构建目标URL
 	targetURL := h.portManager.BuildTargetURL(portResp)
-	logx.Infof("Forwarding request to: %s", targetURL)
-
-	// 创建HTTP客户端
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
-	}
+	logx.WithContext(r.Context()).Infof("Forwarding request to: %s", targetURL)
 
-	// 构建目标请求
-	targetReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL+r.URL.Path, r.Body)
+	// 构建目标请求，超时从端口查询之后开始计算
+	ctx, cancel := h.timeouts.Start(r)
+	defer cancel()
+	targetReq, err := h.newTargetRequest(ctx, r, targetURL, body)
 	if err != nil {
-		logx.Errorf("Failed to create target request: %v", err)
-		h.sendError(w, fmt.Sprintf("Failed to create request: %v", err), http.StatusInternalServerError)
+		logx.WithContext(r.Context()).Errorf("Failed to create target request: %v", err)
+		h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeInternal, "failed to create target request: %v", err))
 		return
 	}
+	targetReq.Body = proxy.LimitUpload(r, targetReq.Body)
 
-	logx.Errorf("create target request response targetReq: %v", targetReq)
-
-	// 复制请求头
-	for key, values := range r.Header {
-		// 跳过内部使用的头
-		if key == "clientId" || key == "appName" {
-			continue
-		}
-		for _, value := range values {
-			targetReq.Header.Add(key, value)
-		}
-	}
-
-	// 复制查询参数
-	if r.URL.RawQuery != "" {
-		targetReq.URL.RawQuery = r.URL.RawQuery
-	}
-
-	logx.Infof("forward request: %v", targetReq.URL.RawQuery)
+	logx.WithContext(r.Context()).Debugf("Created target request: %s %s", targetReq.Method, targetReq.URL)
+	logx.WithContext(r.Context()).Infof("forward request: %v", targetReq.URL.RawQuery)
 
 	// 发送请求
-	resp, err := client.Do(targetReq)
+	resp, err := h.client.Do(targetReq)
 	if err != nil {
-		logx.Errorf("Failed to forward request: %v", err)
-		h.sendError(w, fmt.Sprintf("Failed to forward request: %v", err), http.StatusBadGateway)
+		logx.WithContext(r.Context()).Errorf("Failed to forward request: %v", err)
+		h.errWriter.Write(w, r, proxy.UpstreamError(ctx, err))
 		return
 	}
 	defer resp.Body.Close()
@@ -133,17 +123,19 @@ func (h *DynamicProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 			w.Header().Add(key, value)
 		}
 	}
+	h.headerPolicy.ApplyResponse(w.Header(), r)
 
 	// 复制响应状态码和内容
 	w.WriteHeader(resp.StatusCode)
 
-	// 复制响应体
+	// 复制响应体，配置了带宽限制时按下载速率读取
+	download := proxy.LimitDownload(r, resp.Body)
 	buf := make([]byte, 32*1024) // 32KB buffer
 	for {
-		n, err := resp.Body.Read(buf)
+		n, err := download.Read(buf)
 		if n > 0 {
 			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
-				logx.Errorf("Failed to write response: %v", writeErr)
+				logx.WithContext(r.Context()).Errorf("Failed to write response: %v", writeErr)
 				break
 			}
 		}
@@ -152,30 +144,69 @@ func (h *DynamicProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	logx.Infof("Successfully handled dynamic proxy request: %s %s -> %d", r.Method, r.URL.Path, resp.StatusCode)
+	logx.WithContext(r.Context()).Infof("Successfully handled dynamic proxy request: %s %s -> %d", r.Method, r.URL.Path, resp.StatusCode)
+}
+
+// newTargetRequest 构建转发到隧道端口的请求，body 为已读取的请求体，r.Body 已重置为其副本
+func (h *DynamicProxyHandler) newTargetRequest(ctx context.Context, r *http.Request, targetURL string, body []byte) (*http.Request, error) {
+	targetReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL+r.URL.Path, r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		targetReq.ContentLength = int64(len(body))
+	}
+
+	// 复制请求头
+	for key, values := range r.Header {
+		// 跳过内部使用的头
+		if key == "clientId" || key == "appName" {
+			continue
+		}
+		for _, value := range values {
+			targetReq.Header.Add(key, value)
+		}
+	}
+	if !h.proxyConfig.Headers.PassThrough {
+		proxy.RestrictHeaders(targetReq.Header)
+	}
+	// 隧道另一端是用户本地环境，不向其转发凭证类请求头；策略中显式设置的头不受影响
+	proxy.StripSensitiveHeaders(targetReq.Header)
+	h.forwarded.Apply(targetReq.Header, r)
+	h.headerPolicy.ApplyRequest(targetReq.Header, r)
+	if h.forwarded.PreserveHost() {
+		targetReq.Host = r.Host
+	}
+
+	// 复制查询参数
+	if r.URL.RawQuery != "" {
+		targetReq.URL.RawQuery = r.URL.RawQuery
+	}
+	return targetReq, nil
 }
 
 // HealthCheck 健康检查
 func (h *DynamicProxyHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	r = proxy.WithDefaultClientIDExtractor(r, h.clientID)
 	ctx := r.Context()
 
 	// 读取请求体
 	var body []byte
 	if r.Method != "GET" {
-		// 使用 io.ReadAll 读取完整的请求体，但限制最大大小为 10MB 防止内存问题
-		const maxBodySize = 100 * 1024 * 1024 // 10MB
-		limitReader := io.LimitReader(r.Body, maxBodySize)
+		// 使用 io.ReadAll 读取完整的请求体，但按路由与请求方法的请求体上限限制最大大小防止内存问题
+		maxBodySize := bodySizeLimit(r, h.proxyConfig)
+		limitReader := io.LimitReader(r.Body, maxBodySize+1)
 		var err error
 		body, err = io.ReadAll(limitReader)
 		if err != nil {
-			logx.Errorf("Failed to read request body in health check: %v", err)
+			logx.WithContext(r.Context()).Errorf("Failed to read request body in health check: %v", err)
 			h.sendHealthCheckResponse(w, false, 0, fmt.Sprintf("Failed to read request body: %v", err))
 			return
 		}
 
 		// 检查是否超出限制
-		if len(body) >= maxBodySize {
-			logx.Errorf("Health check request body too large, exceeds %d bytes", maxBodySize)
+		if int64(len(body)) > maxBodySize {
+			logx.WithContext(r.Context()).Errorf("Health check request body too large, exceeds %d bytes", maxBodySize)
 			h.sendHealthCheckResponse(w, false, 0, fmt.Sprintf("Request body too large, exceeds %d bytes", maxBodySize))
 			return
 		}
@@ -184,13 +215,13 @@ func (h *DynamicProxyHandler) HealthCheck(w http.ResponseWriter, r *http.Request
 		r.Body.Close()
 		r.Body = io.NopCloser(bytes.NewReader(body))
 
-		logx.Infof("Health check read request body: %d bytes", len(body))
+		logx.WithContext(r.Context()).Infof("Health check read request body: %d bytes", len(body))
 	}
 
-	// 从请求获取端口信息（GET请求从params获取，其他请求从body获取）
-	portResp, err := h.portManager.GetPortFromHeaders(ctx, r.Method, r.Header, r.URL.Query(), body)
+	// 从请求获取端口信息，clientId 按提取规则查找
+	portResp, err := h.portManager.GetPortForRequest(ctx, r)
 	if err != nil {
-		logx.Errorf("Health check failed to get port: %v", err)
+		logx.WithContext(r.Context()).Errorf("Health check failed to get port: %v", err)
 		h.sendHealthCheckResponse(w, false, 0, fmt.Sprintf("Failed to get port: %v", err))
 		return
 	}
@@ -199,18 +230,19 @@ func (h *DynamicProxyHandler) HealthCheck(w http.ResponseWriter, r *http.Request
 	targetURL := h.portManager.BuildTargetURL(portResp)
 	healthURL := targetURL + "/health"
 
-	// 创建HTTP客户端
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	// 发送健康检查请求
+	// 复用转发客户端发送健康检查请求，最多等待 10 秒
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
 	start := time.Now()
-	resp, err := client.Get(healthURL)
+	var resp *http.Response
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err == nil {
+		resp, err = h.client.Do(req)
+	}
 	duration := time.Since(start)
 
 	if err != nil {
-		logx.Errorf("Health check failed: %v", err)
+		logx.WithContext(r.Context()).Errorf("Health check failed: %v", err)
 		h.sendHealthCheckResponse(w, false, duration, fmt.Sprintf("Health check failed: %v", err))
 		return
 	}
@@ -246,19 +278,19 @@ func (h *DynamicProxyHandler) sendHealthCheckResponse(w http.ResponseWriter, hea
 	json.NewEncoder(w).Encode(response)
 }
 
-// sendError 发送错误响应
-func (h *DynamicProxyHandler) sendError(w http.ResponseWriter, message string, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"code":      "PROXY_ERROR",
-		"message":   message,
-		"timestamp": time.Now().UTC(),
-	})
+// bodySizeLimit 返回缓存请求体的上限，经路由处理的请求按路由与请求方法的限制，否则按全局 body.max_size
+func bodySizeLimit(r *http.Request, cfg *config.ProxyConfig) int64 {
+	if size := proxy.MaxBodySize(r); size > 0 {
+		return size
+	}
+	if cfg.Body.MaxSize > 0 {
+		return cfg.Body.MaxSize
+	}
+	return config.DefaultMaxBodySize
 }
 
-// Close 关闭处理器
+// Close 关闭处理器，端口管理器由创建方关闭
 func (h *DynamicProxyHandler) Close() error {
-	// 目前没有需要清理的资源
+	h.client.CloseIdleConnections()
 	return nil
 }
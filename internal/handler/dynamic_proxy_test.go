@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/proxytest"
+)
+
+func TestDynamicProxyForwardsToTunnel(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	tunnels.AddTunnel(testClientID, indexer)
+	h := newTestDynamicProxyHandler(t, proxytest.NewProxyConfig(tunnels))
+
+	w := serve(h, http.MethodGet, testSearchPath+"?clientId="+testClientID+"&q=foo", "", http.Header{
+		"Authorization": {"Bearer secret"},
+	})
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	echo := proxytest.DecodeEcho(t, w.Body.Bytes())
+	assert.Equal(t, "indexer", echo.Upstream)
+	assert.Equal(t, testSearchPath, echo.Path)
+	assert.Equal(t, "clientId="+testClientID+"&q=foo", echo.Query)
+	assert.Empty(t, indexer.LastRequest().Header.Get("Authorization"), "credentials must not be forwarded to the tunnel")
+	assert.Equal(t, []string{testClientID}, tunnels.Lookups())
+}
+
+func TestDynamicProxyForwardsBody(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	tunnels.AddTunnel(testClientID, indexer)
+	h := newTestDynamicProxyHandler(t, proxytest.NewProxyConfig(tunnels))
+
+	body := `{"clientId":"` + testClientID + `","query":"foo"}`
+	w := serve(h, http.MethodPost, testSearchPath, body, http.Header{"Content-Type": {"application/json"}})
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, body, proxytest.DecodeEcho(t, w.Body.Bytes()).Body)
+	assert.Equal(t, http.MethodPost, indexer.LastRequest().Method)
+}
+
+func TestDynamicProxyCachesPort(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	tunnels.AddTunnel(testClientID, proxytest.NewUpstream(t, "indexer"))
+	h := newTestDynamicProxyHandler(t, proxytest.NewProxyConfig(tunnels))
+
+	for i := 0; i < 2; i++ {
+		w := serve(h, http.MethodGet, testSearchPath+"?clientId="+testClientID, "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	assert.Len(t, tunnels.Lookups(), 1)
+}
+
+func TestDynamicProxyPortLookupErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		fault  *proxytest.Fault
+		status int
+		code   errs.Code
+	}{
+		{"missing client id", testSearchPath, nil, http.StatusBadRequest, errs.CodeClientIDMissing},
+		{"unknown client", testSearchPath + "?clientId=unknown", nil, http.StatusNotFound, errs.CodeTunnelNotFound},
+		{"tunnel manager error", testSearchPath + "?clientId=" + testClientID, &proxytest.Fault{Status: http.StatusInternalServerError}, http.StatusServiceUnavailable, errs.CodeTunnelManagerUnavailable},
+		{"tunnel manager reset", testSearchPath + "?clientId=" + testClientID, &proxytest.Fault{Reset: true}, http.StatusServiceUnavailable, errs.CodeTunnelManagerUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tunnels := proxytest.NewTunnelManager(t)
+			indexer := proxytest.NewUpstream(t, "indexer")
+			tunnels.AddTunnel(testClientID, indexer)
+			if tt.fault != nil {
+				tunnels.InjectFault(*tt.fault)
+			}
+			h := newTestDynamicProxyHandler(t, proxytest.NewProxyConfig(tunnels))
+
+			w := serve(h, http.MethodGet, tt.target, "", nil)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.code, proxytest.ErrorCode(w))
+			assert.Empty(t, indexer.Requests())
+		})
+	}
+}
+
+func TestDynamicProxyUpstreamFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		fault  proxytest.Fault
+		status int
+		code   errs.Code
+	}{
+		{"reset", proxytest.Fault{Reset: true}, http.StatusBadGateway, errs.CodeUpstreamFailed},
+		{"timeout", proxytest.Fault{Delay: time.Second}, http.StatusGatewayTimeout, errs.CodeTimeout},
+		{"server error", proxytest.Fault{Status: http.StatusServiceUnavailable}, http.StatusServiceUnavailable, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tunnels := proxytest.NewTunnelManager(t)
+			indexer := proxytest.NewUpstream(t, "indexer")
+			tunnels.AddTunnel(testClientID, indexer)
+			indexer.InjectFault(tt.fault)
+			cfg := proxytest.NewProxyConfig(tunnels)
+			cfg.Timeouts.Default.Total = 100 * time.Millisecond
+			h := newTestDynamicProxyHandler(t, cfg)
+
+			w := serve(h, http.MethodGet, testSearchPath+"?clientId="+testClientID, "", nil)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.code, proxytest.ErrorCode(w))
+		})
+	}
+}
+
+func TestDynamicProxyHealthCheckUsesSharedTransport(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	tunnels.AddTunnel(testClientID, indexer)
+	cfg := proxytest.Validate(t, proxytest.NewProxyConfig(tunnels))
+	transports := proxytest.NewTransports(t, cfg)
+	h := NewDynamicProxyHandler(cfg, proxytest.NewPortManager(t, cfg), transports)
+	t.Cleanup(func() { h.Close() })
+
+	w := serve(http.HandlerFunc(h.HealthCheck), http.MethodGet, "/health?clientId="+testClientID, "", nil)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"ok"`)
+	assert.Equal(t, "/health", indexer.LastRequest().Path)
+	// 健康检查请求经由共享连接池发出
+	require.NotEmpty(t, transports.Stats())
+	assert.Positive(t, transports.Stats()[0].Dials)
+}
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/zeromicro/go-zero/core/search"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/logic"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// 无法转发时的策略
+const (
+	explainStrategyFanOut = "fan_out" // 聚合检索路由
+	explainStrategyNone   = "none"    // 不会转发，如未命中路由或请求被拒绝
+)
+
+// dynamicPortPlaceholder 动态转发地址中端口的占位符，端口由端口管理器在请求时分配
+const dynamicPortPlaceholder = "<port>"
+
+// Explanation 请求的转发决策
+type Explanation struct {
+	Method         string      `json:"method"`
+	Path           string      `json:"path"`
+	Route          string      `json:"route,omitempty"`            // 命中的路由
+	ClientID       string      `json:"client_id,omitempty"`        // 按路由提取规则找到的 clientId
+	ClientIDSource string      `json:"client_id_source,omitempty"` // clientId 的来源
+	Shadow         string      `json:"shadow,omitempty"`           // 命中的影子规则
+	Split          string      `json:"split,omitempty"`            // 命中的分流规则与后端
+	Rule           string      `json:"rule,omitempty"`             // 命中的转发规则
+	Strategy       string      `json:"strategy"`                   // 转发目标类型: url, dynamic, static, route, fan_out, none
+	Fallback       []string    `json:"fallback,omitempty"`         // 失败后依次回退的策略
+	Port           int         `json:"port,omitempty"`             // 端口管理器分配的隧道端口，仅调试请求查询
+	URL            string      `json:"url,omitempty"`              // 转发地址
+	Headers        http.Header `json:"headers,omitempty"`          // 转发的请求头
+	Notes          []string    `json:"notes,omitempty"`
+
+	resolvePort bool // 动态转发时向端口管理器查询实际端口，否则以占位符表示
+}
+
+func (e *Explanation) note(format string, args ...interface{}) {
+	e.Notes = append(e.Notes, fmt.Sprintf(format, args...))
+}
+
+// Explainer 离线解析请求的转发决策，处理器与路由查找树只在创建时构建一次
+type Explainer struct {
+	handler     *SmartProxyHandler
+	portManager *proxy.PortManager
+	transports  *proxy.TransportRegistry
+}
+
+// NewExplainer 按配置创建请求解析器，用完后需调用 Close
+func NewExplainer(cfg *config.ProxyConfig) *Explainer {
+	// 解析过程不写影子流量比对文件与录制文件
+	explainConfig := *cfg
+	explainConfig.Shadow.DiffFile = ""
+	explainConfig.Record.Enabled = false
+	portManager := proxy.NewPortManagerWithConfig(cfg.PortManager)
+	transports := proxy.NewTransportRegistry(cfg.Transport)
+	return &Explainer{
+		handler:     NewSmartProxyHandler(&explainConfig, portManager, transports),
+		portManager: portManager,
+		transports:  transports,
+	}
+}
+
+// Explain 按与转发相同的流程解析请求命中的路由、策略、规则、转发地址与请求头，不发送任何请求
+func (x *Explainer) Explain(r *http.Request) (*Explanation, error) {
+	return x.handler.Explain(r)
+}
+
+// Close 关闭处理器、端口管理器与连接池
+func (x *Explainer) Close() error {
+	err := x.handler.Close()
+	x.portManager.Close()
+	x.transports.Close()
+	return err
+}
+
+// Explain 解析请求的转发决策，依次经过路由匹配、路由请求体处理、影子规则、分流、转发规则与默认策略
+func (h *SmartProxyHandler) Explain(r *http.Request) (*Explanation, error) {
+	e := newExplanation(r)
+
+	if fanOut := h.proxyConfig.FanOut; fanOut.Enabled && r.URL.Path == fanOut.Path && logic.HasFanOutClientIDs(r, fanOut.ClientIDsParam) {
+		e.Strategy = explainStrategyFanOut
+		e.note("aggregated across clientIds in %s and fan_out.targets, each backend receives %s", fanOut.ClientIDsParam, fanOut.Path)
+		return e, nil
+	}
+
+	route, ok := h.matchRoute(r)
+	if !ok {
+		e.note("no route matches %s %s, the gateway returns 404", r.Method, r.URL.Path)
+		return e, nil
+	}
+	e.Route = routeName(route)
+
+	// 与 RouteHandler 相同的请求处理
+	limit := proxy.NewBodyLimit(h.proxyConfig.Body, route.Body)
+	if err := limit.Apply(r); err != nil {
+		e.note("request rejected: %v", err)
+		return e, nil
+	}
+	if err := proxy.NewBodyTransformer(route.BodyTransforms).Apply(r); err != nil {
+		e.note("request rejected by body_transforms: %v", err)
+		return e, nil
+	}
+	r = proxy.WithFallbackChain(r, proxy.NewFallbackChain(route.Fallback))
+	r = proxy.WithBodyLimit(r, limit)
+	r = proxy.WithClientIDExtractor(r, proxy.NewClientIDExtractor(h.proxyConfig.ClientID, route.ClientID))
+	return e, h.explainRequest(e, r)
+}
+
+// explainRequest 解析已完成路由处理的请求的转发决策
+func (h *SmartProxyHandler) explainRequest(e *Explanation, r *http.Request) error {
+	r = proxy.WithClientIP(r, h.forwarded.ClientIP(r))
+	e.ClientID, e.ClientIDSource = proxy.PeekClientIDWithSource(r)
+
+	if rule := proxy.MatchShadowRule(h.shadowRules, r); rule != nil {
+		e.Shadow = fmt.Sprintf("%s (sample_rate=%g) -> %s", rule.Name, rule.SampleRate, rule.URL)
+	}
+
+	if split := proxy.MatchTrafficSplit(h.trafficSplits, r); split != nil {
+		key := h.stickyKey(r, split.Sticky)
+		backend := split.Pick(key)
+		e.Split = split.Name + "/" + backend.Name
+		if key == "" {
+			weights := make([]string, len(split.Backends))
+			for i, b := range split.Backends {
+				weights[i] = fmt.Sprintf("%s=%d", b.Name, b.Weight)
+			}
+			e.note("backend is picked at random by weight (%s), showing %s", strings.Join(weights, ", "), backend.Name)
+		}
+		if split.Mirror.URL != "" {
+			e.note("request is mirrored to %s", split.Mirror.URL)
+		}
+		return h.explainTarget(e, r, backend.Target)
+	}
+
+	if rule, ok := h.ruleEngine.Match(r); ok {
+		e.Rule = rule.Name
+		return h.explainTarget(e, proxy.WithHeaderPolicy(r, rule.Headers), rule.Target)
+	}
+
+	strategies := proxy.FallbackChainFromContext(r).Strategies(h.defaultStrategy(r))
+	e.Fallback = strategies[1:]
+	if strategies[0] == config.StrategyStatic && h.staticProxyHandler != nil {
+		return h.explainTarget(e, r, config.ForwardTargetConfig{Type: config.TargetTypeStatic})
+	}
+	return h.explainTarget(e, r, config.ForwardTargetConfig{Type: config.TargetTypeDynamic})
+}
+
+func newExplanation(r *http.Request) *Explanation {
+	return &Explanation{Method: r.Method, Path: r.URL.Path, Strategy: explainStrategyNone}
+}
+
+// routeName 返回路由的描述，有名称时附带名称
+func routeName(route config.RouteConfig) string {
+	if route.Name != "" {
+		return route.Name + " (" + route.PathPrefix + ")"
+	}
+	return route.PathPrefix
+}
+
+// newRouteTree 按 go-zero 的路由规则建立路由查找树，节点为路由下标
+// 重复的路由在启动时即失败，由 validate 报告，这里保留先出现的路由
+func newRouteTree(routes []config.RouteConfig) *search.Tree {
+	tree := search.NewTree()
+	for i, route := range routes {
+		tree.Add(path.Clean(route.PathPrefix), i)
+	}
+	return tree
+}
+
+// matchRoute 按 go-zero 的路由规则查找请求命中的路由，所有路由注册的请求方法相同
+func (h *SmartProxyHandler) matchRoute(r *http.Request) (config.RouteConfig, bool) {
+	if !slices.Contains(proxyMethods, r.Method) {
+		return config.RouteConfig{}, false
+	}
+	result, ok := h.routeTree.Search(path.Clean(r.URL.Path))
+	if !ok {
+		return config.RouteConfig{}, false
+	}
+	return h.proxyConfig.Routes[result.Item.(int)], true
+}
+
+// explainTarget 构建转发到 target 的请求，记录转发地址与请求头
+func (h *SmartProxyHandler) explainTarget(e *Explanation, r *http.Request, target config.ForwardTargetConfig) error {
+	e.Strategy = target.Type
+
+	var targetReq *http.Request
+	var err error
+	switch target.Type {
+	case config.TargetTypeURL:
+		targetReq, err = h.newURLRequest(r.Context(), r, target.URL, nil)
+	case config.TargetTypeStatic:
+		if h.staticProxyHandler == nil {
+			e.note("no forward_url configured, the gateway returns PROXY_NOT_CONFIGURED")
+			return nil
+		}
+		targetReq, err = h.staticProxyHandler.proxyLogic.buildTargetRequest(r.Context(), r)
+	case config.TargetTypeRoute:
+		routeHandler, ok := h.routeHandlers[target.Route]
+		if !ok {
+			e.note("route %s not found, the gateway returns PROXY_NOT_CONFIGURED", target.Route)
+			return nil
+		}
+		e.note("forwarded through named route %s", target.Route)
+		targetReq, err = routeHandler.proxyLogic.buildTargetRequest(r.Context(), r)
+	case config.TargetTypeDynamic:
+		return h.dynamicProxyHandler.explain(e, r)
+	default:
+		e.note("unsupported target type %s", target.Type)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	e.URL = targetReq.URL.String()
+	e.Headers = explainHeaders(targetReq)
+	return nil
+}
+
+// explain 构建转发到隧道的请求，未要求查询端口时端口以占位符表示
+func (h *DynamicProxyHandler) explain(e *Explanation, r *http.Request) error {
+	r = proxy.WithDefaultClientIDExtractor(r, h.clientID)
+	clientID := proxy.PeekClientID(r)
+	if clientID == "" {
+		e.note("clientId not found (looked in: %s), the gateway returns PROXY_CLIENT_ID_MISSING", proxy.ClientIDSources(r))
+	}
+
+	portResp := &proxy.PortResponse{}
+	if e.resolvePort && clientID != "" {
+		resolved, err := h.portManager.GetPortForRequest(r.Context(), r)
+		if err != nil {
+			e.note("port manager %s failed for clientId %s: %v", h.proxyConfig.PortManager.URL, clientID, err)
+		} else {
+			portResp = resolved
+			e.Port = resolved.Port
+		}
+	} else if clientID != "" {
+		e.note("port is assigned by port manager %s for clientId %s", h.proxyConfig.PortManager.URL, clientID)
+	}
+
+	targetURL := h.portManager.BuildTargetURL(portResp)
+	targetReq, err := h.newTargetRequest(r.Context(), r, targetURL, nil)
+	if err != nil {
+		return err
+	}
+	e.URL = targetReq.URL.String()
+	e.Headers = explainHeaders(targetReq)
+	if e.Port != 0 {
+		return nil
+	}
+	base := strings.TrimSuffix(targetURL, ":0")
+	e.URL = strings.Replace(e.URL, targetURL, base+":"+dynamicPortPlaceholder, 1)
+	if host := e.Headers.Get("Host"); strings.HasSuffix(host, ":0") {
+		e.Headers.Set("Host", strings.TrimSuffix(host, ":0")+":"+dynamicPortPlaceholder)
+	}
+	return nil
+}
+
+// explainHeaders 返回转发的请求头，补充实际发送的 Host
+func explainHeaders(targetReq *http.Request) http.Header {
+	headers := targetReq.Header.Clone()
+	if headers.Get("Host") == "" {
+		host := targetReq.Host
+		if host == "" {
+			host = targetReq.URL.Host
+		}
+		headers.Set("Host", host)
+	}
+	return headers
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/logic"
+	"github.com/zgsm-ai/codebase-indexer/internal/response"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// FanOutHandler 聚合检索处理器
+// 将携带 clientId 列表的检索请求并行转发到多个 clientId 的隧道和配置的静态目标，合并去重后返回
+type FanOutHandler struct {
+	cfg            config.FanOutConfig
+	fanOutLogic    *logic.FanOutLogic
+	bodyLimit      *proxy.BodyLimit
+	hasPortManager bool // 未配置端口管理器时不接受 clientId 后端
+	errWriter      *proxy.ErrorWriter
+}
+
+// NewFanOutHandler 创建聚合检索处理器，请求体按全局 body 限制读取
+func NewFanOutHandler(cfg *config.ProxyConfig, portManager *proxy.PortManager, transports *proxy.TransportRegistry, errWriter *proxy.ErrorWriter) *FanOutHandler {
+	return &FanOutHandler{
+		cfg:            cfg.FanOut,
+		fanOutLogic:    logic.NewFanOutLogic(cfg, portManager, transports),
+		bodyLimit:      proxy.NewBodyLimit(cfg.Body, config.BodyConfig{}),
+		hasPortManager: portManager != nil && cfg.PortManager.URL != "",
+		errWriter:      errWriter,
+	}
+}
+
+// Wrap 返回注册在检索路径上的处理器，携带 clientId 列表参数的请求执行聚合检索，其余请求交给 next
+// next 为 nil 时检索路径没有对应的转发路由，所有请求都按聚合请求处理
+func (h *FanOutHandler) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if next != nil && !logic.HasFanOutClientIDs(r, h.cfg.ClientIDsParam) {
+			next(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+}
+
+// ServeHTTP 处理聚合检索请求
+func (h *FanOutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil && r.Method != http.MethodGet {
+		// 请求体超限时在读取请求体之前返回
+		if err := h.bodyLimit.Apply(r); err != nil {
+			logx.WithContext(r.Context()).Errorf("Rejected fan-out request body: %v", err)
+			h.errWriter.Write(w, r, err)
+			return
+		}
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			h.errWriter.Write(w, r, proxy.BodyReadError(err))
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	clientIDs := logic.ParseFanOutClientIDs(r.URL.Query(), body, h.cfg.ClientIDsParam)
+	if len(clientIDs)+len(h.cfg.Targets) == 0 {
+		h.errWriter.Write(w, r, errs.NewProxyError(errs.CodeClientIDMissing, h.cfg.ClientIDsParam+" is required"))
+		return
+	}
+	// 未配置端口管理器时 clientId 后端必然失败，在扇出之前拒绝
+	if len(clientIDs) > 0 && !h.hasPortManager {
+		h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeNotConfigured, "%s requires a port manager", h.cfg.ClientIDsParam))
+		return
+	}
+	if len(clientIDs)+len(h.cfg.Targets) > h.cfg.MaxBackends {
+		h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeBadRequest, "too many backends, at most %d allowed", h.cfg.MaxBackends))
+		return
+	}
+
+	backends := make([]logic.FanOutBackend, 0, len(clientIDs)+len(h.cfg.Targets))
+	for _, clientID := range clientIDs {
+		backends = append(backends, logic.FanOutBackend{Source: "client:" + clientID, ClientID: clientID})
+	}
+	for _, target := range h.cfg.Targets {
+		backends = append(backends, logic.FanOutBackend{Source: "static:" + target, BaseURL: target})
+	}
+
+	logx.WithContext(r.Context()).Infof("Fan-out search %s %s to %d backends", r.Method, r.URL.Path, len(backends))
+	result := h.fanOutLogic.Search(r.Context(), r, backends, body)
+
+	succeeded := 0
+	for _, source := range result.Sources {
+		if source.Success {
+			succeeded++
+		}
+	}
+	if succeeded == 0 {
+		h.errWriter.Write(w, r, errs.NewProxyError(errs.CodeUpstreamFailed, "all fan-out backends failed"))
+		return
+	}
+	if result.Partial {
+		w.Header().Set("X-Partial-Results", "true")
+	}
+
+	logx.WithContext(r.Context()).Infof("Fan-out search completed: %d/%d backends succeeded, %d results", succeeded, len(backends), len(result.List))
+	response.JsonCtx(r.Context(), w, result)
+}
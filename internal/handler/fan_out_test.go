@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/proxytest"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+func TestFanOutRejectsBeforeDispatch(t *testing.T) {
+	var requests atomic.Int32
+	static := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"list":[]}`))
+	}))
+	t.Cleanup(static.Close)
+
+	tests := []struct {
+		name   string
+		setup  func(cfg *config.ProxyConfig)
+		method string
+		target string
+		body   string
+		status int
+		code   errs.Code
+	}{
+		{
+			name:   "query clientIds without port manager",
+			method: http.MethodGet,
+			target: "/search?clientIds=client-a,client-b",
+			status: http.StatusNotImplemented,
+			code:   errs.CodeNotConfigured,
+		},
+		{
+			name:   "body clientIds without port manager",
+			method: http.MethodPost,
+			target: "/search",
+			body:   `{"clientIds":["client-a"]}`,
+			status: http.StatusNotImplemented,
+			code:   errs.CodeNotConfigured,
+		},
+		{
+			name:   "body exceeds max size",
+			setup:  func(cfg *config.ProxyConfig) { cfg.Body.MaxSize = 64 },
+			method: http.MethodPost,
+			target: "/search",
+			body:   `{"query":"` + strings.Repeat("x", 64) + `"}`,
+			status: http.StatusRequestEntityTooLarge,
+			code:   errs.CodeBodyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.ProxyConfig{FanOut: config.FanOutConfig{
+				Enabled:        true,
+				Path:           "/search",
+				ClientIDsParam: "clientIds",
+				Targets:        []string{static.URL},
+				Timeout:        time.Second,
+				MaxBackends:    20,
+			}}
+			if tt.setup != nil {
+				tt.setup(cfg)
+			}
+			transports := proxy.NewTransportRegistry(cfg.Transport)
+			t.Cleanup(func() { transports.Close() })
+			h := NewFanOutHandler(cfg, nil, transports, proxy.NewErrorWriter(cfg.Errors))
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), string(tt.code))
+			assert.Zero(t, requests.Load())
+		})
+	}
+}
+
+func TestFanOutBackendHeaders(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	tunnel := proxytest.NewUpstream(t, "tunnel")
+	static := proxytest.NewUpstream(t, "static")
+	tunnels.AddTunnel(testClientID, tunnel)
+	for _, upstream := range []*proxytest.Upstream{tunnel, static} {
+		upstream.Handle(testSearchPath, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"list":[]}`))
+		})
+	}
+	cfg := proxytest.NewProxyConfig(tunnels)
+	cfg.Headers.Request = config.HeaderOpsConfig{Set: map[string]string{"X-Gateway": "costrict"}}
+	cfg.FanOut = config.FanOutConfig{
+		Enabled:        true,
+		Path:           testSearchPath,
+		ClientIDsParam: "clientIds",
+		Targets:        []string{static.URL()},
+	}
+	proxytest.Validate(t, cfg)
+	transports := proxytest.NewTransports(t, cfg)
+	h := NewFanOutHandler(cfg, proxytest.NewPortManager(t, cfg), transports, proxy.NewErrorWriter(cfg.Errors))
+
+	w := serve(h, http.MethodGet, testSearchPath+"?clientIds="+testClientID, "", http.Header{
+		"Authorization":       {"Bearer secret"},
+		"Cookie":              {"session=1"},
+		proxy.RequestIDHeader: {"req-1"},
+	})
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	tunnelReq, staticReq := tunnel.LastRequest(), static.LastRequest()
+	require.NotNil(t, tunnelReq)
+	require.NotNil(t, staticReq)
+	assert.Empty(t, tunnelReq.Header.Get("Authorization"), "tunnel backends must not receive credentials")
+	assert.Empty(t, tunnelReq.Header.Get("Cookie"))
+	assert.Equal(t, "Bearer secret", staticReq.Header.Get("Authorization"))
+	for _, req := range []*proxytest.Request{tunnelReq, staticReq} {
+		assert.Equal(t, "req-1", req.Header.Get(proxy.RequestIDHeader))
+		assert.Equal(t, "costrict", req.Header.Get("X-Gateway"))
+		assert.NotEmpty(t, req.Header.Get(proxy.HeaderXForwardedFor))
+	}
+}
+
+func TestFanOutWrapRoutesByClientIDs(t *testing.T) {
+	static := proxytest.NewUpstream(t, "static")
+	static.Handle(testSearchPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"list":[{"filePath":"a.go","startLine":1,"endLine":2,"score":0.5}]}`))
+	})
+	cfg := &config.ProxyConfig{FanOut: config.FanOutConfig{
+		Enabled:        true,
+		Path:           testSearchPath,
+		ClientIDsParam: "clientIds",
+		Targets:        []string{static.URL()},
+		Timeout:        time.Second,
+		MaxBackends:    20,
+	}}
+	h := NewFanOutHandler(cfg, nil, proxytest.NewTransports(t, cfg), proxy.NewErrorWriter(cfg.Errors))
+	var routed string
+	next := h.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		routed = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		fanOut bool
+	}{
+		{"plain search", http.MethodGet, testSearchPath + "?query=foo", "", false},
+		{"empty clientIds query", http.MethodGet, testSearchPath + "?clientIds=", "", true},
+		{"clientIds in body", http.MethodPost, testSearchPath, `{"clientIds":[],"query":"foo"}`, true},
+		{"body without clientIds", http.MethodPost, testSearchPath, `{"query":"foo"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routed = ""
+			before := len(static.Requests())
+			w := serve(next, tt.method, tt.target, tt.body, nil)
+
+			if !tt.fanOut {
+				assert.Equal(t, http.StatusNoContent, w.Code)
+				assert.Equal(t, tt.body, routed, "routed request must keep its body")
+				assert.Len(t, static.Requests(), before)
+				return
+			}
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), `"source":"static:`+static.URL()+`"`)
+			assert.Len(t, static.Requests(), before+1)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/proxytest"
+)
+
+const (
+	testClientID   = "client-a"
+	testSearchPath = "/codebase-indexer/api/v1/search/semantic"
+)
+
+func TestMain(m *testing.M) {
+	logx.Disable()
+	os.Exit(m.Run())
+}
+
+func newTestDynamicProxyHandler(t *testing.T, cfg *config.ProxyConfig) *DynamicProxyHandler {
+	t.Helper()
+	h := NewDynamicProxyHandler(proxytest.Validate(t, cfg), proxytest.NewPortManager(t, cfg), proxytest.NewTransports(t, cfg))
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func newTestSmartProxyHandler(t *testing.T, cfg *config.ProxyConfig) *SmartProxyHandler {
+	t.Helper()
+	h := NewSmartProxyHandler(proxytest.Validate(t, cfg), proxytest.NewPortManager(t, cfg), proxytest.NewTransports(t, cfg))
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func newTestMultiProxyHandler(t *testing.T, cfg *config.ProxyConfig) *MultiProxyHandler {
+	t.Helper()
+	h := NewMultiProxyHandler(proxytest.Validate(t, cfg), proxytest.NewTransports(t, cfg))
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// serve 以 httptest.ResponseRecorder 执行请求
+func serve(h http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, target, reader)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/logic"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// livezHandler 存活探针，进程能处理请求即返回 200，停机期间仍返回 200 以免被重启
+func livezHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealthJSON(w, http.StatusOK, map[string]string{"status": logic.ReadinessOK})
+	}
+}
+
+// readyzHandler 就绪探针，任一检查失败返回 503
+// 带 verbose 查询参数时返回各项检查的详细结果与转发连接池统计，供运维排查
+func readyzHandler(readiness *logic.ReadinessLogic, transports *proxy.TransportRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := readiness.Check(r.Context())
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		if _, verbose := r.URL.Query()["verbose"]; verbose {
+			report.Pools = transports.Stats()
+		} else {
+			report.Checks = nil
+		}
+		writeHealthJSON(w, status, report)
+	}
+}
+
+// writeHealthJSON 写出健康检查 JSON 响应
+func writeHealthJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
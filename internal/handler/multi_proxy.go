@@ -9,45 +9,82 @@ import (
 
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
 )
 
 // MultiProxyHandler 多路由代理处理器
 type MultiProxyHandler struct {
 	routeHandlers map[string]*ProxyHandler
 	routeConfigs  []config.RouteConfig
+	errorPages    map[string]*proxy.ErrorPages        // 按路径前缀索引的路由错误页
+	timeouts      map[string]*proxy.TimeoutBudget     // 按路径前缀索引的路由超时预算
+	bodyLimits    map[string]*proxy.BodyLimit         // 按路径前缀索引的路由请求体限制
+	compressors   map[string]*proxy.Compressor        // 按路径前缀索引的路由压缩配置
+	transformers  map[string]*proxy.BodyTransformer   // 按路径前缀索引的路由请求体转换
+	clientIDs     map[string]*proxy.ClientIDExtractor // 按路径前缀索引的路由 clientId 提取规则
+	bandwidths    map[string]*proxy.BandwidthLimit    // 按路径前缀索引的路由带宽限制
+	faults        *proxy.FaultInjector                // 未启用故障注入时为 nil
+	forwarded     *proxy.ForwardedHeaders
+	errWriter     *proxy.ErrorWriter
 	mu            sync.RWMutex
 }
 
 // NewMultiProxyHandler 创建多路由代理处理器
-func NewMultiProxyHandler(cfg *config.ProxyConfig) *MultiProxyHandler {
+func NewMultiProxyHandler(cfg *config.ProxyConfig, transports *proxy.TransportRegistry) *MultiProxyHandler {
 	handlers := make(map[string]*ProxyHandler)
+	errorPages := make(map[string]*proxy.ErrorPages)
+	timeouts := make(map[string]*proxy.TimeoutBudget)
+	bodyLimits := make(map[string]*proxy.BodyLimit)
+	compressors := make(map[string]*proxy.Compressor)
+	transformers := make(map[string]*proxy.BodyTransformer)
+	clientIDs := make(map[string]*proxy.ClientIDExtractor)
+	bandwidths := make(map[string]*proxy.BandwidthLimit)
 
 	for _, route := range cfg.Routes {
-		// 转换重写规则
-		rules := make([]RewriteRule, len(cfg.Rewrite.Rules))
-		for i, rule := range cfg.Rewrite.Rules {
-			rules[i] = RewriteRule{From: rule.From, To: rule.To}
-		}
-
 		singleConfig := &ProxyConfig{
-			Mode:    cfg.Mode,
-			Target:  TargetConfig{URL: route.Target.URL, Timeout: route.Target.Timeout},
-			Rewrite: RewriteConfig{Enabled: cfg.Rewrite.Enabled, Rules: rules},
-			Headers: HeadersConfig{PassThrough: cfg.Headers.PassThrough, Exclude: cfg.Headers.Exclude, Override: cfg.Headers.Override},
+			Mode:     cfg.Mode,
+			Target:   TargetConfig{URL: route.Target.URL, Timeout: route.Target.Timeout},
+			Rewrite:  newRewriteConfig(cfg.Rewrite),
+			Headers:  newHeadersConfig(cfg, route.Headers),
+			Errors:   cfg.Errors,
+			Timeouts: cfg.Timeouts,
 		}
 
-		handlers[route.PathPrefix] = NewProxyHandler(singleConfig)
+		transports.Register(route.Target.URL, route.Transport)
+		handlers[route.PathPrefix] = NewProxyHandler(singleConfig, transports)
+		errorPages[route.PathPrefix] = newErrorPages(cfg, route, transports)
+		timeouts[route.PathPrefix] = proxy.NewTimeoutBudget(cfg.Timeouts, route.Target.Timeout, route.Timeouts)
+		bodyLimits[route.PathPrefix] = proxy.NewBodyLimit(cfg.Body, route.Body)
+		compressors[route.PathPrefix] = proxy.NewCompressor(cfg.Compression, route.Compression)
+		transformers[route.PathPrefix] = proxy.NewBodyTransformer(route.BodyTransforms)
+		clientIDs[route.PathPrefix] = proxy.NewClientIDExtractor(cfg.ClientID, route.ClientID)
+		bandwidths[route.PathPrefix] = proxy.NewBandwidthLimit(cfg.Bandwidth, route.Bandwidth)
 		logx.Infof("Registered route: %s -> %s", route.PathPrefix, route.Target.URL)
 	}
 
+	forwarded, err := proxy.NewForwardedHeaders(cfg.Headers.Forwarded)
+	logx.Must(err)
+
 	return &MultiProxyHandler{
 		routeHandlers: handlers,
 		routeConfigs:  cfg.Routes,
+		errorPages:    errorPages,
+		timeouts:      timeouts,
+		bodyLimits:    bodyLimits,
+		compressors:   compressors,
+		transformers:  transformers,
+		clientIDs:     clientIDs,
+		bandwidths:    bandwidths,
+		faults:        proxy.NewFaultInjector(cfg.FaultInjection),
+		forwarded:     forwarded,
+		errWriter:     proxy.NewErrorWriter(cfg.Errors),
 	}
 }
 
 // ServeHTTP 处理多路由代理请求
 func (h *MultiProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = proxy.WithClientIP(r, h.forwarded.ClientIP(r))
 	path := r.URL.Path
 
 	var matchedPrefix string
@@ -65,13 +102,51 @@ func (h *MultiProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.mu.RUnlock()
 
 	if handler == nil {
-		logx.Errorf("No route found for path: %s", path)
-		http.NotFound(w, r)
+		logx.WithContext(r.Context()).Errorf("No route found for path: %s", path)
+		h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeRouteNotFound, "no route found for path: %s", path))
 		return
 	}
 
-	logx.Infof("Routing request: %s -> %s (prefix: %s)", path, handler.proxyLogic.GetTargetURL(), matchedPrefix)
-	handler.ServeHTTP(w, r)
+	logx.WithContext(r.Context()).Infof("Routing request: %s -> %s (prefix: %s)", path, handler.proxyLogic.GetTargetURL(), matchedPrefix)
+	compressor := h.compressors[matchedPrefix]
+	w, finish := compressor.Compress(w, r)
+	defer finish()
+
+	limit := h.bodyLimits[matchedPrefix]
+	if err := limit.Apply(r); err != nil {
+		logx.WithContext(r.Context()).Errorf("Rejected request body: %v", err)
+		h.errWriter.Write(w, proxy.WithErrorPages(r, h.errorPages[matchedPrefix]), err)
+		return
+	}
+	if err := compressor.DecompressRequest(r); err != nil {
+		h.errWriter.Write(w, proxy.WithErrorPages(r, h.errorPages[matchedPrefix]), err)
+		return
+	}
+	if err := h.transformers[matchedPrefix].Apply(r); err != nil {
+		h.errWriter.Write(w, proxy.WithErrorPages(r, h.errorPages[matchedPrefix]), err)
+		return
+	}
+	r = proxy.WithTimeoutBudget(proxy.WithErrorPages(r, h.errorPages[matchedPrefix]), h.timeouts[matchedPrefix])
+	r = proxy.WithBodyLimit(r, limit)
+	r = proxy.WithClientIDExtractor(r, h.clientIDs[matchedPrefix])
+	r = proxy.WithBandwidthLimit(r, h.bandwidths[matchedPrefix])
+	r = proxy.WithFaultInjector(r, h.faults, h.routeConfig(matchedPrefix))
+	// 每个路由转发到固定目标，故障规则按 route 策略匹配
+	w, done := proxy.InjectFault(w, r, config.TargetTypeRoute)
+	if done {
+		return
+	}
+	handler.ServeHTTP(limit.LimitResponse(w, r, h.errWriter), r)
+}
+
+// routeConfig 返回路径前缀对应的路由配置
+func (h *MultiProxyHandler) routeConfig(prefix string) config.RouteConfig {
+	for _, route := range h.routeConfigs {
+		if route.PathPrefix == prefix {
+			return route
+		}
+	}
+	return config.RouteConfig{PathPrefix: prefix}
 }
 
 // HealthCheck 健康检查处理器
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/proxytest"
+)
+
+func TestMultiProxyLongestPrefix(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	search := proxytest.NewUpstream(t, "search")
+	cfg := proxytest.NewProxyConfig(tunnels,
+		proxytest.Route("/codebase-indexer", indexer),
+		proxytest.Route("/codebase-indexer/api/v1/search", search),
+	)
+	h := newTestMultiProxyHandler(t, cfg)
+
+	tests := []struct {
+		path     string
+		upstream string
+	}{
+		{testSearchPath, "search"},
+		{"/codebase-indexer/api/v1/files", "indexer"},
+	}
+
+	for _, tt := range tests {
+		w := serve(h, http.MethodGet, tt.path, "", nil)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		echo := proxytest.DecodeEcho(t, w.Body.Bytes())
+		assert.Equal(t, tt.upstream, echo.Upstream, tt.path)
+		assert.Equal(t, tt.path, echo.Path, tt.path)
+	}
+}
+
+func TestMultiProxyRouteNotFound(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	cfg := proxytest.NewProxyConfig(tunnels, proxytest.Route("/codebase-indexer", proxytest.NewUpstream(t, "indexer")))
+	h := newTestMultiProxyHandler(t, cfg)
+
+	w := serve(h, http.MethodGet, "/unknown", "", nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, errs.CodeRouteNotFound, proxytest.ErrorCode(w))
+}
+
+func TestMultiProxyUpstreamFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(u *proxytest.Upstream)
+		status int
+		code   errs.Code
+	}{
+		{"refused", func(u *proxytest.Upstream) { u.Close() }, http.StatusBadGateway, errs.CodeUpstreamRefused},
+		{"reset", func(u *proxytest.Upstream) { u.InjectFault(proxytest.Fault{Reset: true}) }, http.StatusBadGateway, errs.CodeUpstreamFailed},
+		{"timeout", func(u *proxytest.Upstream) { u.InjectFault(proxytest.Fault{Delay: time.Second}) }, http.StatusGatewayTimeout, errs.CodeTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := proxytest.NewUpstream(t, "indexer")
+			route := proxytest.Route("/codebase-indexer", indexer)
+			route.Target.Timeout = 100 * time.Millisecond
+			h := newTestMultiProxyHandler(t, proxytest.NewProxyConfig(proxytest.NewTunnelManager(t), route))
+			tt.setup(indexer)
+
+			w := serve(h, http.MethodGet, testSearchPath, "", nil)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.code, proxytest.ErrorCode(w))
+		})
+	}
+}
+
+func TestMultiProxyDefaultTotalTimeout(t *testing.T) {
+	indexer := proxytest.NewUpstream(t, "indexer")
+	route := proxytest.Route("/codebase-indexer", indexer)
+	route.Target.Timeout = 0
+	cfg := proxytest.NewProxyConfig(proxytest.NewTunnelManager(t), route)
+	cfg.Timeouts.Default.Total = 100 * time.Millisecond
+	h := newTestMultiProxyHandler(t, cfg)
+	indexer.InjectFault(proxytest.Fault{Delay: time.Second})
+
+	w := serve(h, http.MethodGet, testSearchPath, "", nil)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code, w.Body.String())
+	assert.Equal(t, errs.CodeTimeout, proxytest.ErrorCode(w))
+}
+
+func TestMultiProxyFaultInjectionReset(t *testing.T) {
+	indexer := proxytest.NewUpstream(t, "indexer")
+	cfg := proxytest.NewProxyConfig(proxytest.NewTunnelManager(t), proxytest.Route("/codebase-indexer", indexer))
+	cfg.FaultInjection = config.FaultInjectionConfig{
+		Enabled: true,
+		Rules: []config.FaultRuleConfig{{
+			Name:       "reset",
+			Routes:     []string{"/codebase-indexer"},
+			Strategies: []string{config.TargetTypeRoute},
+			Reset:      true,
+		}},
+	}
+	gateway := httptest.NewServer(newTestMultiProxyHandler(t, cfg))
+	t.Cleanup(gateway.Close)
+
+	req, err := http.NewRequest(http.MethodGet, gateway.URL+testSearchPath, nil)
+	require.NoError(t, err)
+	req.Header.Set(config.DefaultFaultHeader, "reset")
+	_, err = http.DefaultClient.Do(req)
+
+	assert.Error(t, err)
+	assert.Empty(t, indexer.Requests())
+}
+
+func TestMultiProxyBandwidthLimitSharedByClient(t *testing.T) {
+	indexer := proxytest.NewUpstream(t, "indexer")
+	payload := strings.Repeat("x", 400)
+	indexer.Handle(testSearchPath, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, payload)
+	})
+	cfg := proxytest.NewProxyConfig(proxytest.NewTunnelManager(t), proxytest.Route("/codebase-indexer", indexer))
+	cfg.Bandwidth = config.BandwidthConfig{Download: 2000, Burst: 200}
+	h := newTestMultiProxyHandler(t, cfg)
+
+	// 同一 clientId 的并发请求共享令牌桶，两个请求共 800 字节，超出 burst 的 600 字节按速率限速
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serve(h, http.MethodGet, testSearchPath+"?clientId="+testClientID, "", nil)
+			assert.Equal(t, payload, w.Body.String())
+		}()
+	}
+	wg.Wait()
+
+	assert.GreaterOrEqual(t, time.Since(start), 280*time.Millisecond)
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,14 +12,19 @@ import (
 
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/rest/httpx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
 )
 
 // ProxyConfig 代理配置
 type ProxyConfig struct {
-	Mode    string        `json:"mode" yaml:"mode"` // 代理模式: rewrite, full_path
-	Target  TargetConfig  `json:"target" yaml:"target"`
-	Rewrite RewriteConfig `json:"rewrite" yaml:"rewrite"`
-	Headers HeadersConfig `json:"headers" yaml:"headers"`
+	Mode     string                `json:"mode" yaml:"mode"` // 代理模式: rewrite, full_path
+	Target   TargetConfig          `json:"target" yaml:"target"`
+	Rewrite  RewriteConfig         `json:"rewrite" yaml:"rewrite"`
+	Headers  HeadersConfig         `json:"headers" yaml:"headers"`
+	Errors   config.ErrorsConfig   `json:"errors" yaml:"errors"`
+	Timeouts config.TimeoutsConfig `json:"timeouts" yaml:"timeouts"`
 }
 
 // TargetConfig 目标服务配置
@@ -31,65 +35,43 @@ type TargetConfig struct {
 
 // RewriteConfig 路径重写配置
 type RewriteConfig struct {
-	Enabled bool          `json:"enabled" yaml:"enabled"`
-	Rules   []RewriteRule `json:"rules" yaml:"rules"`
+	Enabled       bool          `json:"enabled" yaml:"enabled"`
+	StripPrefixes []string      `json:"strip_prefixes" yaml:"strip_prefixes"`
+	Rules         []RewriteRule `json:"rules" yaml:"rules"`
 }
 
 // RewriteRule 重写规则
 type RewriteRule struct {
-	From string `json:"from" yaml:"from"`
-	To   string `json:"to" yaml:"to"`
+	Type     string            `json:"type" yaml:"type"`
+	From     string            `json:"from" yaml:"from"`
+	To       string            `json:"to" yaml:"to"`
+	SetQuery map[string]string `json:"set_query" yaml:"set_query"`
 }
 
 // HeadersConfig Header配置
 type HeadersConfig struct {
-	PassThrough bool              `json:"pass_through" yaml:"pass_through"`
-	Exclude     []string          `json:"exclude" yaml:"exclude"`
-	Override    map[string]string `json:"override" yaml:"override"`
+	PassThrough    bool                          `json:"pass_through" yaml:"pass_through"`
+	Exclude        []string                      `json:"exclude" yaml:"exclude"`
+	Override       map[string]string             `json:"override" yaml:"override"`
+	UserInfoHeader string                        `json:"user_info_header" yaml:"user_info_header"`
+	Policies       []config.HeaderPolicyConfig   `json:"policies" yaml:"policies"` // 按顺序执行的头策略
+	Forwarded      config.ForwardedHeadersConfig `json:"forwarded" yaml:"forwarded"`
 }
 
-// ProxyError 代理错误响应
-type ProxyError struct {
-	Code      string    `json:"code"`
-	Message   string    `json:"message"`
-	Details   string    `json:"details,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
-}
-
-// Error 实现error接口
-func (e *ProxyError) Error() string {
-	return e.Message
-}
-
-// 错误码常量
-const (
-	ErrorCodeBadRequest        = "PROXY_BAD_REQUEST"
-	ErrorCodeTargetUnreachable = "PROXY_TARGET_UNREACHABLE"
-	ErrorCodeTimeout           = "PROXY_TIMEOUT"
-	ErrorCodeInternalError     = "PROXY_INTERNAL_ERROR"
-	ErrorCodeInvalidMode       = "PROXY_INVALID_MODE"
-)
-
 // 代理模式常量
 const (
 	ProxyModeRewrite  = "rewrite"
 	ProxyModeFullPath = "full_path"
 )
 
-// UtilsRewriteRule 路径重写规则
-type UtilsRewriteRule struct {
-	From string
-	To   string
-}
-
 // PathBuilder 路径构建器接口
 type PathBuilder interface {
-	BuildPath(originalPath string) (string, error)
+	BuildPath(ctx context.Context, originalPath string) (string, error)
 }
 
 // RewritePathBuilder 路径重写构建器
 type RewritePathBuilder struct {
-	rules []UtilsRewriteRule
+	rewriter *proxy.Rewriter
 }
 
 // FullPathBuilder 全路径构建器
@@ -99,146 +81,157 @@ type FullPathBuilder struct {
 
 // ProxyLogic 代理转发逻辑
 type ProxyLogic struct {
-	cfg         *ProxyConfig
-	client      *http.Client
-	pathBuilder PathBuilder
+	cfg          *ProxyConfig
+	client       *http.Client
+	timeouts     *proxy.TimeoutBudget
+	pathBuilder  PathBuilder
+	rewriter     *proxy.Rewriter
+	headerPolicy *proxy.HeaderPolicy
+	forwarded    *proxy.ForwardedHeaders
 }
 
-// NewProxyLogic 创建代理逻辑实例
-func NewProxyLogic(cfg *ProxyConfig) *ProxyLogic {
+// NewProxyLogic 创建代理逻辑实例，转发使用 transports 中的连接池
+func NewProxyLogic(cfg *ProxyConfig, transports *proxy.TransportRegistry) *ProxyLogic {
 	// 构建重写规则
-	rules := make([]UtilsRewriteRule, len(cfg.Rewrite.Rules))
+	rules := make([]proxy.RewriteRule, len(cfg.Rewrite.Rules))
 	for i, rule := range cfg.Rewrite.Rules {
-		rules[i] = UtilsRewriteRule{
-			From: rule.From,
-			To:   rule.To,
+		rules[i] = proxy.RewriteRule{
+			Type:     rule.Type,
+			From:     rule.From,
+			To:       rule.To,
+			SetQuery: rule.SetQuery,
 		}
 	}
+	rewriter, err := proxy.NewRewriter(cfg.Rewrite.StripPrefixes, rules)
+	logx.Must(err)
+	forwarded, err := proxy.NewForwardedHeaders(cfg.Headers.Forwarded)
+	logx.Must(err)
 
 	// 根据模式创建路径构建器
 	var pathBuilder PathBuilder
 	if cfg.Mode == ProxyModeFullPath {
 		pathBuilder = &FullPathBuilder{targetURL: cfg.Target.URL}
 	} else {
-		pathBuilder = &RewritePathBuilder{rules: rules}
+		pathBuilder = &RewritePathBuilder{rewriter: rewriter}
 	}
 
 	return &ProxyLogic{
-		cfg: cfg,
-		client: &http.Client{
-			Timeout: cfg.Target.Timeout,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
-		},
-		pathBuilder: pathBuilder,
+		cfg:          cfg,
+		client:       transports.Client(),
+		timeouts:     proxy.NewTimeoutBudget(cfg.Timeouts, cfg.Target.Timeout, config.TimeoutsConfig{}),
+		pathBuilder:  pathBuilder,
+		rewriter:     rewriter,
+		headerPolicy: proxy.NewHeaderPolicy(cfg.Headers.UserInfoHeader, cfg.Headers.Policies...),
+		forwarded:    forwarded,
 	}
 }
 
 // Forward 执行请求转发
 func (l *ProxyLogic) Forward(ctx context.Context, original *http.Request) (*http.Response, error) {
-	logx.Infof("Starting to forward request: %s %s (mode: %s)", original.Method, original.URL.Path, l.cfg.Mode)
+	logx.WithContext(ctx).Infof("Starting to forward request: %s %s (mode: %s)", original.Method, original.URL.Path, l.cfg.Mode)
 
 	targetReq, err := l.buildTargetRequest(ctx, original)
 	if err != nil {
-		logx.Errorf("Failed to build target request: %v", err)
+		logx.WithContext(ctx).Errorf("Failed to build target request: %v", err)
 		return nil, err
 	}
+	targetReq.Body = proxy.LimitUpload(original, targetReq.Body)
 
 	resp, err := l.client.Do(targetReq)
 	if err != nil {
-		if urlErr, ok := err.(*url.Error); ok {
-			if urlErr.Timeout() {
-				return nil, newTimeoutError(urlErr.Error())
-			}
-		}
-		return nil, newTargetUnreachableError(err.Error())
+		return nil, proxy.UpstreamError(ctx, err)
 	}
 
-	logx.Infof("Successfully forwarded request, status: %d", resp.StatusCode)
+	logx.WithContext(ctx).Infof("Successfully forwarded request, status: %d", resp.StatusCode)
 	return resp, nil
 }
 
 // buildTargetRequest 构建目标请求
 func (l *ProxyLogic) buildTargetRequest(ctx context.Context, original *http.Request) (*http.Request, error) {
-	logx.Infof("[PROXY_LOGIC_DEBUG] === Building Target Request ===")
-	logx.Infof("[PROXY_LOGIC_DEBUG] Original request path: %s", original.URL.Path)
-	logx.Infof("[PROXY_LOGIC_DEBUG] Original full URL: %s", original.URL.String())
-	logx.Infof("[PROXY_LOGIC_DEBUG] Proxy mode: %s", l.cfg.Mode)
+	logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] === Building Target Request ===")
+	logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] Original request path: %s", original.URL.Path)
+	logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] Original full URL: %s", original.URL.String())
+	logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] Proxy mode: %s", l.cfg.Mode)
 
 	var fullURL string
 	var err error
+	rawQuery := original.URL.RawQuery
 
 	if l.cfg.Mode == ProxyModeFullPath {
 		// 全路径模式：使用FullPathBuilder
-		fullURL, err = l.pathBuilder.BuildPath(original.URL.Path)
+		fullURL, err = l.pathBuilder.BuildPath(ctx, original.URL.Path)
 		if err != nil {
-			return nil, newInternalError("failed to build full path: " + err.Error())
+			return nil, errs.NewProxyError(errs.CodeInternal, "failed to build full path: "+err.Error())
 		}
 	} else {
 		// rewrite模式：使用传统方式
 		targetPath := original.URL.Path
-		logx.Infof("[PROXY_LOGIC_DEBUG] Rewrite mode - initial path: %s", targetPath)
+		logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] Rewrite mode - initial path: %s", targetPath)
 
 		// 移除代理前缀
-		if strings.HasPrefix(targetPath, "/proxy") {
-			targetPath = strings.TrimPrefix(targetPath, "/proxy")
-			logx.Infof("[PROXY_LOGIC_DEBUG] After removing /proxy prefix: %s", targetPath)
-		}
+		targetPath = l.rewriter.StripPrefix(targetPath)
+		logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] After removing proxy prefix: %s", targetPath)
 
 		// 应用路径重写规则
 		if l.cfg.Rewrite.Enabled {
-			logx.Infof("[PROXY_LOGIC_DEBUG] Rewrite enabled, applying %d rules", len(l.cfg.Rewrite.Rules))
-			rules := make([]UtilsRewriteRule, len(l.cfg.Rewrite.Rules))
-			for i, rule := range l.cfg.Rewrite.Rules {
-				rules[i] = UtilsRewriteRule{
-					From: rule.From,
-					To:   rule.To,
-				}
-			}
+			logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] Rewrite enabled, applying %d rules", len(l.cfg.Rewrite.Rules))
 			originalPath := targetPath
-			targetPath = rewritePath(targetPath, rules)
-			logx.Infof("[PROXY_LOGIC_DEBUG] Path before rewrite: %s", originalPath)
-			logx.Infof("[PROXY_LOGIC_DEBUG] Path after rewrite: %s", targetPath)
+			targetPath, rawQuery = l.rewriter.Rewrite(targetPath, rawQuery)
+			logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] Path before rewrite: %s", originalPath)
+			logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] Path after rewrite: %s", targetPath)
 		}
 
 		// 清理路径
 		targetPath = cleanPath(targetPath)
-		logx.Infof("[PROXY_LOGIC_DEBUG] After cleaning path: %s", targetPath)
+		logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] After cleaning path: %s", targetPath)
 
 		fullURL = joinPath(l.cfg.Target.URL, targetPath)
 	}
 
-	if original.URL.RawQuery != "" {
-		fullURL += "?" + original.URL.RawQuery
+	if rawQuery != "" {
+		fullURL += "?" + rawQuery
 	}
-	logx.Infof("[PROXY_LOGIC_DEBUG] Final target URL: %s", fullURL)
-	logx.Infof("[PROXY_LOGIC_DEBUG] === End Building Target Request ===")
+	logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] Final target URL: %s", fullURL)
+	logx.WithContext(ctx).Infof("[PROXY_LOGIC_DEBUG] === End Building Target Request ===")
 
 	// 创建新请求
 	targetURL, err := url.Parse(fullURL)
 	if err != nil {
-		return nil, newInternalError("failed to parse target URL: " + err.Error())
+		return nil, errs.NewProxyError(errs.CodeInternal, "failed to parse target URL: "+err.Error())
 	}
 
 	targetReq, err := http.NewRequestWithContext(ctx, original.Method, targetURL.String(), original.Body)
 	if err != nil {
-		return nil, newInternalError("failed to create target request: " + err.Error())
+		return nil, errs.NewProxyError(errs.CodeInternal, "failed to create target request: "+err.Error())
 	}
+	// 保留请求体长度，请求体被转换后按新长度转发
+	targetReq.ContentLength = original.ContentLength
 
-	// 复制并过滤header
+	// 复制并过滤header，关闭透传时只保留内容协商相关的header
+	headers := original.Header
+	if !l.cfg.Headers.PassThrough {
+		headers = headers.Clone()
+		proxy.RestrictHeaders(headers)
+	}
 	filteredHeaders := filterHeaders(
-		original.Header,
+		headers,
 		l.cfg.Headers.Exclude,
 		l.cfg.Headers.Override,
 	)
+	if id := proxy.RequestID(original); id != "" {
+		filteredHeaders.Set(proxy.RequestIDHeader, id)
+	}
+	l.forwarded.Apply(filteredHeaders, original)
+	l.headerPolicy.ApplyRequest(filteredHeaders, original)
 
-	// 设置Host header为目标地址
+	// 设置Host为目标地址，配置保留原始Host时使用客户端请求的Host
 	if host := targetURL.Host; host != "" {
 		filteredHeaders.Set("Host", host)
 	}
+	if l.forwarded.PreserveHost() {
+		filteredHeaders.Set("Host", original.Host)
+		targetReq.Host = original.Host
+	}
 
 	targetReq.Header = filteredHeaders
 
@@ -246,24 +239,16 @@ func (l *ProxyLogic) buildTargetRequest(ctx context.Context, original *http.Requ
 }
 
 // BuildPath 构建路径（RewritePathBuilder实现）
-func (b *RewritePathBuilder) BuildPath(originalPath string) (string, error) {
-	if len(b.rules) == 0 {
-		return originalPath, nil
+func (b *RewritePathBuilder) BuildPath(ctx context.Context, originalPath string) (string, error) {
+	newPath, _ := b.rewriter.Rewrite(originalPath, "")
+	if newPath != originalPath {
+		logx.WithContext(ctx).Infof("Path rewritten: %s -> %s", originalPath, newPath)
 	}
-
-	for _, rule := range b.rules {
-		if strings.HasPrefix(originalPath, rule.From) {
-			newPath := strings.Replace(originalPath, rule.From, rule.To, 1)
-			logx.Infof("Path rewritten: %s -> %s", originalPath, newPath)
-			return newPath, nil
-		}
-	}
-
-	return originalPath, nil
+	return newPath, nil
 }
 
 // BuildPath 构建路径（FullPathBuilder实现）
-func (b *FullPathBuilder) BuildPath(originalPath string) (string, error) {
+func (b *FullPathBuilder) BuildPath(ctx context.Context, originalPath string) (string, error) {
 	if originalPath == "" {
 		originalPath = "/"
 	}
@@ -295,6 +280,11 @@ func (l *ProxyLogic) GetTargetURL() string {
 // HealthCheck 检查目标服务健康状态
 func (l *ProxyLogic) HealthCheck(ctx context.Context) (bool, time.Duration, error) {
 	start := time.Now()
+	if timeout := l.cfg.Timeouts.Default.Total; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", l.cfg.Target.URL+"/health", nil)
 	if err != nil {
@@ -325,46 +315,49 @@ func (l *ProxyLogic) Close() error {
 // ProxyHandler 代理处理器
 type ProxyHandler struct {
 	proxyLogic *ProxyLogic
+	errWriter  *proxy.ErrorWriter
 }
 
 // NewProxyHandler 创建代理处理器
-func NewProxyHandler(cfg *ProxyConfig) *ProxyHandler {
+func NewProxyHandler(cfg *ProxyConfig, transports *proxy.TransportRegistry) *ProxyHandler {
 	return &ProxyHandler{
-		proxyLogic: NewProxyLogic(cfg),
+		proxyLogic: NewProxyLogic(cfg, transports),
+		errWriter:  proxy.NewErrorWriter(cfg.Errors),
 	}
 }
 
 // ServeHTTP 处理代理请求
 func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx, cancel := h.proxyLogic.timeouts.Start(r)
+	defer cancel()
 
 	// 记录请求日志
-	logx.Infof("Received proxy request: %s %s from %s (mode: %s)", r.Method, r.URL.Path, r.RemoteAddr, h.proxyLogic.cfg.Mode)
+	logx.WithContext(r.Context()).Infof("Received proxy request: %s %s from %s (mode: %s)", r.Method, r.URL.Path, r.RemoteAddr, h.proxyLogic.cfg.Mode)
 
 	// 验证请求
 	if err := h.validateRequest(r); err != nil {
-		logx.Errorf("Invalid request: %v", err)
-		h.sendError(w, err, http.StatusBadRequest)
+		logx.WithContext(r.Context()).Errorf("Invalid request: %v", err)
+		h.errWriter.Write(w, r, err)
 		return
 	}
 
 	// 执行转发
 	resp, err := h.proxyLogic.Forward(ctx, r)
 	if err != nil {
-		logx.Errorf("Failed to forward request: %v", err)
-		h.handleForwardError(w, err)
+		logx.WithContext(r.Context()).Errorf("Failed to forward request: %v", err)
+		h.errWriter.Write(w, r, err)
 		return
 	}
 	defer resp.Body.Close()
 
 	// 复制响应
-	if err := h.copyResponse(w, resp); err != nil {
-		logx.Errorf("Failed to copy response: %v", err)
-		h.sendError(w, err, http.StatusInternalServerError)
+	if err := h.copyResponse(w, r, resp); err != nil {
+		logx.WithContext(r.Context()).Errorf("Failed to copy response: %v", err)
+		h.errWriter.Write(w, r, err)
 		return
 	}
 
-	logx.Infof("Successfully handled proxy request: %s %s -> %d (mode: %s)", r.Method, r.URL.Path, resp.StatusCode, h.proxyLogic.cfg.Mode)
+	logx.WithContext(r.Context()).Infof("Successfully handled proxy request: %s %s -> %d (mode: %s)", r.Method, r.URL.Path, resp.StatusCode, h.proxyLogic.cfg.Mode)
 }
 
 // HealthCheck 健康检查处理器
@@ -373,7 +366,7 @@ func (h *ProxyHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 
 	healthy, duration, err := h.proxyLogic.HealthCheck(ctx)
 	if err != nil {
-		logx.Errorf("Health check failed: %v", err)
+		logx.WithContext(r.Context()).Errorf("Health check failed: %v", err)
 		httpx.OkJson(w, map[string]interface{}{
 			"status": "error",
 			"error":  err.Error(),
@@ -401,11 +394,7 @@ func (h *ProxyHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 func (h *ProxyHandler) validateRequest(r *http.Request) error {
 	// 验证URL长度
 	if len(r.URL.String()) > 65536 {
-		return &ProxyError{
-			Code:    "PROXY_URL_TOO_LONG",
-			Message: "Request URL too long",
-			Details: "URL length exceeds 64KB limit",
-		}
+		return errs.NewProxyError(errs.CodeURLTooLong, "URL length exceeds 64KB limit")
 	}
 
 	// 验证Header大小
@@ -417,11 +406,7 @@ func (h *ProxyHandler) validateRequest(r *http.Request) error {
 		}
 	}
 	if headerSize > 1024*1024 { // 1MB
-		return &ProxyError{
-			Code:    "PROXY_HEADERS_TOO_LARGE",
-			Message: "Request headers too large",
-			Details: "Headers size exceeds 1MB limit",
-		}
+		return errs.NewProxyError(errs.CodeHeadersTooLarge, "Headers size exceeds 1MB limit")
 	}
 
 	// 验证HTTP方法
@@ -430,66 +415,28 @@ func (h *ProxyHandler) validateRequest(r *http.Request) error {
 		http.MethodPatch, http.MethodHead, http.MethodOptions:
 		// 有效方法
 	default:
-		return &ProxyError{
-			Code:    "PROXY_METHOD_NOT_ALLOWED",
-			Message: "HTTP method not allowed",
-			Details: "Method " + r.Method + " is not supported",
-		}
+		return errs.NewProxyError(errs.CodeMethodNotAllowed, "Method "+r.Method+" is not supported")
 	}
 
 	return nil
 }
 
 // copyResponse 复制响应
-func (h *ProxyHandler) copyResponse(dst http.ResponseWriter, src *http.Response) error {
-	// 复制状态码
-	dst.WriteHeader(src.StatusCode)
-
-	// 复制Header
+func (h *ProxyHandler) copyResponse(dst http.ResponseWriter, r *http.Request, src *http.Response) error {
+	// 复制Header，需在写出状态码之前完成
 	for key, values := range src.Header {
 		for _, value := range values {
 			dst.Header().Add(key, value)
 		}
 	}
+	h.proxyLogic.headerPolicy.ApplyResponse(dst.Header(), r)
 
-	// 复制Body
-	_, err := io.Copy(dst, src.Body)
-	return err
-}
-
-// handleForwardError 处理转发错误
-func (h *ProxyHandler) handleForwardError(w http.ResponseWriter, err error) {
-	if proxyErr, ok := err.(*ProxyError); ok {
-		switch proxyErr.Code {
-		case ErrorCodeTimeout:
-			h.sendError(w, err, http.StatusGatewayTimeout)
-		case ErrorCodeTargetUnreachable:
-			h.sendError(w, err, http.StatusServiceUnavailable)
-		case ErrorCodeBadRequest:
-			h.sendError(w, err, http.StatusBadRequest)
-		default:
-			h.sendError(w, err, http.StatusInternalServerError)
-		}
-	} else {
-		h.sendError(w, err, http.StatusInternalServerError)
-	}
-}
+	// 复制状态码
+	dst.WriteHeader(src.StatusCode)
 
-// sendError 发送错误响应
-func (h *ProxyHandler) sendError(w http.ResponseWriter, err error, statusCode int) {
-	if proxyErr, ok := err.(*ProxyError); ok {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(statusCode)
-		json.NewEncoder(w).Encode(proxyErr)
-	} else {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(statusCode)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"code":      "PROXY_ERROR",
-			"message":   err.Error(),
-			"timestamp": time.Now().UTC(),
-		})
-	}
+	// 复制Body，配置了带宽限制时按下载速率读取
+	_, err := io.Copy(dst, proxy.LimitDownload(r, src.Body))
+	return err
 }
 
 // Close 关闭处理器
@@ -498,29 +445,6 @@ func (h *ProxyHandler) Close() error {
 }
 
 // 工具函数实现
-func rewritePath(path string, rules []UtilsRewriteRule) string {
-	logx.Infof("[REWRITE_DEBUG] === Path Rewrite Started ===")
-	logx.Infof("[REWRITE_DEBUG] Input path: %s", path)
-	logx.Infof("[REWRITE_DEBUG] Number of rules: %d", len(rules))
-
-	for i, rule := range rules {
-		logx.Infof("[REWRITE_DEBUG] Checking rule %d: From='%s', To='%s'", i+1, rule.From, rule.To)
-		logx.Infof("[REWRITE_DEBUG] Path starts with '%s': %v", rule.From, strings.HasPrefix(path, rule.From))
-
-		if strings.HasPrefix(path, rule.From) {
-			newPath := strings.Replace(path, rule.From, rule.To, 1)
-			logx.Infof("[REWRITE_DEBUG] Rule %d matched! Path before: %s", i+1, path)
-			logx.Infof("[REWRITE_DEBUG] Rule %d matched! Path after: %s", i+1, newPath)
-			logx.Infof("[REWRITE_DEBUG] === Path Rewrite Completed (Match Found) ===")
-			return newPath
-		}
-	}
-
-	logx.Infof("[REWRITE_DEBUG] No rules matched, returning original path: %s", path)
-	logx.Infof("[REWRITE_DEBUG] === Path Rewrite Completed (No Match) ===")
-	return path
-}
-
 func cleanPath(path string) string {
 	if path == "" {
 		return "/"
@@ -609,30 +533,3 @@ func removeConnectionHeaders(headers http.Header) {
 	headers.Del("Upgrade")
 	headers.Del("Transfer-Encoding")
 }
-
-func newTimeoutError(details string) *ProxyError {
-	return &ProxyError{
-		Code:      ErrorCodeTimeout,
-		Message:   "Request timeout",
-		Details:   details,
-		Timestamp: time.Now().UTC(),
-	}
-}
-
-func newTargetUnreachableError(details string) *ProxyError {
-	return &ProxyError{
-		Code:      ErrorCodeTargetUnreachable,
-		Message:   "Target service unreachable",
-		Details:   details,
-		Timestamp: time.Now().UTC(),
-	}
-}
-
-func newInternalError(details string) *ProxyError {
-	return &ProxyError{
-		Code:      ErrorCodeInternalError,
-		Message:   "Internal server error",
-		Details:   details,
-		Timestamp: time.Now().UTC(),
-	}
-}
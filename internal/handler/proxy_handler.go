@@ -3,19 +3,25 @@ package handler
 import (
 	"net/http"
 
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
 	"github.com/zgsm-ai/codebase-indexer/internal/svc"
 )
 
 // proxyHealthCheckHandler 代理健康检查处理器
 func proxyHealthCheckHandler(serverCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !serverCtx.Drainer.Ready() {
+			serverCtx.ErrorWriter.Write(w, r, errs.NewProxyError(errs.CodeShuttingDown, "not ready"))
+			return
+		}
+
 		// 兼容旧版本
 		if serverCtx.ProxyHandler != nil {
 			serverCtx.ProxyHandler.HealthCheck(w, r)
 			return
 		}
 
-		http.Error(w, "No proxy handler configured", http.StatusNotImplemented)
+		serverCtx.ErrorWriter.Write(w, r, errs.NewProxyError(errs.CodeNotConfigured, "no proxy handler configured"))
 	}
 }
 
@@ -27,6 +33,6 @@ func proxyHandler(serverCtx *svc.ServiceContext) http.HandlerFunc {
 			return
 		}
 
-		http.Error(w, "No proxy handler configured", http.StatusNotImplemented)
+		serverCtx.ErrorWriter.Write(w, r, errs.NewProxyError(errs.CodeNotConfigured, "no proxy handler configured"))
 	}
 }
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/logic"
+	"github.com/zgsm-ai/codebase-indexer/internal/metrics"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// serveRecorded 正常处理请求，并将请求与响应提交给流量录制逻辑
+// 请求体与响应体超过 max_body_bytes 的部分不录制，记录中标记为截断
+func (h *SmartProxyHandler) serveRecorded(w http.ResponseWriter, r *http.Request, route config.RouteConfig) {
+	limit := h.recorder.MaxBodyBytes()
+	body, err := proxy.PeekBody(r, limit+1)
+	if err != nil {
+		logx.WithContext(r.Context()).Errorf("Skip recording %s %s: failed to read request body: %v", r.Method, r.URL.Path, err)
+		metrics.RecordedRequests.Inc(logic.RecordResultSkipped)
+		h.ServeHTTP(w, r)
+		return
+	}
+	requestTruncated := int64(len(body)) > limit
+	if requestTruncated {
+		body = body[:limit]
+	}
+
+	exchange := &logic.RecordedExchange{
+		Time:      time.Now(),
+		RequestID: proxy.RequestID(r),
+		Route:     route.PathPrefix,
+		ClientID:  proxy.PeekClientID(r),
+		User:      h.recorder.User(r),
+		Request: logic.RecordedRequest{
+			Method:       r.Method,
+			Host:         r.Host,
+			Path:         r.URL.Path,
+			Query:        r.URL.RawQuery,
+			Header:       r.Header.Clone(),
+			RecordedBody: logic.NewRecordedBody(body, requestTruncated),
+		},
+	}
+	if route.Name != "" {
+		exchange.Route = route.Name
+	}
+
+	cw := newCaptureWriter(w, limit)
+	h.ServeHTTP(cw, r)
+
+	exchange.Duration = time.Since(exchange.Time).String()
+	header := cw.header
+	if header == nil {
+		header = cw.Header().Clone()
+	}
+	exchange.Response = logic.RecordedResponse{
+		Status:       cw.Status(),
+		Header:       header,
+		RecordedBody: logic.NewRecordedBody(cw.body.Bytes(), cw.truncated),
+	}
+	h.recorder.Record(exchange)
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// statusWriter 记录响应状态码的 ResponseWriter 包装
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w}
+}
+
+// WriteHeader 记录状态码
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write 未显式写入状态码时视为 200
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush 透传 Flush，保证流式响应可用
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 使用
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status 返回已写入的状态码，未写入时返回 200
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// captureWriter 在写出响应的同时保留最多 limit 字节的响应体副本
+type captureWriter struct {
+	*statusWriter
+	header    http.Header
+	body      bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+func newCaptureWriter(w http.ResponseWriter, limit int64) *captureWriter {
+	return &captureWriter{statusWriter: newStatusWriter(w), limit: limit}
+}
+
+// WriteHeader 记录状态码与响应头快照
+func (w *captureWriter) WriteHeader(statusCode int) {
+	if w.header == nil {
+		w.header = w.ResponseWriter.Header().Clone()
+	}
+	w.statusWriter.WriteHeader(statusCode)
+}
+
+// Write 写出响应并保留副本
+func (w *captureWriter) Write(data []byte) (int, error) {
+	if w.header == nil {
+		w.header = w.ResponseWriter.Header().Clone()
+	}
+	if remaining := w.limit - int64(w.body.Len()); remaining > 0 {
+		if int64(len(data)) > remaining {
+			w.body.Write(data[:remaining])
+			w.truncated = true
+		} else {
+			w.body.Write(data)
+		}
+	} else if len(data) > 0 {
+		w.truncated = true
+	}
+	return w.statusWriter.Write(data)
+}
@@ -7,42 +7,72 @@ import (
 	"net/http"
 
 	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
 	"github.com/zgsm-ai/codebase-indexer/internal/svc"
 
 	"github.com/zeromicro/go-zero/rest"
 )
 
+// proxyMethods 代理路由注册的 HTTP 方法
+var proxyMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodDelete,
+	http.MethodPatch,
+	http.MethodHead,
+	http.MethodOptions,
+}
+
 func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
-	// 1. 注册健康检查路由
-	registerHealthCheckRoutes(server, serverCtx)
-	
-	// 2. 注册代理路由
+	// 使用智能代理处理器，根据请求头和配置自动选择转发策略
+	var proxyHandler *SmartProxyHandler
 	if serverCtx.Config.ProxyConfig != nil {
-		// 使用智能代理处理器，根据请求头和配置自动选择转发策略
-		proxyHandler := NewSmartProxyHandler(serverCtx.Config.ProxyConfig)
+		proxyHandler = NewSmartProxyHandler(serverCtx.Config.ProxyConfig, serverCtx.PortManager, serverCtx.Transports)
+		serverCtx.Drainer.OnClose("smart proxy handler", proxyHandler.Close)
+		serverCtx.Drainer.OnClose("port manager", serverCtx.PortManager.Close)
 		logx.Infof("Using smart proxy handler with automatic routing strategy")
-		
+	}
+	serverCtx.Drainer.OnClose("upstream connection pools", serverCtx.Transports.Close)
+
+	// 1. 注册健康检查路由
+	registerHealthCheckRoutes(server, serverCtx, proxyHandler)
+
+	// 2. 注册代理路由
+	if proxyHandler != nil {
+
 		// 注册代理处理器
-		methods := []string{
-			http.MethodGet,
-			http.MethodPost,
-			http.MethodPut,
-			http.MethodDelete,
-			http.MethodPatch,
-			http.MethodHead,
-			http.MethodOptions,
+		methods := proxyMethods
+
+		// 聚合检索注册在检索路径上，携带 clientId 列表的 GET/POST 请求执行聚合，其余请求按路由转发
+		var fanOutHandler *FanOutHandler
+		fanOutRouted := false
+		if serverCtx.Config.ProxyConfig.FanOut.Enabled {
+			fanOutHandler = NewFanOutHandler(serverCtx.Config.ProxyConfig, serverCtx.PortManager, serverCtx.Transports, serverCtx.ErrorWriter)
+		}
+		wrapFanOut := func(routeConfig config.RouteConfig, method string, handler http.HandlerFunc) http.HandlerFunc {
+			if fanOutHandler == nil || routeConfig.PathPrefix != serverCtx.Config.ProxyConfig.FanOut.Path {
+				return handler
+			}
+			if method != http.MethodGet && method != http.MethodPost {
+				return handler
+			}
+			fanOutRouted = true
+			return fanOutHandler.Wrap(handler)
 		}
-		
+
 		var routes []rest.Route
 		if serverCtx.Config.ProxyConfig.DynamicPort {
 			// 动态端口模式下，使用统一的路径前缀
 			routes = make([]rest.Route, 0, len(serverCtx.Config.ProxyConfig.Routes)*len(methods))
 			for _, routeConfig := range serverCtx.Config.ProxyConfig.Routes {
+				routeHandler := proxyHandler.RouteHandler(routeConfig)
 				for _, method := range methods {
 					routes = append(routes, rest.Route{
 						Method:  method,
 						Path:    routeConfig.PathPrefix,
-						Handler: http.HandlerFunc(proxyHandler.ServeHTTP),
+						Handler: wrapFanOut(routeConfig, method, routeHandler),
 					})
 				}
 			}
@@ -50,22 +80,51 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 			// 静态路由模式下，使用配置的路径前缀
 			routes = make([]rest.Route, 0, len(serverCtx.Config.ProxyConfig.Routes)*len(methods))
 			for _, routeConfig := range serverCtx.Config.ProxyConfig.Routes {
+				routeHandler := proxyHandler.RouteHandler(routeConfig)
 				for _, method := range methods {
 					routes = append(routes, rest.Route{
 						Method:  method,
 						Path:    routeConfig.PathPrefix,
-						Handler: http.HandlerFunc(proxyHandler.ServeHTTP),
+						Handler: wrapFanOut(routeConfig, method, routeHandler),
 					})
 				}
 			}
 		}
-		
+
+		// 检索路径没有对应的转发路由时单独注册聚合检索
+		if fanOutHandler != nil && !fanOutRouted {
+			for _, method := range []string{http.MethodGet, http.MethodPost} {
+				routes = append(routes, rest.Route{
+					Method:  method,
+					Path:    serverCtx.Config.ProxyConfig.FanOut.Path,
+					Handler: fanOutHandler.Wrap(nil),
+				})
+			}
+		}
+		if fanOutHandler != nil {
+			logx.Infof("Registered fan-out search on %s", serverCtx.Config.ProxyConfig.FanOut.Path)
+		}
+
 		server.AddRoutes(routes)
 	}
 }
 
 // registerHealthCheckRoutes 注册健康检查路由
-func registerHealthCheckRoutes(server *rest.Server, serverCtx *svc.ServiceContext) {
+func registerHealthCheckRoutes(server *rest.Server, serverCtx *svc.ServiceContext, proxyHandler *SmartProxyHandler) {
+	// 存活与就绪探针
+	server.AddRoutes([]rest.Route{
+		{
+			Method:  http.MethodGet,
+			Path:    "/livez",
+			Handler: livezHandler(),
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/readyz",
+			Handler: readyzHandler(serverCtx.Readiness, serverCtx.Transports),
+		},
+	})
+
 	server.AddRoutes(
 		[]rest.Route{
 			{
@@ -76,15 +135,15 @@ func registerHealthCheckRoutes(server *rest.Server, serverCtx *svc.ServiceContex
 		},
 		rest.WithPrefix("/codebase-indexer"),
 	)
-	
+
 	// 如果启用了动态代理，注册动态代理健康检查路由
-	if serverCtx.Config.ProxyConfig != nil && serverCtx.Config.ProxyConfig.DynamicPort {
+	if proxyHandler != nil && serverCtx.Config.ProxyConfig.DynamicPort {
 		server.AddRoutes(
 			[]rest.Route{
 				{
 					Method:  http.MethodGet,
 					Path:    "/api/v1/dynamic-proxy/health",
-					Handler: dynamicProxyHealthCheckHandler(serverCtx),
+					Handler: dynamicProxyHealthCheckHandler(serverCtx, proxyHandler.dynamicProxyHandler),
 				},
 			},
 			rest.WithPrefix("/codebase-indexer"),
@@ -92,15 +151,13 @@ func registerHealthCheckRoutes(server *rest.Server, serverCtx *svc.ServiceContex
 	}
 }
 
-// dynamicProxyHealthCheckHandler 动态代理健康检查处理器
-func dynamicProxyHealthCheckHandler(serverCtx *svc.ServiceContext) http.HandlerFunc {
+// dynamicProxyHealthCheckHandler 动态代理健康检查处理器，复用智能代理处理器中的动态代理处理器
+func dynamicProxyHealthCheckHandler(serverCtx *svc.ServiceContext, dynamicHandler *DynamicProxyHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if serverCtx.Config.ProxyConfig != nil && serverCtx.Config.ProxyConfig.DynamicPort {
-			dynamicHandler := NewDynamicProxyHandler(serverCtx.Config.ProxyConfig)
-			dynamicHandler.HealthCheck(w, r)
+		if !serverCtx.Drainer.Ready() {
+			serverCtx.ErrorWriter.Write(w, r, errs.NewProxyError(errs.CodeShuttingDown, "not ready"))
 			return
 		}
-
-		http.Error(w, "Dynamic proxy not configured", http.StatusNotImplemented)
+		dynamicHandler.HealthCheck(w, r)
 	}
-}
\ No newline at end of file
+}
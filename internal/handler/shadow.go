@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/logic"
+	"github.com/zgsm-ai/codebase-indexer/internal/metrics"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// serveWithShadow 正常处理请求，并将请求副本与主后端响应提交给影子流量逻辑比对
+func (h *SmartProxyHandler) serveWithShadow(w http.ResponseWriter, r *http.Request, rule *proxy.ShadowRule) {
+	job, ok := h.newShadowJob(r, rule.Name, rule.URL)
+	if !ok {
+		h.route(w, r)
+		return
+	}
+
+	cw := newCaptureWriter(w, h.shadow.MaxBodyBytes())
+	h.route(cw, r)
+
+	if cw.truncated {
+		logx.WithContext(r.Context()).Infof("Skip shadow diff for %s %s: response body exceeds %d bytes", r.Method, r.URL.Path, h.shadow.MaxBodyBytes())
+		metrics.MirrorRequests.Inc(rule.Name, logic.ShadowResultSkipped)
+		return
+	}
+	job.Diff = true
+	job.PrimaryStatus = cw.Status()
+	job.PrimaryHeader = cw.header
+	job.PrimaryBody = cw.body.Bytes()
+	h.shadow.Submit(job)
+}
+
+// mirrorRequest 将请求副本提交给影子流量逻辑发送到镜像目标，丢弃其响应
+func (h *SmartProxyHandler) mirrorRequest(r *http.Request, mirrorURL, source string) {
+	if job, ok := h.newShadowJob(r, source, mirrorURL); ok {
+		h.shadow.Submit(job)
+	}
+}
+
+// newShadowJob 复制请求生成影子请求，未创建影子流量逻辑或请求体超过上限时放弃
+func (h *SmartProxyHandler) newShadowJob(r *http.Request, source, baseURL string) (*logic.ShadowJob, bool) {
+	if h.shadow == nil {
+		return nil, false
+	}
+	var body []byte
+	if r.Method != http.MethodGet {
+		var err error
+		body, err = proxy.PeekBody(r, h.shadow.MaxBodyBytes()+1)
+		if err != nil || int64(len(body)) > h.shadow.MaxBodyBytes() {
+			logx.WithContext(r.Context()).Errorf("Skip mirroring %s %s: request body unavailable or too large", r.Method, r.URL.Path)
+			metrics.MirrorRequests.Inc(source, logic.ShadowResultSkipped)
+			return nil, false
+		}
+	}
+
+	return &logic.ShadowJob{
+		Source:    source,
+		RequestID: proxy.RequestID(r),
+		BaseURL:   baseURL,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     r.URL.RawQuery,
+		Header:    r.Header.Clone(),
+		Body:      body,
+	}, true
+}
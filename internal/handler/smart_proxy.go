@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,10 +10,16 @@ import (
 	"time"
 
 	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/search"
 	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/logic"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
 )
 
 // SmartProxyHandler 智能代理处理器
+// 先按分流规则（traffic_split）按权重选择后端，再按转发规则引擎（forward_rules 与
+// header_based_forward）选择目标，均未命中时
 // 根据请求头中的 X-Costrict-Version 字段和配置来决定转发策略：
 // 1. 如果请求头里有 X-Costrict-Version 字段，则用 port_manager 转发
 // 2. 否则如果配置了转发到固定地址，则转发到固定地址
@@ -20,106 +27,313 @@ import (
 type SmartProxyHandler struct {
 	dynamicProxyHandler *DynamicProxyHandler
 	staticProxyHandler  *ProxyHandler
+	routeHandlers       map[string]*ProxyHandler // 命名路由处理器
+	ruleEngine          *proxy.RuleEngine
+	trafficSplits       []*proxy.TrafficSplit
+	shadowRules         []*proxy.ShadowRule
+	shadow              *logic.ShadowLogic // 未启用影子流量且没有镜像目标时为 nil
+	recorder            *logic.RecordLogic // 未启用流量录制时为 nil
+	headerPolicy        *proxy.HeaderPolicy
+	forwarded           *proxy.ForwardedHeaders
+	errWriter           *proxy.ErrorWriter
+	client              *http.Client // 转发到规则中指定 URL 的客户端
+	transports          *proxy.TransportRegistry
+	timeouts            *proxy.TimeoutBudget
+	clientID            *proxy.ClientIDExtractor
+	debug               *proxy.DebugGate
+	faults              *proxy.FaultInjector // 未启用故障注入时为 nil
 	proxyConfig         *config.ProxyConfig
+	routeTree           *search.Tree // 按 go-zero 路由规则查找请求命中的路由，供请求解析使用
 }
 
 // NewSmartProxyHandler 创建智能代理处理器
-func NewSmartProxyHandler(cfg *config.ProxyConfig) *SmartProxyHandler {
+// 路由目标按 route.transport 登记到 transports，所有转发共用其中的连接池，动态转发使用共享的 portManager
+func NewSmartProxyHandler(cfg *config.ProxyConfig, portManager *proxy.PortManager, transports *proxy.TransportRegistry) *SmartProxyHandler {
+	for _, route := range cfg.Routes {
+		transports.Register(route.Target.URL, route.Transport)
+	}
+
+	ruleEngine, err := proxy.NewRuleEngine(cfg)
+	logx.Must(err)
+	trafficSplits, err := proxy.NewTrafficSplits(cfg.TrafficSplit)
+	logx.Must(err)
+	shadowRules, err := proxy.NewShadowRules(cfg.Shadow)
+	logx.Must(err)
+	forwarded, err := proxy.NewForwardedHeaders(cfg.Headers.Forwarded)
+	logx.Must(err)
+
 	handler := &SmartProxyHandler{
-		dynamicProxyHandler: NewDynamicProxyHandler(cfg),
+		dynamicProxyHandler: NewDynamicProxyHandler(cfg, portManager, transports),
+		routeHandlers:       make(map[string]*ProxyHandler),
+		ruleEngine:          ruleEngine,
+		trafficSplits:       trafficSplits,
+		shadowRules:         shadowRules,
+		headerPolicy:        proxy.NewHeaderPolicy(cfg.UserInfoHeader, cfg.Headers.Policy()),
+		forwarded:           forwarded,
+		errWriter:           proxy.NewErrorWriter(cfg.Errors),
+		client:              transports.Client(),
+		transports:          transports,
+		timeouts:            proxy.NewTimeoutBudget(cfg.Timeouts, 0, config.TimeoutsConfig{}),
+		clientID:            proxy.NewClientIDExtractor(cfg.ClientID, nil),
+		debug:               proxy.NewDebugGate(cfg.Debug, cfg.UserInfoHeader),
+		faults:              proxy.NewFaultInjector(cfg.FaultInjection),
 		proxyConfig:         cfg,
+		routeTree:           newRouteTree(cfg.Routes),
+	}
+
+	// 影子比对与分流镜像共用发送队列，两者都未配置时不启动工作协程
+	if cfg.Shadow.Enabled || hasMirror(trafficSplits) {
+		handler.shadow = logic.NewShadowLogic(cfg.Shadow, transports)
+	}
+
+	if cfg.Record.Enabled {
+		handler.recorder = logic.NewRecordLogic(cfg.Record, cfg.UserInfoHeader)
 	}
 
 	// 如果配置了 ForwardURL，创建静态代理处理器
 	if cfg.ForwardURL != "" {
-		staticConfig := &ProxyConfig{
-			Mode: cfg.Mode,
-			Target: TargetConfig{
-				URL:     cfg.ForwardURL,
-				Timeout: 30 * time.Second,
-			},
-			Rewrite: RewriteConfig{
-				Enabled: cfg.Rewrite.Enabled,
-				Rules:   make([]RewriteRule, len(cfg.Rewrite.Rules)),
-			},
-			Headers: HeadersConfig{
-				PassThrough: cfg.Headers.PassThrough,
-				Exclude:     cfg.Headers.Exclude,
-				Override:    cfg.Headers.Override,
-			},
+		handler.staticProxyHandler = NewProxyHandler(newTargetProxyConfig(cfg, cfg.ForwardURL, cfg.Timeouts.Default.Total), transports)
+		logx.Infof("Created static proxy handler for forward URL: %s", cfg.ForwardURL)
+	}
+
+	// 为命名路由创建代理处理器，供转发规则引用
+	for _, route := range cfg.Routes {
+		if route.Name == "" {
+			continue
 		}
+		handler.routeHandlers[route.Name] = NewProxyHandler(newTargetProxyConfig(cfg, route.Target.URL, route.Target.Timeout, route.Headers), transports)
+		logx.Infof("Created named route handler: %s -> %s", route.Name, route.Target.URL)
+	}
 
-		// 复制重写规则
-		for i, rule := range cfg.Rewrite.Rules {
-			staticConfig.Rewrite.Rules[i] = RewriteRule{
-				From: rule.From,
-				To:   rule.To,
-			}
+	logx.Infof("Created smart proxy handler with %d forward rules", len(ruleEngine.Rules()))
+	return handler
+}
+
+// newTargetProxyConfig 基于全局代理配置为指定目标构建单目标代理配置，policies 在全局头策略之后执行
+func newTargetProxyConfig(cfg *config.ProxyConfig, targetURL string, timeout time.Duration, policies ...config.HeaderPolicyConfig) *ProxyConfig {
+	targetConfig := &ProxyConfig{
+		Mode: cfg.Mode,
+		Target: TargetConfig{
+			URL:     targetURL,
+			Timeout: timeout,
+		},
+		Rewrite:  newRewriteConfig(cfg.Rewrite),
+		Headers:  newHeadersConfig(cfg, policies...),
+		Errors:   cfg.Errors,
+		Timeouts: cfg.Timeouts,
+	}
+
+	return targetConfig
+}
+
+// newErrorPages 创建路由错误页，redirect 目标与 forward_url 一样按静态目标转发
+func newErrorPages(cfg *config.ProxyConfig, route config.RouteConfig, transports *proxy.TransportRegistry) *proxy.ErrorPages {
+	targets := make(map[string]*ProxyHandler)
+	for _, page := range route.ErrorPages {
+		if page.Type == config.ErrorPageRedirect && targets[page.URL] == nil {
+			targets[page.URL] = NewProxyHandler(newTargetProxyConfig(cfg, page.URL, route.Target.Timeout, route.Headers), transports)
 		}
+	}
+	pages, err := proxy.NewErrorPages(route.ErrorPages, func(w http.ResponseWriter, r *http.Request, targetURL string) {
+		targets[targetURL].ServeHTTP(w, r)
+	})
+	logx.Must(err)
+	return pages
+}
 
-		handler.staticProxyHandler = NewProxyHandler(staticConfig)
-		logx.Infof("Created static proxy handler for forward URL: %s", cfg.ForwardURL)
+// newHeadersConfig 复制全局 Header 配置，头策略依次为全局策略与 policies
+func newHeadersConfig(cfg *config.ProxyConfig, policies ...config.HeaderPolicyConfig) HeadersConfig {
+	return HeadersConfig{
+		PassThrough:    cfg.Headers.PassThrough,
+		Exclude:        cfg.Headers.Exclude,
+		Override:       cfg.Headers.Override,
+		UserInfoHeader: cfg.UserInfoHeader,
+		Policies:       append([]config.HeaderPolicyConfig{cfg.Headers.Policy()}, policies...),
+		Forwarded:      cfg.Headers.Forwarded,
 	}
+}
 
-	logx.Infof("Created smart proxy handler")
-	return handler
+// newRewriteConfig 复制全局路径重写配置
+func newRewriteConfig(cfg config.RewriteConfig) RewriteConfig {
+	rewrite := RewriteConfig{
+		Enabled:       cfg.Enabled,
+		StripPrefixes: cfg.StripPrefixes,
+		Rules:         make([]RewriteRule, len(cfg.Rules)),
+	}
+	for i, rule := range cfg.Rules {
+		rewrite.Rules[i] = RewriteRule{
+			Type:     rule.Type,
+			From:     rule.From,
+			To:       rule.To,
+			SetQuery: rule.SetQuery,
+		}
+	}
+	return rewrite
 }
 
 // ServeHTTP 处理智能代理请求
 func (h *SmartProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 检查是否启用了基于请求头的转发
-	if h.proxyConfig.HeaderBasedForward.Enabled {
-		// 检查请求路径是否匹配任何一个配置的路径
-		for _, pathConfig := range h.proxyConfig.HeaderBasedForward.Paths {
-			if r.URL.Path == pathConfig.Path {
-				// 检查请求头中是否有指定的字段
-				headerValue := r.Header.Get(h.proxyConfig.HeaderBasedForward.HeaderName)
-				if headerValue != "" {
-					logx.Infof("Request contains %s header: %s, forwarding to: %s",
-						h.proxyConfig.HeaderBasedForward.HeaderName, headerValue, pathConfig.WithHeaderURL)
-					h.forwardToURL(w, r, pathConfig.WithHeaderURL)
-					return
-				} else {
-					logx.Infof("No %s header found, forwarding to: %s",
-						h.proxyConfig.HeaderBasedForward.HeaderName, pathConfig.WithoutHeaderURL)
-					h.forwardToURL(w, r, pathConfig.WithoutHeaderURL)
-					return
-				}
-			}
-		}
+	// 按可信代理配置解析真实客户端 IP，供头策略等使用
+	r = proxy.WithClientIP(r, h.forwarded.ClientIP(r))
+	// 分流、头策略与动态转发按同一套规则查找 clientId
+	r = proxy.WithDefaultClientIDExtractor(r, h.clientID)
+
+	// 命中影子规则且被采样时，额外将请求副本发送到影子目标比对
+	if rule := proxy.MatchShadowRule(h.shadowRules, r); rule != nil && rule.Sampled() {
+		h.serveWithShadow(w, r, rule)
+		return
+	}
+
+	h.route(w, r)
+}
+
+// route 选择转发策略并转发请求
+func (h *SmartProxyHandler) route(w http.ResponseWriter, r *http.Request) {
+	// 优先按分流规则选择后端
+	if split := proxy.MatchTrafficSplit(h.trafficSplits, r); split != nil {
+		h.serveSplit(w, r, split)
+		return
+	}
+
+	// 其次使用转发规则引擎
+	if rule, ok := h.ruleEngine.Match(r); ok {
+		logx.WithContext(r.Context()).Infof("Request %s %s matched forward rule %s, target type: %s", r.Method, r.URL.Path, rule.Name, rule.Target.Type)
+		h.dispatch(w, proxy.WithHeaderPolicy(r, rule.Headers), rule.Target)
+		return
 	}
 
-	// 如果没有启用基于请求头的转发或路径不匹配，使用原有的逻辑
+	// 如果没有命中任何规则，按 X-Costrict-Version 选择策略，路由配置了回退链时失败后依次回退
+	proxy.FallbackChainFromContext(r).Run(w, r, h.defaultStrategy(r), h.serveStrategy)
+}
+
+// defaultStrategy 未命中任何规则时选择的转发策略
+func (h *SmartProxyHandler) defaultStrategy(r *http.Request) string {
 	// 检查请求头中是否有 X-Costrict-Version 字段
 	costrictVersion := r.Header.Get("X-Costrict-Version")
 	if costrictVersion != "" {
-		logx.Infof("Request contains X-Costrict-Version header: %s, using dynamic proxy (port_manager)", costrictVersion)
-		h.dynamicProxyHandler.ServeHTTP(w, r)
-		return
+		logx.WithContext(r.Context()).Infof("Request contains X-Costrict-Version header: %s, using dynamic proxy (port_manager)", costrictVersion)
+		return config.StrategyDynamic
 	}
 
 	// 如果没有 X-Costrict-Version 字段，检查是否配置了 ForwardURL
 	if h.staticProxyHandler != nil {
-		logx.Infof("No X-Costrict-Version header found, using static proxy to forward URL: %s", h.proxyConfig.ForwardURL)
-		h.staticProxyHandler.ServeHTTP(w, r)
-		return
+		logx.WithContext(r.Context()).Infof("No X-Costrict-Version header found, using static proxy to forward URL: %s", h.proxyConfig.ForwardURL)
+		return config.StrategyStatic
 	}
 
 	// 否则使用 port_manager 转发
-	logx.Infof("No X-Costrict-Version header and no forward URL configured, using dynamic proxy (port_manager)")
+	logx.WithContext(r.Context()).Infof("No X-Costrict-Version header and no forward URL configured, using dynamic proxy (port_manager)")
+	return config.StrategyDynamic
+}
+
+// serveStrategy 按策略转发请求
+func (h *SmartProxyHandler) serveStrategy(strategy string, w http.ResponseWriter, r *http.Request) {
+	w, done := proxy.InjectFault(w, r, strategy)
+	if done {
+		return
+	}
+	if strategy == config.StrategyStatic && h.staticProxyHandler != nil {
+		h.staticProxyHandler.ServeHTTP(w, r)
+		return
+	}
 	h.dynamicProxyHandler.ServeHTTP(w, r)
 }
 
+// RouteHandler 返回绑定路由请求体限制、压缩、请求体转换、clientId 提取规则、错误页、回退链、超时预算与带宽限制的处理函数
+// 携带调试标记且通过校验的请求按调试模式处理，命中录制条件的请求同时录制请求与响应
+// 携带故障注入请求头的请求在选定转发策略后按故障规则注入故障
+func (h *SmartProxyHandler) RouteHandler(route config.RouteConfig) http.HandlerFunc {
+	pages := newErrorPages(h.proxyConfig, route, h.transports)
+	fallback := proxy.NewFallbackChain(route.Fallback)
+	timeouts := proxy.NewTimeoutBudget(h.proxyConfig.Timeouts, route.Target.Timeout, route.Timeouts)
+	limit := proxy.NewBodyLimit(h.proxyConfig.Body, route.Body)
+	compressor := proxy.NewCompressor(h.proxyConfig.Compression, route.Compression)
+	transformer := proxy.NewBodyTransformer(route.BodyTransforms)
+	clientID := proxy.NewClientIDExtractor(h.proxyConfig.ClientID, route.ClientID)
+	bandwidth := proxy.NewBandwidthLimit(h.proxyConfig.Bandwidth, route.Bandwidth)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w, finish := compressor.Compress(w, r)
+		defer finish()
+
+		// 请求体超限时在读取请求体之前返回
+		if err := limit.Apply(r); err != nil {
+			logx.WithContext(r.Context()).Errorf("Rejected request body: %v", err)
+			h.errWriter.Write(w, proxy.WithErrorPages(r, pages), err)
+			return
+		}
+		if err := compressor.DecompressRequest(r); err != nil {
+			h.errWriter.Write(w, proxy.WithErrorPages(r, pages), err)
+			return
+		}
+		// 在读取 clientId 与缓存请求体之前转换请求体
+		if err := transformer.Apply(r); err != nil {
+			h.errWriter.Write(w, proxy.WithErrorPages(r, pages), err)
+			return
+		}
+		w = limit.LimitResponse(w, r, h.errWriter)
+		r = proxy.WithFallbackChain(proxy.WithErrorPages(r, pages), fallback)
+		r = proxy.WithTimeoutBudget(r, timeouts)
+		r = proxy.WithBodyLimit(r, limit)
+		r = proxy.WithClientIDExtractor(r, clientID)
+		r = proxy.WithBandwidthLimit(r, bandwidth)
+		r = proxy.WithFaultInjector(r, h.faults, route)
+		if mode := h.debug.Extract(r); mode != "" {
+			h.serveDebug(w, r, route, mode)
+			return
+		}
+		if h.recorder != nil && h.recorder.Match(r, route) {
+			h.serveRecorded(w, r, route)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+}
+
+// dispatch 按转发目标分发请求
+func (h *SmartProxyHandler) dispatch(w http.ResponseWriter, r *http.Request, target config.ForwardTargetConfig) {
+	w, done := proxy.InjectFault(w, r, target.Type)
+	if done {
+		return
+	}
+	switch target.Type {
+	case config.TargetTypeURL:
+		h.forwardToURL(w, r, target.URL)
+	case config.TargetTypeDynamic:
+		h.dynamicProxyHandler.ServeHTTP(w, r)
+	case config.TargetTypeStatic:
+		if h.staticProxyHandler == nil {
+			h.errWriter.Write(w, r, errs.NewProxyError(errs.CodeNotConfigured, "no forward URL configured for static target"))
+			return
+		}
+		h.staticProxyHandler.ServeHTTP(w, r)
+	case config.TargetTypeRoute:
+		routeHandler, ok := h.routeHandlers[target.Route]
+		if !ok {
+			h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeNotConfigured, "route not found: %s", target.Route))
+			return
+		}
+		routeHandler.ServeHTTP(w, r)
+	default:
+		h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeInternal, "unsupported target type: %s", target.Type))
+	}
+}
+
 // forwardToURL 转发请求到指定URL
 func (h *SmartProxyHandler) forwardToURL(w http.ResponseWriter, r *http.Request, targetURL string) {
-	// 读取请求体内容
+	// 读取请求体内容，按路由与请求方法的请求体上限限制最大大小
 	var bodyBytes []byte
 	var err error
 	if r.Body != nil {
-		bodyBytes, err = io.ReadAll(r.Body)
+		maxBodySize := bodySizeLimit(r, h.proxyConfig)
+		bodyBytes, err = io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
 		if err != nil {
-			logx.Errorf("Failed to read request body: %v", err)
-			h.sendError(w, fmt.Sprintf("Failed to read request body: %v", err), http.StatusInternalServerError)
+			logx.WithContext(r.Context()).Errorf("Failed to read request body: %v", err)
+			h.errWriter.Write(w, r, proxy.BodyReadError(err))
+			return
+		}
+		if int64(len(bodyBytes)) > maxBodySize {
+			logx.WithContext(r.Context()).Errorf("Request body too large, exceeds %d bytes", maxBodySize)
+			h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeBodyTooLarge, "request body exceeds %d bytes", maxBodySize))
 			return
 		}
 		// 重新设置请求体，以便其他中间件或处理器可以读取
@@ -133,40 +347,22 @@ func (h *SmartProxyHandler) forwardToURL(w http.ResponseWriter, r *http.Request,
 		bodyReader = bytes.NewBuffer(bodyBytes)
 	}
 
-	targetReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL, bodyReader)
+	ctx, cancel := h.timeouts.Start(r)
+	defer cancel()
+
+	targetReq, err := h.newURLRequest(ctx, r, targetURL, bodyReader)
 	if err != nil {
-		logx.Errorf("Failed to create target request: %v", err)
-		h.sendError(w, fmt.Sprintf("Failed to create target request: %v", err), http.StatusInternalServerError)
+		logx.WithContext(r.Context()).Errorf("Failed to create target request: %v", err)
+		h.errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeInternal, "failed to create target request: %v", err))
 		return
 	}
-
-	// 复制请求头
-	for key, values := range r.Header {
-		for _, value := range values {
-			targetReq.Header.Add(key, value)
-		}
-	}
-
-	// 复制查询参数
-	if r.URL.RawQuery != "" {
-		targetReq.URL.RawQuery = r.URL.RawQuery
-	}
-
-	// 创建HTTP客户端
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
-	}
+	targetReq.Body = proxy.LimitUpload(r, targetReq.Body)
 
 	// 发送请求
-	resp, err := client.Do(targetReq)
+	resp, err := h.client.Do(targetReq)
 	if err != nil {
-		logx.Errorf("Failed to forward request: %v", err)
-		h.sendError(w, fmt.Sprintf("Failed to forward request: %v", err), http.StatusBadGateway)
+		logx.WithContext(r.Context()).Errorf("Failed to forward request: %v", err)
+		h.errWriter.Write(w, r, proxy.UpstreamError(ctx, err))
 		return
 	}
 	defer resp.Body.Close()
@@ -177,17 +373,19 @@ func (h *SmartProxyHandler) forwardToURL(w http.ResponseWriter, r *http.Request,
 			w.Header().Add(key, value)
 		}
 	}
+	h.headerPolicy.ApplyResponse(w.Header(), r)
 
 	// 复制响应状态码和内容
 	w.WriteHeader(resp.StatusCode)
 
-	// 复制响应体
+	// 复制响应体，配置了带宽限制时按下载速率读取
+	body := proxy.LimitDownload(r, resp.Body)
 	buf := make([]byte, 32*1024) // 32KB buffer
 	for {
-		n, err := resp.Body.Read(buf)
+		n, err := body.Read(buf)
 		if n > 0 {
 			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
-				logx.Errorf("Failed to write response: %v", writeErr)
+				logx.WithContext(r.Context()).Errorf("Failed to write response: %v", writeErr)
 				break
 			}
 		}
@@ -196,18 +394,36 @@ func (h *SmartProxyHandler) forwardToURL(w http.ResponseWriter, r *http.Request,
 		}
 	}
 
-	logx.Infof("Successfully forwarded request: %s %s -> %d", r.Method, targetURL, resp.StatusCode)
+	logx.WithContext(r.Context()).Infof("Successfully forwarded request: %s %s -> %d", r.Method, targetURL, resp.StatusCode)
 }
 
-// sendError 发送错误响应
-func (h *SmartProxyHandler) sendError(w http.ResponseWriter, message string, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"code":      "PROXY_ERROR",
-		"message":   message,
-		"timestamp": time.Now().UTC(),
-	})
+// newURLRequest 构建转发到规则中指定 URL 的请求
+func (h *SmartProxyHandler) newURLRequest(ctx context.Context, r *http.Request, targetURL string, body io.Reader) (*http.Request, error) {
+	targetReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 复制请求头
+	for key, values := range r.Header {
+		for _, value := range values {
+			targetReq.Header.Add(key, value)
+		}
+	}
+	if !h.proxyConfig.Headers.PassThrough {
+		proxy.RestrictHeaders(targetReq.Header)
+	}
+	h.forwarded.Apply(targetReq.Header, r)
+	h.headerPolicy.ApplyRequest(targetReq.Header, r)
+	if h.forwarded.PreserveHost() {
+		targetReq.Host = r.Host
+	}
+
+	// 复制查询参数
+	if r.URL.RawQuery != "" {
+		targetReq.URL.RawQuery = r.URL.RawQuery
+	}
+	return targetReq, nil
 }
 
 // HealthCheck 健康检查
@@ -217,6 +433,7 @@ func (h *SmartProxyHandler) HealthCheck(w http.ResponseWriter, r *http.Request)
 		StaticProxy        map[string]interface{} `json:"static_proxy,omitempty"`
 		ForwardURL         string                 `json:"forward_url,omitempty"`
 		HeaderBasedForward map[string]interface{} `json:"header_based_forward,omitempty"`
+		ForwardRules       map[string]interface{} `json:"forward_rules,omitempty"`
 		Strategy           string                 `json:"strategy"`
 	}
 
@@ -255,6 +472,22 @@ func (h *SmartProxyHandler) HealthCheck(w http.ResponseWriter, r *http.Request)
 		healthStatus.HeaderBasedForward = headerBasedForwardStatus
 	}
 
+	// 如果启用了转发规则引擎，返回规则概要
+	if h.proxyConfig.ForwardRules.Enabled {
+		rules := make([]map[string]interface{}, 0, len(h.proxyConfig.ForwardRules.Rules))
+		for _, rule := range h.proxyConfig.ForwardRules.Rules {
+			rules = append(rules, map[string]interface{}{
+				"name":   rule.Name,
+				"target": rule.Target,
+			})
+		}
+		healthStatus.ForwardRules = map[string]interface{}{
+			"enabled": true,
+			"rules":   rules,
+			"default": h.proxyConfig.ForwardRules.Default,
+		}
+	}
+
 	response := map[string]interface{}{
 		"status": "ok",
 		"proxy":  healthStatus,
@@ -323,6 +556,8 @@ func (h *SmartProxyHandler) Close() error {
 		}
 	}
 
+	h.client.CloseIdleConnections()
+
 	// 关闭静态代理处理器
 	if h.staticProxyHandler != nil {
 		if err := h.staticProxyHandler.Close(); err != nil {
@@ -330,6 +565,27 @@ func (h *SmartProxyHandler) Close() error {
 		}
 	}
 
+	// 等待影子请求发送完毕
+	if h.shadow != nil {
+		if err := h.shadow.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close shadow logic: %w", err))
+		}
+	}
+
+	// 写完录制文件
+	if h.recorder != nil {
+		if err := h.recorder.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close recorder: %w", err))
+		}
+	}
+
+	// 关闭命名路由处理器
+	for name, routeHandler := range h.routeHandlers {
+		if err := routeHandler.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close route handler %s: %w", name, err))
+		}
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("errors closing smart proxy handlers: %v", errs)
 	}
@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/proxytest"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+func TestSmartProxyStrategyByVersionHeader(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	tunnel := proxytest.NewUpstream(t, "tunnel")
+	shared := proxytest.NewUpstream(t, "shared")
+	tunnels.AddTunnel(testClientID, tunnel)
+	cfg := proxytest.NewProxyConfig(tunnels)
+	cfg.ForwardURL = shared.URL()
+	h := newTestSmartProxyHandler(t, cfg)
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		strategy string
+		upstream string
+	}{
+		{"without version header", nil, config.StrategyStatic, "shared"},
+		{"with version header", http.Header{"X-Costrict-Version": {"2.0.0"}}, config.StrategyDynamic, "tunnel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodGet, testSearchPath+"?clientId="+testClientID, "", tt.header)
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, tt.strategy, w.Header().Get(proxy.StrategyHeader))
+			assert.Equal(t, tt.upstream, proxytest.DecodeEcho(t, w.Body.Bytes()).Upstream)
+		})
+	}
+}
+
+func TestSmartProxyFallbackChain(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	shared := proxytest.NewUpstream(t, "shared")
+	route := config.RouteConfig{
+		PathPrefix: "/codebase-indexer",
+		Target:     config.TargetConfig{URL: shared.URL(), Timeout: proxytest.DefaultRouteTimeout},
+		Fallback:   config.FallbackConfig{Chain: []string{config.StrategyDynamic, config.StrategyStatic}},
+	}
+	cfg := proxytest.NewProxyConfig(tunnels, route)
+	cfg.ForwardURL = shared.URL()
+	h := newTestSmartProxyHandler(t, cfg)
+	routeHandler := h.RouteHandler(cfg.Routes[0])
+
+	body := `{"clientId":"unknown","query":"foo"}`
+	w := serve(routeHandler, http.MethodPost, testSearchPath, body, http.Header{
+		"Content-Type":       {"application/json"},
+		"X-Costrict-Version": {"2.0.0"},
+	})
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, config.StrategyStatic, w.Header().Get(proxy.StrategyHeader))
+	assert.Equal(t, config.StrategyDynamic, w.Header().Get(proxy.FallbackFromHeader))
+	echo := proxytest.DecodeEcho(t, w.Body.Bytes())
+	assert.Equal(t, "shared", echo.Upstream)
+	assert.Equal(t, body, echo.Body, "request body must be replayed to the fallback strategy")
+	assert.Equal(t, []string{"unknown"}, tunnels.Lookups())
+}
+
+func TestSmartProxyForwardRuleURL(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	embedder := proxytest.NewUpstream(t, "embedder")
+	cfg := proxytest.NewProxyConfig(tunnels)
+	cfg.ForwardRules = config.ForwardRulesConfig{
+		Enabled: true,
+		Rules: []config.ForwardRuleConfig{{
+			Name:   "embedder",
+			Match:  config.RuleMatchConfig{Path: testSearchPath, PathType: config.PathMatchExact},
+			Target: config.ForwardTargetConfig{Type: config.TargetTypeURL, URL: embedder.URL() + "/codebase-embedder/api/v1/search/semantic"},
+		}},
+		Default: config.ForwardTargetConfig{Type: config.TargetTypeDynamic},
+	}
+	h := newTestSmartProxyHandler(t, cfg)
+
+	w := serve(h, http.MethodGet, testSearchPath+"?clientId="+testClientID, "", nil)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	echo := proxytest.DecodeEcho(t, w.Body.Bytes())
+	assert.Equal(t, "embedder", echo.Upstream)
+	assert.Equal(t, "/codebase-embedder/api/v1/search/semantic", echo.Path)
+	assert.Empty(t, tunnels.Lookups())
+}
+
+func TestSmartProxyPassesThroughUpstreamError(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	tunnels.AddTunnel(testClientID, indexer)
+	indexer.InjectFaultOnce(proxytest.Fault{Status: http.StatusServiceUnavailable})
+	h := newTestSmartProxyHandler(t, proxytest.NewProxyConfig(tunnels))
+
+	w := serve(h, http.MethodGet, testSearchPath+"?clientId="+testClientID, "", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"error":"injected fault"}`, w.Body.String())
+
+	w = serve(h, http.MethodGet, testSearchPath+"?clientId="+testClientID, "", nil)
+	assert.Equal(t, http.StatusOK, w.Code, "one-shot fault must only affect the first request")
+}
+
+func TestSmartProxyTruncatedResponse(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	tunnels.AddTunnel(testClientID, indexer)
+	indexer.InjectFault(proxytest.Fault{Truncate: true})
+	h := newTestSmartProxyHandler(t, proxytest.NewProxyConfig(tunnels))
+	gateway := httptest.NewServer(h)
+	t.Cleanup(gateway.Close)
+
+	resp, err := http.Get(gateway.URL + testSearchPath + "?clientId=" + testClientID)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Error(t, err, "truncated upstream body must not be reported as complete")
+	length, _ := strconv.Atoi(resp.Header.Get("Content-Length"))
+	assert.Less(t, len(body), length)
+}
+
+func TestSmartProxyDebugDryRun(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	tunnels.AddTunnel(testClientID, indexer)
+	route := proxytest.Route("/codebase-indexer", indexer)
+	cfg := proxytest.NewProxyConfig(tunnels, route)
+	cfg.Debug = config.DebugConfig{Enabled: true, Secret: "s3cret"}
+	h := newTestSmartProxyHandler(t, cfg)
+
+	w := serve(h.RouteHandler(cfg.Routes[0]), http.MethodGet, testSearchPath+"?clientId="+testClientID, "", http.Header{
+		config.DefaultDebugHeader:       {config.DebugModeDryRun},
+		config.DefaultDebugSecretHeader: {"s3cret"},
+		"Authorization":                 {"Bearer secret"},
+	})
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var e Explanation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &e))
+	assert.Equal(t, config.StrategyDynamic, e.Strategy)
+	assert.Equal(t, testClientID, e.ClientID)
+	assert.Equal(t, indexer.Port(), e.Port)
+	assert.Empty(t, indexer.Requests(), "dry-run must not forward the request")
+}
+
+func TestSmartProxyFaultInjection(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	tunnel := proxytest.NewUpstream(t, "tunnel")
+	shared := proxytest.NewUpstream(t, "shared")
+	tunnels.AddTunnel(testClientID, tunnel)
+	route := proxytest.Route("/codebase-indexer", shared)
+	cfg := proxytest.NewProxyConfig(tunnels, route)
+	cfg.ForwardURL = shared.URL()
+	cfg.FaultInjection = config.FaultInjectionConfig{
+		Enabled: true,
+		Rules: []config.FaultRuleConfig{
+			{Name: "tunnel-down", Strategies: []string{config.StrategyDynamic}, Abort: http.StatusServiceUnavailable},
+			{Name: "other-client", ClientIDs: []string{"client-b"}, Abort: http.StatusBadGateway},
+		},
+	}
+	h := newTestSmartProxyHandler(t, cfg)
+	routeHandler := h.RouteHandler(cfg.Routes[0])
+	target := testSearchPath + "?clientId=" + testClientID
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		status   int
+		fault    string
+		upstream *proxytest.Upstream
+	}{
+		{"without fault header", http.Header{"X-Costrict-Version": {"2.0.0"}}, http.StatusOK, "", tunnel},
+		{"matched strategy", http.Header{"X-Costrict-Version": {"2.0.0"}, config.DefaultFaultHeader: {"all"}}, http.StatusServiceUnavailable, "tunnel-down", nil},
+		{"unmatched strategy", http.Header{config.DefaultFaultHeader: {"all"}}, http.StatusOK, "", shared},
+		{"unselected rule", http.Header{"X-Costrict-Version": {"2.0.0"}, config.DefaultFaultHeader: {"other-client"}}, http.StatusOK, "", tunnel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(tunnel.Requests()) + len(shared.Requests())
+			w := serve(routeHandler, http.MethodGet, target, "", tt.header)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.fault, w.Header().Get(proxy.FaultHeader))
+			if tt.upstream == nil {
+				assert.Equal(t, before, len(tunnel.Requests())+len(shared.Requests()), "aborted request must not be forwarded")
+				return
+			}
+			require.NotNil(t, tt.upstream.LastRequest())
+			assert.Empty(t, tt.upstream.LastRequest().Header.Get(config.DefaultFaultHeader), "fault header must not be forwarded")
+		})
+	}
+}
+
+func TestSmartProxyFaultInjectionPartialResponse(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	tunnels.AddTunnel(testClientID, indexer)
+	route := proxytest.Route("/codebase-indexer", indexer)
+	cfg := proxytest.NewProxyConfig(tunnels, route)
+	cfg.FaultInjection = config.FaultInjectionConfig{
+		Enabled: true,
+		Rules:   []config.FaultRuleConfig{{Name: "partial", PartialBytes: 10}},
+	}
+	h := newTestSmartProxyHandler(t, cfg)
+	gateway := httptest.NewServer(h.RouteHandler(cfg.Routes[0]))
+	t.Cleanup(gateway.Close)
+
+	req, err := http.NewRequest(http.MethodGet, gateway.URL+testSearchPath+"?clientId="+testClientID, nil)
+	require.NoError(t, err)
+	req.Header.Set(config.DefaultFaultHeader, "partial")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "partial", resp.Header.Get(proxy.FaultHeader))
+	assert.Error(t, err)
+	assert.Len(t, body, 10)
+}
+
+func TestSmartProxyBandwidthLimitDownload(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	tunnels.AddTunnel(testClientID, indexer)
+	payload := strings.Repeat("x", 600)
+	indexer.Handle(testSearchPath, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, payload)
+	})
+	route := proxytest.Route("/codebase-indexer", indexer)
+	route.Bandwidth = config.BandwidthConfig{Download: 2000, Burst: 200, MaxDownload: 4000}
+	cfg := proxytest.NewProxyConfig(tunnels, route)
+	h := newTestSmartProxyHandler(t, cfg)
+	routeHandler := h.RouteHandler(cfg.Routes[0])
+	target := testSearchPath + "?clientId=" + testClientID
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		minimum time.Duration
+	}{
+		// 令牌桶初始装满，600 字节中超出 burst 的 400 字节按速率限速
+		{"route rate", http.Header{"X-Costrict-Version": {"2.0.0"}}, 200 * time.Millisecond},
+		{"header rate", http.Header{"X-Costrict-Version": {"2.0.0"}, config.DefaultDownloadRateHeader: {"1000"}}, 400 * time.Millisecond},
+		{"header rate capped at max", http.Header{"X-Costrict-Version": {"2.0.0"}, config.DefaultDownloadRateHeader: {"1M"}}, 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			w := serve(routeHandler, http.MethodGet, target, "", tt.header)
+			elapsed := time.Since(start)
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, payload, w.Body.String())
+			assert.GreaterOrEqual(t, elapsed, tt.minimum-20*time.Millisecond)
+		})
+	}
+}
+
+func TestSmartProxyBandwidthLimitUpload(t *testing.T) {
+	tunnels := proxytest.NewTunnelManager(t)
+	indexer := proxytest.NewUpstream(t, "indexer")
+	tunnels.AddTunnel(testClientID, indexer)
+	route := proxytest.Route("/codebase-indexer", indexer)
+	route.Bandwidth = config.BandwidthConfig{Upload: 2000, Burst: 200}
+	cfg := proxytest.NewProxyConfig(tunnels, route)
+	h := newTestSmartProxyHandler(t, cfg)
+	// clientId 从请求体中提取，限速读取的请求体必须完整转发
+	body := `{"clientId":"` + testClientID + `","content":"` + strings.Repeat("x", 600) + `"}`
+
+	start := time.Now()
+	w := serve(h.RouteHandler(cfg.Routes[0]), http.MethodPost, "/codebase-indexer/api/v1/files/upload", body, http.Header{"X-Costrict-Version": {"2.0.0"}})
+	elapsed := time.Since(start)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotNil(t, indexer.LastRequest())
+	assert.Equal(t, body, string(indexer.LastRequest().Body))
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+}
+
+func TestSmartProxyShadowLogicCreatedOnDemand(t *testing.T) {
+	indexer := proxytest.NewUpstream(t, "indexer")
+	mirror := proxytest.NewUpstream(t, "mirror")
+	tunnels := proxytest.NewTunnelManager(t)
+
+	cfg := proxytest.NewProxyConfig(tunnels, proxytest.Route("/codebase-indexer", indexer))
+	h := newTestSmartProxyHandler(t, cfg)
+	assert.Nil(t, h.shadow, "shadow logic must not start without shadow or mirror config")
+
+	cfg = proxytest.NewProxyConfig(tunnels, proxytest.Route("/codebase-indexer", indexer))
+	cfg.TrafficSplit = config.TrafficSplitConfig{
+		Enabled: true,
+		Splits: []config.SplitConfig{{
+			Name:     "canary",
+			Match:    config.RuleMatchConfig{Path: "/codebase-indexer", PathType: config.PathMatchPrefix},
+			Backends: []config.WeightedBackendConfig{{Name: "stable", Weight: 100, Target: config.ForwardTargetConfig{Type: config.TargetTypeURL, URL: indexer.URL()}}},
+			Mirror:   config.MirrorConfig{URL: mirror.URL()},
+		}},
+	}
+	h = newTestSmartProxyHandler(t, cfg)
+	require.NotNil(t, h.shadow)
+
+	w := serve(h, http.MethodGet, testSearchPath, "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Eventually(t, func() bool { return len(mirror.Requests()) == 1 }, time.Second, 10*time.Millisecond)
+}
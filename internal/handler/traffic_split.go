@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/metrics"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// splitHeader 响应头中标识分流结果
+const splitHeader = "X-Proxy-Split"
+
+// serveSplit 按分流规则选择后端并转发，同时记录分流指标
+func (h *SmartProxyHandler) serveSplit(w http.ResponseWriter, r *http.Request, split *proxy.TrafficSplit) {
+	backend := split.Pick(h.stickyKey(r, split.Sticky))
+	logx.WithContext(r.Context()).Infof("Request %s %s matched traffic split %s, backend: %s", r.Method, r.URL.Path, split.Name, backend.Name)
+
+	if split.Mirror.URL != "" {
+		h.mirrorRequest(r, split.Mirror.URL, split.Name)
+	}
+
+	sw := newStatusWriter(w)
+	sw.Header().Set(splitHeader, split.Name+"/"+backend.Name)
+	start := time.Now()
+	h.dispatch(sw, r, backend.Target)
+
+	metrics.SplitRequests.Inc(split.Name, backend.Name, strconv.Itoa(sw.Status()))
+	metrics.SplitDuration.Observe(time.Since(start).Milliseconds(), split.Name, backend.Name)
+}
+
+// stickyKey 获取粘性分配的键，获取不到时返回空字符串（随机分配）
+func (h *SmartProxyHandler) stickyKey(r *http.Request, sticky string) string {
+	switch sticky {
+	case config.StickyUser:
+		return utils.ParseJWTUserInfo(r, h.proxyConfig.UserInfoHeader)
+	case config.StickyClientID:
+		return proxy.PeekClientID(r)
+	default:
+		return ""
+	}
+}
+
+// hasMirror 是否有分流规则配置了镜像目标
+func hasMirror(splits []*proxy.TrafficSplit) bool {
+	for _, split := range splits {
+		if split.Mirror.URL != "" {
+			return true
+		}
+	}
+	return false
+}
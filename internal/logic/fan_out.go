@@ -0,0 +1,327 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// fanOutAppName 通过端口管理器查询隧道端口时使用的应用名
+const fanOutAppName = "codebase-indexer"
+
+// maxFanOutPeekBodySize 判断是否为聚合请求时读取的最大请求体字节数，检索请求体只包含检索条件
+const maxFanOutPeekBodySize = 1024 * 1024
+
+// FanOutBackend 单个聚合后端
+type FanOutBackend struct {
+	Source   string // 结果来源标识，如 client:xxx 或 static:http://...
+	ClientID string // 通过端口管理器解析的 clientId，静态目标为空
+	BaseURL  string // 静态目标地址，clientId 目标为空
+}
+
+// FanOutSourceResult 单个后端的执行结果
+type FanOutSourceResult struct {
+	Source     string `json:"source"`
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Count      int    `json:"count"`
+	Duration   string `json:"duration"`
+	Error      string `json:"error,omitempty"`
+}
+
+// FanOutResult 聚合检索结果
+type FanOutResult struct {
+	List    []map[string]interface{} `json:"list"`
+	Partial bool                     `json:"partial"`
+	Sources []FanOutSourceResult     `json:"sources"`
+}
+
+// FanOutLogic 聚合检索逻辑
+type FanOutLogic struct {
+	cfg          config.FanOutConfig
+	headers      config.HeadersConfig
+	headerPolicy *proxy.HeaderPolicy
+	forwarded    *proxy.ForwardedHeaders
+	portManager  *proxy.PortManager
+	client       *http.Client
+}
+
+// NewFanOutLogic 创建聚合检索逻辑实例，后端请求头与单目标转发使用相同的头配置
+func NewFanOutLogic(cfg *config.ProxyConfig, portManager *proxy.PortManager, transports *proxy.TransportRegistry) *FanOutLogic {
+	forwarded, err := proxy.NewForwardedHeaders(cfg.Headers.Forwarded)
+	logx.Must(err)
+
+	return &FanOutLogic{
+		cfg:          cfg.FanOut,
+		headers:      cfg.Headers,
+		headerPolicy: proxy.NewHeaderPolicy(cfg.UserInfoHeader, cfg.Headers.Policy()),
+		forwarded:    forwarded,
+		portManager:  portManager,
+		client:       transports.Client(),
+	}
+}
+
+// Search 将请求并行发送到所有后端并合并结果
+func (l *FanOutLogic) Search(ctx context.Context, original *http.Request, backends []FanOutBackend, body []byte) *FanOutResult {
+	type backendResult struct {
+		source FanOutSourceResult
+		items  []map[string]interface{}
+	}
+
+	results := make([]backendResult, len(backends))
+	var wg sync.WaitGroup
+	for i, backend := range backends {
+		wg.Add(1)
+		go func(i int, backend FanOutBackend) {
+			defer wg.Done()
+			start := time.Now()
+			items, statusCode, err := l.searchBackend(ctx, original, backend, body)
+			results[i].source = FanOutSourceResult{
+				Source:     backend.Source,
+				Success:    err == nil,
+				StatusCode: statusCode,
+				Count:      len(items),
+				Duration:   time.Since(start).String(),
+			}
+			if err != nil {
+				logx.WithContext(ctx).Errorf("Fan-out backend %s failed: %v", backend.Source, err)
+				results[i].source.Error = err.Error()
+				return
+			}
+			results[i].items = items
+		}(i, backend)
+	}
+	wg.Wait()
+
+	result := &FanOutResult{Sources: make([]FanOutSourceResult, 0, len(results))}
+	merged := make(map[string]map[string]interface{})
+	for _, r := range results {
+		result.Sources = append(result.Sources, r.source)
+		if !r.source.Success {
+			result.Partial = true
+			continue
+		}
+		for _, item := range r.items {
+			key := fanOutItemKey(item)
+			if existing, ok := merged[key]; ok && fanOutItemScore(existing) >= fanOutItemScore(item) {
+				continue
+			}
+			item["source"] = r.source.Source
+			merged[key] = item
+		}
+	}
+
+	result.List = make([]map[string]interface{}, 0, len(merged))
+	for _, item := range merged {
+		result.List = append(result.List, item)
+	}
+	sort.SliceStable(result.List, func(i, j int) bool {
+		si, sj := fanOutItemScore(result.List[i]), fanOutItemScore(result.List[j])
+		if si != sj {
+			return si > sj
+		}
+		return fanOutItemKey(result.List[i]) < fanOutItemKey(result.List[j])
+	})
+
+	if topK, err := strconv.Atoi(original.URL.Query().Get("topK")); err == nil && topK > 0 && len(result.List) > topK {
+		result.List = result.List[:topK]
+	}
+
+	return result
+}
+
+// searchBackend 向单个后端发送检索请求并解析结果列表
+func (l *FanOutLogic) searchBackend(ctx context.Context, original *http.Request, backend FanOutBackend, body []byte) ([]map[string]interface{}, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.cfg.Timeout)
+	defer cancel()
+
+	baseURL := backend.BaseURL
+	if backend.ClientID != "" {
+		portResp, err := l.portManager.GetPort(ctx, backend.ClientID, fanOutAppName, original.Header)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to get port: %w", err)
+		}
+		baseURL = l.portManager.BuildTargetURL(portResp)
+	}
+
+	query := original.URL.Query()
+	query.Del(l.cfg.ClientIDsParam)
+	if backend.ClientID != "" {
+		query.Set("clientId", backend.ClientID)
+	}
+	targetURL := strings.TrimRight(baseURL, "/") + l.cfg.Path
+	if encoded := query.Encode(); encoded != "" {
+		targetURL += "?" + encoded
+	}
+
+	var bodyReader io.Reader
+	if len(body) > 0 {
+		backendBody, err := l.buildBackendBody(body, backend.ClientID)
+		if err != nil {
+			return nil, 0, err
+		}
+		bodyReader = bytes.NewReader(backendBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, original.Method, targetURL, bodyReader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = l.buildBackendHeader(original, backend.ClientID != "")
+	if l.forwarded.PreserveHost() {
+		req.Host = original.Host
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	items, err := parseFanOutList(respBody)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	return items, resp.StatusCode, nil
+}
+
+// buildBackendHeader 按单目标转发的规则构建后端请求头，隧道后端与动态转发一样不接收凭证类请求头
+func (l *FanOutLogic) buildBackendHeader(original *http.Request, tunnel bool) http.Header {
+	headers := original.Header.Clone()
+	headers.Del("Content-Length")
+	if !l.headers.PassThrough {
+		proxy.RestrictHeaders(headers)
+	}
+	headers = proxy.FilterHeaders(headers, l.headers.Exclude, l.headers.Override)
+	if tunnel {
+		// 策略中显式设置的头不受影响
+		proxy.StripSensitiveHeaders(headers)
+	}
+	if id := proxy.RequestID(original); id != "" {
+		headers.Set(proxy.RequestIDHeader, id)
+	}
+	l.forwarded.Apply(headers, original)
+	l.headerPolicy.ApplyRequest(headers, original)
+	return headers
+}
+
+// buildBackendBody 为 JSON 请求体替换 clientId 并移除 clientId 列表字段
+func (l *FanOutLogic) buildBackendBody(body []byte, clientID string) ([]byte, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body, nil
+	}
+	delete(payload, l.cfg.ClientIDsParam)
+	if clientID != "" {
+		payload["clientId"] = clientID
+	}
+	return json.Marshal(payload)
+}
+
+// parseFanOutList 从响应中解析检索结果列表，兼容 {data:{list}} 与 {list} 两种格式
+func parseFanOutList(body []byte) ([]map[string]interface{}, error) {
+	var payload struct {
+		List []map[string]interface{} `json:"list"`
+		Data *struct {
+			List []map[string]interface{} `json:"list"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if payload.Data != nil && payload.Data.List != nil {
+		return payload.Data.List, nil
+	}
+	return payload.List, nil
+}
+
+// fanOutItemKey 按文件路径与行范围生成去重键
+func fanOutItemKey(item map[string]interface{}) string {
+	filePath, _ := item["filePath"].(string)
+	return fmt.Sprintf("%s#%v-%v", filePath, item["startLine"], item["endLine"])
+}
+
+// fanOutItemScore 获取结果得分
+func fanOutItemScore(item map[string]interface{}) float64 {
+	score, _ := item["score"].(float64)
+	return score
+}
+
+// HasFanOutClientIDs 判断请求是否通过查询参数或 JSON 请求体携带 clientId 列表参数，参数值可以为空
+// 读取过的请求体会被重新拼接，不影响后续处理器读取
+func HasFanOutClientIDs(r *http.Request, param string) bool {
+	if r.URL.Query().Has(param) {
+		return true
+	}
+	if r.Method == http.MethodGet {
+		return false
+	}
+	body, err := proxy.PeekBody(r, maxFanOutPeekBodySize)
+	if err != nil || len(body) == 0 {
+		return false
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return false
+	}
+	_, ok := payload[param]
+	return ok
+}
+
+// ParseFanOutClientIDs 从查询参数或 JSON 请求体中解析 clientId 列表
+// 查询参数支持逗号分隔或重复传参，请求体支持字符串数组
+func ParseFanOutClientIDs(query url.Values, body []byte, param string) []string {
+	var ids []string
+	for _, value := range query[param] {
+		ids = append(ids, strings.Split(value, ",")...)
+	}
+
+	if len(body) > 0 {
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err == nil {
+			if list, ok := payload[param].([]interface{}); ok {
+				for _, v := range list {
+					if s, ok := v.(string); ok {
+						ids = append(ids, s)
+					}
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]struct{}, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		result = append(result, id)
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package logic
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+func TestParseFanOutClientIDs(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		body  string
+		want  []string
+	}{
+		{"comma separated", "clientIds=a,b", "", []string{"a", "b"}},
+		{"repeated", "clientIds=a&clientIds=b", "", []string{"a", "b"}},
+		{"body array", "", `{"clientIds":["a","b"]}`, []string{"a", "b"}},
+		{"query and body deduplicated", "clientIds=a,%20b", `{"clientIds":["b","c",1]}`, []string{"a", "b", "c"}},
+		{"empty values skipped", "clientIds=,a,", "", []string{"a"}},
+		{"invalid body ignored", "", `not json`, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ParseFanOutClientIDs(query, []byte(tt.body), "clientIds"))
+		})
+	}
+}
+
+func TestFanOutSearchMerge(t *testing.T) {
+	newBackend := func(status int, body string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/search", r.URL.Path)
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	first := newBackend(http.StatusOK, `{"data":{"list":[{"filePath":"a.go","startLine":1,"endLine":2,"score":0.5},{"filePath":"b.go","startLine":1,"endLine":2,"score":0.9}]}}`)
+	second := newBackend(http.StatusOK, `{"list":[{"filePath":"a.go","startLine":1,"endLine":2,"score":0.8},{"filePath":"c.go","startLine":3,"endLine":4,"score":0.1}]}`)
+	failing := newBackend(http.StatusInternalServerError, `{}`)
+
+	transports := proxy.NewTransportRegistry(config.TransportConfig{})
+	t.Cleanup(func() { transports.Close() })
+	cfg := &config.ProxyConfig{FanOut: config.FanOutConfig{Path: "/search", ClientIDsParam: "clientIds", Timeout: time.Second}}
+	l := NewFanOutLogic(cfg, nil, transports)
+	backends := []FanOutBackend{
+		{Source: "static:first", BaseURL: first.URL},
+		{Source: "static:second", BaseURL: second.URL},
+		{Source: "static:failing", BaseURL: failing.URL},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string // 按得分排序的 filePath@source
+	}{
+		{"merged by score", "", []string{"b.go@static:first", "a.go@static:second", "c.go@static:second"}},
+		{"topK", "topK=2", []string{"b.go@static:first", "a.go@static:second"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/aggregate?"+tt.query, nil)
+			result := l.Search(r.Context(), r, backends, nil)
+
+			got := make([]string, 0, len(result.List))
+			for _, item := range result.List {
+				got = append(got, item["filePath"].(string)+"@"+item["source"].(string))
+			}
+			assert.Equal(t, tt.want, got)
+			assert.True(t, result.Partial)
+			require.Len(t, result.Sources, len(backends))
+			assert.False(t, result.Sources[2].Success)
+			assert.Equal(t, http.StatusInternalServerError, result.Sources[2].StatusCode)
+		})
+	}
+}
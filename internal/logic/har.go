@@ -0,0 +1,278 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// harVersion 写出的 HTTP Archive 版本
+const harVersion = "1.2"
+
+// harCreator 写出 HAR 文件的程序名称
+const harCreator = "codebase-querier-proxy"
+
+// harDocument HTTP Archive 文件，录制信息以 _ 开头的自定义字段保存
+type harDocument struct {
+	Log struct {
+		Version string `json:"version"`
+		Creator struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	RequestID       string      `json:"_requestId,omitempty"`
+	Route           string      `json:"_route,omitempty"`
+	ClientID        string      `json:"_clientId,omitempty"`
+	User            string      `json:"_user,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harContent    `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size      int    `json:"size"`
+	MimeType  string `json:"mimeType"`
+	Text      string `json:"text,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
+	Truncated bool   `json:"_truncated,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harWriter 流式写出 HAR 文件，创建时写入文件头，每条记录追加写入，关闭时补全文件尾
+// 进程异常退出时文件缺少结尾，不是合法的 HAR 文件
+type harWriter struct {
+	file    *os.File
+	entries int
+}
+
+// newHARWriter 创建 HAR 文件并写入文件头
+func newHARWriter(file string) (*harWriter, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("{\n  \"log\": {\n    \"version\": %q,\n    \"creator\": {\n      \"name\": %q,\n      \"version\": %q\n    },\n    \"entries\": [",
+		harVersion, harCreator, harVersion)
+	if _, err := f.WriteString(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &harWriter{file: f}, nil
+}
+
+// write 追加写入一条录制记录
+func (w *harWriter) write(exchange *RecordedExchange) error {
+	data, err := json.MarshalIndent(toHAREntry(exchange), "      ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := "\n      "
+	if w.entries > 0 {
+		sep = "," + sep
+	}
+	if _, err := w.file.WriteString(sep); err != nil {
+		return err
+	}
+	if _, err := w.file.Write(data); err != nil {
+		return err
+	}
+	w.entries++
+	return nil
+}
+
+// close 写入文件尾并关闭文件
+func (w *harWriter) close() error {
+	tail := "]\n  }\n}\n"
+	if w.entries > 0 {
+		tail = "\n    " + tail
+	}
+	_, err := w.file.WriteString(tail)
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readHAR 读取 HAR 文件中的录制记录
+func readHAR(file string) ([]*RecordedExchange, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc harDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %w", err)
+	}
+
+	exchanges := make([]*RecordedExchange, 0, len(doc.Log.Entries))
+	for i, entry := range doc.Log.Entries {
+		exchange, err := fromHAREntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("entries[%d]: %w", i, err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	return exchanges, nil
+}
+
+func toHAREntry(exchange *RecordedExchange) harEntry {
+	duration, _ := time.ParseDuration(exchange.Duration)
+	ms := float64(duration) / float64(time.Millisecond)
+
+	host := exchange.Request.Host
+	if host == "" {
+		host = "localhost"
+	}
+	u := url.URL{Scheme: "http", Host: host, Path: exchange.Request.Path, RawQuery: exchange.Request.Query}
+
+	entry := harEntry{
+		StartedDateTime: exchange.Time,
+		Time:            ms,
+		Request: harRequest{
+			Method:      exchange.Request.Method,
+			URL:         u.String(),
+			HTTPVersion: "HTTP/1.1",
+			Headers:     toHARHeaders(exchange.Request.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(exchange.Request.Body),
+		},
+		Response: harResponse{
+			Status:      exchange.Response.Status,
+			StatusText:  http.StatusText(exchange.Response.Status),
+			HTTPVersion: "HTTP/1.1",
+			Headers:     toHARHeaders(exchange.Response.Header),
+			Content:     toHARContent(exchange.Response.Header, exchange.Response.RecordedBody),
+			HeadersSize: -1,
+			BodySize:    len(exchange.Response.Body),
+		},
+		Timings:   harTimings{Wait: ms},
+		RequestID: exchange.RequestID,
+		Route:     exchange.Route,
+		ClientID:  exchange.ClientID,
+		User:      exchange.User,
+	}
+	for name, values := range u.Query() {
+		for _, value := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: name, Value: value})
+		}
+	}
+	if exchange.Request.Body != "" {
+		content := toHARContent(exchange.Request.Header, exchange.Request.RecordedBody)
+		entry.Request.PostData = &content
+	}
+	return entry
+}
+
+func fromHAREntry(entry harEntry) (*RecordedExchange, error) {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request url: %w", err)
+	}
+	exchange := &RecordedExchange{
+		Time:      entry.StartedDateTime,
+		RequestID: entry.RequestID,
+		Route:     entry.Route,
+		ClientID:  entry.ClientID,
+		User:      entry.User,
+		Duration:  time.Duration(entry.Time * float64(time.Millisecond)).String(),
+		Request: RecordedRequest{
+			Method: entry.Request.Method,
+			Host:   u.Host,
+			Path:   u.Path,
+			Query:  u.RawQuery,
+			Header: fromHARHeaders(entry.Request.Headers),
+		},
+		Response: RecordedResponse{
+			Status:       entry.Response.Status,
+			Header:       fromHARHeaders(entry.Response.Headers),
+			RecordedBody: fromHARContent(entry.Response.Content),
+		},
+	}
+	if entry.Request.PostData != nil {
+		exchange.Request.RecordedBody = fromHARContent(*entry.Request.PostData)
+	}
+	return exchange, nil
+}
+
+func toHARHeaders(header http.Header) []harNameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]harNameValue, 0, len(names))
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func fromHARHeaders(headers []harNameValue) http.Header {
+	header := make(http.Header, len(headers))
+	for _, h := range headers {
+		header.Add(h.Name, h.Value)
+	}
+	return header
+}
+
+func toHARContent(header http.Header, body RecordedBody) harContent {
+	return harContent{
+		Size:      len(body.Body),
+		MimeType:  header.Get("Content-Type"),
+		Text:      body.Body,
+		Encoding:  body.BodyEncoding,
+		Truncated: body.Truncated,
+	}
+}
+
+func fromHARContent(content harContent) RecordedBody {
+	return RecordedBody{Body: content.Text, BodyEncoding: content.Encoding, Truncated: content.Truncated}
+}
@@ -0,0 +1,343 @@
+package logic
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// 检查结果级别
+const (
+	LintError   = "error"   // 启动失败或请求必然出错
+	LintWarning = "warning" // 配置可以加载，但部分规则不会生效
+)
+
+// LintIssue 单条检查结果
+type LintIssue struct {
+	Severity string `json:"severity"`
+	Field    string `json:"field"`
+	Message  string `json:"message"`
+}
+
+// String 返回检查结果的描述
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%-7s %s: %s", i.Severity, i.Field, i.Message)
+}
+
+// LintLogic 代理配置检查逻辑
+// 在 Validate 之上检查被遮蔽的前缀、不可达的规则、重复的路由与转发地址的协议
+type LintLogic struct {
+	cfg    *config.ProxyConfig
+	issues []LintIssue
+}
+
+// lintRule 参与可达性检查的规则
+type lintRule struct {
+	field   string
+	match   config.RuleMatchConfig
+	matcher *proxy.RequestMatcher
+}
+
+// NewLintLogic 创建配置检查逻辑实例，cfg 应已通过 Validate
+func NewLintLogic(cfg *config.ProxyConfig) *LintLogic {
+	return &LintLogic{cfg: cfg}
+}
+
+// Lint 执行全部检查
+func (l *LintLogic) Lint() []LintIssue {
+	l.issues = nil
+	if l.cfg == nil {
+		l.warnf("proxy_config", "not configured, the gateway serves health checks only")
+		return l.issues
+	}
+
+	l.lintRoutes()
+	l.lintStripPrefixes()
+	l.lintRules(l.forwardRules())
+	l.lintRules(l.splitRules())
+	l.lintRules(l.shadowRules())
+	l.lintFallback()
+	l.lintURLs()
+	return l.issues
+}
+
+func (l *LintLogic) errorf(field, format string, args ...interface{}) {
+	l.issues = append(l.issues, LintIssue{Severity: LintError, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *LintLogic) warnf(field, format string, args ...interface{}) {
+	l.issues = append(l.issues, LintIssue{Severity: LintWarning, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// lintRoutes 检查重复的路由与路径格式，路由按路径精确注册，重复注册会导致启动失败
+func (l *LintLogic) lintRoutes() {
+	seen := make(map[string]int, len(l.cfg.Routes))
+	for i, route := range l.cfg.Routes {
+		field := fmt.Sprintf("routes[%d].path_prefix", i)
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			l.errorf(field, "%s must start with '/'", route.PathPrefix)
+		}
+		if j, ok := seen[route.PathPrefix]; ok {
+			l.errorf(field, "duplicated route %s, already defined by routes[%d]", route.PathPrefix, j)
+			continue
+		}
+		seen[route.PathPrefix] = i
+	}
+}
+
+// lintStripPrefixes 按顺序去除前缀，前面的前缀覆盖后面的前缀时后者永远不会生效
+func (l *LintLogic) lintStripPrefixes() {
+	prefixes := l.cfg.Rewrite.StripPrefixes
+	for i, prefix := range prefixes {
+		for j := 0; j < i; j++ {
+			prev := strings.TrimRight(prefixes[j], "/")
+			if prefix == prefixes[j] || strings.HasPrefix(prefix, prev+"/") {
+				l.warnf(fmt.Sprintf("rewrite.strip_prefixes[%d]", i), "%s is shadowed by rewrite.strip_prefixes[%d] (%s)", prefix, j, prefixes[j])
+				break
+			}
+		}
+	}
+}
+
+// lintRules 检查按顺序匹配的规则：被前面规则完全覆盖的规则，以及路径不对应任何路由的规则都不会命中
+func (l *LintLogic) lintRules(rules []lintRule) {
+	for i, rule := range rules {
+		for j := 0; j < i; j++ {
+			if covers(rules[j].match, rule.match) {
+				l.warnf(rule.field, "unreachable: every request it matches is matched by %s first", rules[j].field)
+				break
+			}
+		}
+		if rule.match.Path != "" && !l.reachable(rule.matcher) {
+			l.warnf(rule.field, "unreachable: path %s (%s) matches no route path_prefix", rule.match.Path, rule.match.PathType)
+		}
+	}
+}
+
+// lintFallback 启用转发规则引擎后未命中规则的请求都转发到 forward_rules.default，路由回退链不会生效
+func (l *LintLogic) lintFallback() {
+	if !l.cfg.ForwardRules.Enabled {
+		return
+	}
+	for i, route := range l.cfg.Routes {
+		if len(route.Fallback.Chain) > 0 {
+			l.warnf(fmt.Sprintf("routes[%d].fallback", i), "never used: requests not matched by a forward rule go to forward_rules.default")
+		}
+	}
+}
+
+// reachable 规则路径是否匹配某个路由，只有路由的请求会进入转发规则；路由含路径参数时无法判断，视为可达
+func (l *LintLogic) reachable(matcher *proxy.RequestMatcher) bool {
+	for _, route := range l.cfg.Routes {
+		if strings.ContainsAny(route.PathPrefix, ":*") || matcher.MatchPath(route.PathPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardRules 返回转发规则与 header_based_forward 生成的兼容规则，顺序与规则引擎一致
+func (l *LintLogic) forwardRules() []lintRule {
+	var rules []lintRule
+	if l.cfg.ForwardRules.Enabled {
+		for i, rule := range l.cfg.ForwardRules.Rules {
+			rules = l.appendRule(rules, fmt.Sprintf("forward_rules.rules[%d]", i), rule.Match)
+		}
+	}
+	if l.cfg.HeaderBasedForward.Enabled {
+		for i, path := range l.cfg.HeaderBasedForward.Paths {
+			rules = l.appendRule(rules, fmt.Sprintf("header_based_forward.paths[%d]", i), config.RuleMatchConfig{Path: path.Path})
+		}
+	}
+	return rules
+}
+
+func (l *LintLogic) splitRules() []lintRule {
+	var rules []lintRule
+	if l.cfg.TrafficSplit.Enabled {
+		for i, split := range l.cfg.TrafficSplit.Splits {
+			rules = l.appendRule(rules, fmt.Sprintf("traffic_split.splits[%d]", i), split.Match)
+		}
+	}
+	return rules
+}
+
+func (l *LintLogic) shadowRules() []lintRule {
+	var rules []lintRule
+	if l.cfg.Shadow.Enabled {
+		for i, rule := range l.cfg.Shadow.Rules {
+			rules = l.appendRule(rules, fmt.Sprintf("shadow.rules[%d]", i), rule.Match)
+		}
+	}
+	return rules
+}
+
+func (l *LintLogic) appendRule(rules []lintRule, field string, match config.RuleMatchConfig) []lintRule {
+	if match.PathType == "" {
+		match.PathType = config.PathMatchExact
+	}
+	matcher, err := proxy.NewRequestMatcher(match)
+	if err != nil {
+		l.errorf(field, "%v", err)
+		return rules
+	}
+	return append(rules, lintRule{field: field, match: match, matcher: matcher})
+}
+
+// covers 前一条规则 prev 是否匹配 next 能匹配的全部请求
+func covers(prev, next config.RuleMatchConfig) bool {
+	if !coversPath(prev, next) {
+		return false
+	}
+	if len(prev.Methods) > 0 {
+		if len(next.Methods) == 0 || !subset(next.Methods, prev.Methods) {
+			return false
+		}
+	}
+	if !valueMatchesSubset(prev.Headers, next.Headers) || !valueMatchesSubset(prev.Query, next.Query) {
+		return false
+	}
+	if prev.Version != "" && (prev.Version != next.Version || !strings.EqualFold(prev.VersionHeader, next.VersionHeader)) {
+		return false
+	}
+	return true
+}
+
+// coversPath prev 的路径条件是否包含 next 的路径条件
+func coversPath(prev, next config.RuleMatchConfig) bool {
+	if prev.Path == "" {
+		return true
+	}
+	if next.Path == "" {
+		return false
+	}
+	if prev.PathType == next.PathType && prev.Path == next.Path {
+		return true
+	}
+	switch prev.PathType {
+	case config.PathMatchPrefix:
+		return (next.PathType == config.PathMatchExact || next.PathType == config.PathMatchPrefix) && strings.HasPrefix(next.Path, prev.Path)
+	case config.PathMatchGlob, config.PathMatchRegex:
+		if next.PathType != config.PathMatchExact {
+			return false
+		}
+		matcher, err := proxy.NewRequestMatcher(config.RuleMatchConfig{Path: prev.Path, PathType: prev.PathType})
+		return err == nil && matcher.MatchPath(next.Path)
+	}
+	return false
+}
+
+// valueMatchesSubset prev 的每个条件都出现在 next 中时，next 匹配的请求一定满足 prev
+func valueMatchesSubset(prev, next []config.ValueMatchConfig) bool {
+	for _, p := range prev {
+		found := false
+		for _, n := range next {
+			if strings.EqualFold(p.Name, n.Name) && p.Op == n.Op && p.Value == n.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func subset(items, set []string) bool {
+	for _, item := range items {
+		found := false
+		for _, s := range set {
+			if strings.EqualFold(item, s) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// lintURLs 检查转发地址必须是带主机的 http 或 https 地址
+func (l *LintLogic) lintURLs() {
+	for i, route := range l.cfg.Routes {
+		l.checkURL(fmt.Sprintf("routes[%d].target.url", i), route.Target.URL)
+		for j, page := range route.ErrorPages {
+			if page.Type == config.ErrorPageRedirect {
+				l.checkURL(fmt.Sprintf("routes[%d].error_pages[%d].url", i, j), page.URL)
+			}
+		}
+	}
+	if l.cfg.ForwardURL != "" {
+		l.checkURL("forward_url", l.cfg.ForwardURL)
+	}
+	if l.cfg.DynamicPort {
+		l.checkURL("port_manager.url", l.cfg.PortManager.URL)
+	}
+	if forwardURL := l.cfg.PortManager.ForwardURL; forwardURL != "" {
+		if l.checkURL("port_manager.forward_url", forwardURL) {
+			// 隧道端口直接拼接在 forward_url 之后
+			if u, _ := url.Parse(forwardURL); u.Port() != "" || strings.Trim(u.Path, "/") != "" {
+				l.errorf("port_manager.forward_url", "%s must not contain a port or path, the tunnel port is appended to it", forwardURL)
+			}
+		}
+	}
+	if l.cfg.HeaderBasedForward.Enabled {
+		for i, path := range l.cfg.HeaderBasedForward.Paths {
+			l.checkURL(fmt.Sprintf("header_based_forward.paths[%d].with_header_url", i), path.WithHeaderURL)
+			l.checkURL(fmt.Sprintf("header_based_forward.paths[%d].without_header_url", i), path.WithoutHeaderURL)
+		}
+	}
+	if l.cfg.ForwardRules.Enabled {
+		for i, rule := range l.cfg.ForwardRules.Rules {
+			if rule.Target.Type == config.TargetTypeURL {
+				l.checkURL(fmt.Sprintf("forward_rules.rules[%d].target.url", i), rule.Target.URL)
+			}
+		}
+	}
+	if l.cfg.TrafficSplit.Enabled {
+		for i, split := range l.cfg.TrafficSplit.Splits {
+			for j, backend := range split.Backends {
+				if backend.Target.Type == config.TargetTypeURL {
+					l.checkURL(fmt.Sprintf("traffic_split.splits[%d].backends[%d].target.url", i, j), backend.Target.URL)
+				}
+			}
+			if split.Mirror.URL != "" {
+				l.checkURL(fmt.Sprintf("traffic_split.splits[%d].mirror.url", i), split.Mirror.URL)
+			}
+		}
+	}
+	if l.cfg.Shadow.Enabled {
+		for i, rule := range l.cfg.Shadow.Rules {
+			l.checkURL(fmt.Sprintf("shadow.rules[%d].url", i), rule.URL)
+		}
+	}
+	if l.cfg.FanOut.Enabled {
+		for i, target := range l.cfg.FanOut.Targets {
+			l.checkURL(fmt.Sprintf("fan_out.targets[%d]", i), target)
+		}
+	}
+}
+
+// checkURL 检查地址的协议与主机，返回地址是否有效
+func (l *LintLogic) checkURL(field, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		l.errorf(field, "invalid url %s: %v", rawURL, err)
+		return false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		l.errorf(field, "%s must use http or https scheme", rawURL)
+		return false
+	}
+	if u.Host == "" {
+		l.errorf(field, "%s has no host", rawURL)
+		return false
+	}
+	return true
+}
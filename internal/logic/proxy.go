@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
 )
 
 // ProxyConfig 代理配置
@@ -25,14 +27,17 @@ type TargetConfig struct {
 
 // RewriteConfig 路径重写配置
 type RewriteConfig struct {
-	Enabled bool          `json:"enabled" yaml:"enabled"`
-	Rules   []RewriteRule `json:"rules" yaml:"rules"`
+	Enabled       bool          `json:"enabled" yaml:"enabled"`
+	StripPrefixes []string      `json:"strip_prefixes" yaml:"strip_prefixes"`
+	Rules         []RewriteRule `json:"rules" yaml:"rules"`
 }
 
 // RewriteRule 重写规则
 type RewriteRule struct {
-	From string `json:"from" yaml:"from"`
-	To   string `json:"to" yaml:"to"`
+	Type     string            `json:"type" yaml:"type"`
+	From     string            `json:"from" yaml:"from"`
+	To       string            `json:"to" yaml:"to"`
+	SetQuery map[string]string `json:"set_query" yaml:"set_query"`
 }
 
 // HeadersConfig Header配置
@@ -42,43 +47,30 @@ type HeadersConfig struct {
 	Override    map[string]string `json:"override" yaml:"override"`
 }
 
-// ProxyError 代理错误响应
-type ProxyError struct {
-	Code      string    `json:"code"`
-	Message   string    `json:"message"`
-	Details   string    `json:"details,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
-}
-
-// Error 实现error接口
-func (e *ProxyError) Error() string {
-	return e.Message
-}
-
-// 错误码常量
-const (
-	ErrorCodeBadRequest        = "PROXY_BAD_REQUEST"
-	ErrorCodeTargetUnreachable = "PROXY_TARGET_UNREACHABLE"
-	ErrorCodeTimeout           = "PROXY_TIMEOUT"
-	ErrorCodeInternalError     = "PROXY_INTERNAL_ERROR"
-)
-
-// RewriteRule 路径重写规则
-type UtilsRewriteRule struct {
-	From string
-	To   string
-}
-
 // ProxyLogic 代理转发逻辑
 type ProxyLogic struct {
-	cfg    *ProxyConfig
-	client *http.Client
+	cfg      *ProxyConfig
+	client   *http.Client
+	rewriter *proxy.Rewriter
 }
 
 // NewProxyLogic 创建代理逻辑实例
 func NewProxyLogic(cfg *ProxyConfig) *ProxyLogic {
+	rules := make([]proxy.RewriteRule, len(cfg.Rewrite.Rules))
+	for i, rule := range cfg.Rewrite.Rules {
+		rules[i] = proxy.RewriteRule{
+			Type:     rule.Type,
+			From:     rule.From,
+			To:       rule.To,
+			SetQuery: rule.SetQuery,
+		}
+	}
+	rewriter, err := proxy.NewRewriter(cfg.Rewrite.StripPrefixes, rules)
+	logx.Must(err)
+
 	return &ProxyLogic{
-		cfg: cfg,
+		cfg:      cfg,
+		rewriter: rewriter,
 		client: &http.Client{
 			Timeout: cfg.Target.Timeout,
 			Transport: &http.Transport{
@@ -92,25 +84,20 @@ func NewProxyLogic(cfg *ProxyConfig) *ProxyLogic {
 
 // Forward 执行请求转发
 func (l *ProxyLogic) Forward(ctx context.Context, original *http.Request) (*http.Response, error) {
-	logx.Infof("Starting to forward request: %s %s", original.Method, original.URL.Path)
+	logx.WithContext(ctx).Infof("Starting to forward request: %s %s", original.Method, original.URL.Path)
 
 	targetReq, err := l.buildTargetRequest(ctx, original)
 	if err != nil {
-		logx.Errorf("Failed to build target request: %v", err)
+		logx.WithContext(ctx).Errorf("Failed to build target request: %v", err)
 		return nil, err
 	}
 
 	resp, err := l.client.Do(targetReq)
 	if err != nil {
-		if urlErr, ok := err.(*url.Error); ok {
-			if urlErr.Timeout() {
-				return nil, newTimeoutError(urlErr.Error())
-			}
-		}
-		return nil, newTargetUnreachableError(err.Error())
+		return nil, errs.FromUpstream(err)
 	}
 
-	logx.Infof("Successfully forwarded request, status: %d", resp.StatusCode)
+	logx.WithContext(ctx).Infof("Successfully forwarded request, status: %d", resp.StatusCode)
 	return resp, nil
 }
 
@@ -119,27 +106,19 @@ func (l *ProxyLogic) buildTargetRequest(ctx context.Context, original *http.Requ
 	// 解析目标URL
 	targetURL, err := url.Parse(l.cfg.Target.URL)
 	if err != nil {
-		return nil, newInternalError("invalid target URL: " + err.Error())
+		return nil, errs.NewProxyError(errs.CodeInternal, "invalid target URL: "+err.Error())
 	}
 
 	// 构建目标路径
 	targetPath := original.URL.Path
+	rawQuery := original.URL.RawQuery
 
 	// 移除代理前缀
-	if strings.HasPrefix(targetPath, "/proxy") {
-		targetPath = strings.TrimPrefix(targetPath, "/proxy")
-	}
+	targetPath = l.rewriter.StripPrefix(targetPath)
 
 	// 应用路径重写规则
 	if l.cfg.Rewrite.Enabled {
-		rules := make([]UtilsRewriteRule, len(l.cfg.Rewrite.Rules))
-		for i, rule := range l.cfg.Rewrite.Rules {
-			rules[i] = UtilsRewriteRule{
-				From: rule.From,
-				To:   rule.To,
-			}
-		}
-		targetPath = rewritePath(targetPath, rules)
+		targetPath, rawQuery = l.rewriter.Rewrite(targetPath, rawQuery)
 	}
 
 	// 清理路径
@@ -147,19 +126,19 @@ func (l *ProxyLogic) buildTargetRequest(ctx context.Context, original *http.Requ
 
 	// 构建完整URL
 	fullURL := joinPath(targetURL.String(), targetPath)
-	if original.URL.RawQuery != "" {
-		fullURL += "?" + original.URL.RawQuery
+	if rawQuery != "" {
+		fullURL += "?" + rawQuery
 	}
 
 	// 创建新请求
 	targetURL, err = url.Parse(fullURL)
 	if err != nil {
-		return nil, newInternalError("failed to parse target URL: " + err.Error())
+		return nil, errs.NewProxyError(errs.CodeInternal, "failed to parse target URL: "+err.Error())
 	}
 
 	targetReq, err := http.NewRequestWithContext(ctx, original.Method, targetURL.String(), original.Body)
 	if err != nil {
-		return nil, newInternalError("failed to create target request: " + err.Error())
+		return nil, errs.NewProxyError(errs.CodeInternal, "failed to create target request: "+err.Error())
 	}
 
 	// 复制并过滤header
@@ -210,15 +189,6 @@ func (l *ProxyLogic) Close() error {
 }
 
 // 工具函数实现
-func rewritePath(path string, rules []UtilsRewriteRule) string {
-	for _, rule := range rules {
-		if strings.HasPrefix(path, rule.From) {
-			return strings.Replace(path, rule.From, rule.To, 1)
-		}
-	}
-	return path
-}
-
 func cleanPath(path string) string {
 	if path == "" {
 		return "/"
@@ -320,30 +290,3 @@ func removeConnectionHeaders(headers http.Header) {
 		}
 	}
 }
-
-func newTimeoutError(details string) *ProxyError {
-	return &ProxyError{
-		Code:      ErrorCodeTimeout,
-		Message:   "Request timeout",
-		Details:   details,
-		Timestamp: time.Now().UTC(),
-	}
-}
-
-func newTargetUnreachableError(details string) *ProxyError {
-	return &ProxyError{
-		Code:      ErrorCodeTargetUnreachable,
-		Message:   "Target service is unreachable",
-		Details:   details,
-		Timestamp: time.Now().UTC(),
-	}
-}
-
-func newInternalError(details string) *ProxyError {
-	return &ProxyError{
-		Code:      ErrorCodeInternalError,
-		Message:   "Internal server error",
-		Details:   details,
-		Timestamp: time.Now().UTC(),
-	}
-}
@@ -0,0 +1,229 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// 就绪检查状态
+const (
+	ReadinessOK   = "ok"
+	ReadinessFail = "fail"
+)
+
+// ReadinessCheck 单项就绪检查结果
+type ReadinessCheck struct {
+	Name     string           `json:"name"`
+	Status   string           `json:"status"`
+	Target   string           `json:"target,omitempty"`
+	Duration string           `json:"duration,omitempty"`
+	Details  string           `json:"details,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Targets  []ReadinessCheck `json:"targets,omitempty"` // 路由各目标的检查结果
+}
+
+// ReadinessReport 就绪检查报告
+type ReadinessReport struct {
+	Status string                 `json:"status"`
+	Checks []ReadinessCheck       `json:"checks,omitempty"`
+	Pools  []proxy.TransportStats `json:"pools,omitempty"` // 转发连接池统计，仅详细输出时返回
+}
+
+// Ready 所有检查是否都通过
+func (r ReadinessReport) Ready() bool {
+	return r.Status == ReadinessOK
+}
+
+// ReadinessLogic 就绪检查逻辑
+// 检查配置是否加载、服务是否正在停机、端口管理器是否可达，以及每个 required 路由是否至少有一个可达的目标
+type ReadinessLogic struct {
+	cfg         *config.ProxyConfig
+	health      config.HealthConfig
+	portManager *proxy.PortManager
+	drainer     *proxy.Drainer
+	client      *http.Client
+}
+
+// NewReadinessLogic 创建就绪检查逻辑实例，cfg 为 nil 时配置检查不通过
+// 探测请求复用 transports 的连接池，连接池由创建方关闭
+func NewReadinessLogic(cfg *config.ProxyConfig, portManager *proxy.PortManager, drainer *proxy.Drainer, transports *proxy.TransportRegistry) *ReadinessLogic {
+	var health config.HealthConfig
+	if cfg != nil {
+		health = cfg.Health
+	}
+	if health.Timeout == 0 {
+		health.Timeout = config.DefaultHealthTimeout
+	}
+	if health.Path == "" {
+		health.Path = config.DefaultHealthPath
+	}
+	if health.PortManagerPath == "" {
+		health.PortManagerPath = health.Path
+	}
+
+	return &ReadinessLogic{
+		cfg:         cfg,
+		health:      health,
+		portManager: portManager,
+		drainer:     drainer,
+		client: &http.Client{
+			Transport: transports,
+			// 探测时不跟随重定向，3xx 即视为可达
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Check 执行全部就绪检查，各项检查并发执行
+func (l *ReadinessLogic) Check(ctx context.Context) ReadinessReport {
+	checks := []ReadinessCheck{l.checkConfig(), l.checkShutdown()}
+	if l.cfg == nil {
+		return newReadinessReport(checks)
+	}
+
+	var probes []func() ReadinessCheck
+	if l.portManager != nil && !l.health.SkipPortManager {
+		probes = append(probes, func() ReadinessCheck { return l.checkPortManager(ctx) })
+	}
+	for _, route := range l.cfg.Routes {
+		if route.Required {
+			route := route
+			probes = append(probes, func() ReadinessCheck { return l.checkRoute(ctx, route) })
+		}
+	}
+
+	results := make([]ReadinessCheck, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, probe func() ReadinessCheck) {
+			defer wg.Done()
+			results[i] = probe()
+		}(i, probe)
+	}
+	wg.Wait()
+
+	return newReadinessReport(append(checks, results...))
+}
+
+// checkConfig 检查代理配置是否加载
+func (l *ReadinessLogic) checkConfig() ReadinessCheck {
+	check := ReadinessCheck{Name: "config", Status: ReadinessOK}
+	if l.cfg == nil {
+		check.Status = ReadinessFail
+		check.Error = "proxy_config not configured"
+		return check
+	}
+	check.Details = fmt.Sprintf("mode=%s, routes=%d, dynamic_port=%t", l.cfg.Mode, len(l.cfg.Routes), l.cfg.DynamicPort)
+	return check
+}
+
+// checkShutdown 收到停机信号后不再就绪
+func (l *ReadinessLogic) checkShutdown() ReadinessCheck {
+	check := ReadinessCheck{Name: "shutdown", Status: ReadinessOK}
+	if l.drainer != nil && !l.drainer.Ready() {
+		check.Status = ReadinessFail
+		check.Error = fmt.Sprintf("shutting down, %d in-flight requests", l.drainer.Inflight())
+	}
+	return check
+}
+
+// checkPortManager 检查端口管理器是否可达
+func (l *ReadinessLogic) checkPortManager(ctx context.Context) ReadinessCheck {
+	check := ReadinessCheck{Name: "port_manager", Status: ReadinessOK, Target: l.cfg.PortManager.URL}
+	ctx, cancel := context.WithTimeout(ctx, l.health.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := l.portManager.Ping(ctx, l.health.PortManagerPath)
+	check.Duration = time.Since(start).String()
+	if err != nil {
+		check.Status = ReadinessFail
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// checkRoute 检查路由是否至少有一个可达的目标，回退链包含 static 时 forward_url 也算作目标
+func (l *ReadinessLogic) checkRoute(ctx context.Context, route config.RouteConfig) ReadinessCheck {
+	check := ReadinessCheck{Name: "route:" + route.PathPrefix, Status: ReadinessFail}
+	if route.Name != "" {
+		check.Name = "route:" + route.Name
+	}
+
+	for _, target := range l.routeTargets(route) {
+		result := l.Probe(ctx, target)
+		if result.Status == ReadinessOK {
+			check.Status = ReadinessOK
+		}
+		check.Targets = append(check.Targets, result)
+	}
+	if check.Status == ReadinessFail {
+		check.Error = "no healthy target"
+	}
+	return check
+}
+
+// routeTargets 返回路由的候选目标地址
+func (l *ReadinessLogic) routeTargets(route config.RouteConfig) []string {
+	targets := []string{route.Target.URL}
+	for _, strategy := range route.Fallback.Chain {
+		if strategy == config.StrategyStatic && l.cfg.ForwardURL != "" && l.cfg.ForwardURL != route.Target.URL {
+			targets = append(targets, l.cfg.ForwardURL)
+		}
+	}
+	return targets
+}
+
+// Probe 请求目标的健康检查路径，响应状态码小于 500 视为可达
+func (l *ReadinessLogic) Probe(ctx context.Context, target string) ReadinessCheck {
+	check := ReadinessCheck{Name: "target", Status: ReadinessOK, Target: target}
+	ctx, cancel := context.WithTimeout(ctx, l.health.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(target, "/")+l.health.Path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := l.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		return nil
+	}()
+	check.Duration = time.Since(start).String()
+	if err != nil {
+		check.Status = ReadinessFail
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// newReadinessReport 汇总检查结果，任一检查失败即不就绪
+func newReadinessReport(checks []ReadinessCheck) ReadinessReport {
+	report := ReadinessReport{Status: ReadinessOK, Checks: checks}
+	for _, check := range checks {
+		if check.Status != ReadinessOK {
+			report.Status = ReadinessFail
+			break
+		}
+	}
+	return report
+}
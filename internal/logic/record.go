@@ -0,0 +1,240 @@
+package logic
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/metrics"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// 录制结果常量
+const (
+	RecordResultRecorded = "recorded"
+	RecordResultDropped  = "dropped"
+	RecordResultSkipped  = "skipped"
+)
+
+// bodyEncodingBase64 非 UTF-8 内容以 base64 编码保存
+const bodyEncodingBase64 = "base64"
+
+// RecordedExchange 录制的一次请求与响应
+type RecordedExchange struct {
+	Time      time.Time        `json:"time"`
+	RequestID string           `json:"requestId,omitempty"`
+	Route     string           `json:"route,omitempty"`
+	ClientID  string           `json:"clientId,omitempty"`
+	User      string           `json:"user,omitempty"`
+	Duration  string           `json:"duration"`
+	Request   RecordedRequest  `json:"request"`
+	Response  RecordedResponse `json:"response"`
+}
+
+// logger 返回带请求 ID 字段的日志记录器
+func (e *RecordedExchange) logger() logx.Logger {
+	return logx.WithContext(proxy.WithRequestID(context.Background(), e.RequestID))
+}
+
+// RecordedRequest 录制的请求
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Host   string      `json:"host,omitempty"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	RecordedBody
+}
+
+// RecordedResponse 录制的响应
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	RecordedBody
+}
+
+// RecordedBody 录制的请求体或响应体
+type RecordedBody struct {
+	Body         string `json:"body,omitempty"`
+	BodyEncoding string `json:"bodyEncoding,omitempty"` // 为 base64 时 Body 为 base64 编码
+	Truncated    bool   `json:"truncated,omitempty"`    // 超过 max_body_bytes 被截断
+}
+
+// NewRecordedBody 保存内容，非 UTF-8 内容以 base64 编码
+func NewRecordedBody(body []byte, truncated bool) RecordedBody {
+	if utf8.Valid(body) {
+		return RecordedBody{Body: string(body), Truncated: truncated}
+	}
+	return RecordedBody{Body: base64.StdEncoding.EncodeToString(body), BodyEncoding: bodyEncodingBase64, Truncated: truncated}
+}
+
+// Bytes 返回解码后的内容
+func (b RecordedBody) Bytes() ([]byte, error) {
+	if b.BodyEncoding == bodyEncodingBase64 {
+		return base64.StdEncoding.DecodeString(b.Body)
+	}
+	return []byte(b.Body), nil
+}
+
+// RecordLogic 流量录制逻辑
+// 按路由、clientId 与用户过滤请求，通过有界队列异步写入录制文件，队列满时直接丢弃，保证不阻塞主请求
+type RecordLogic struct {
+	cfg            config.RecordConfig
+	userInfoHeader string
+	routes         map[string]bool
+	clientIDs      map[string]bool
+	users          map[string]bool
+	redact         []string
+	queue          chan *RecordedExchange
+	file           *os.File   // jsonl 格式的录制文件
+	har            *harWriter // har 格式的录制文件
+	count          int
+	queueMu        sync.RWMutex // 保护 closed，防止向已关闭的队列发送
+	closed         bool
+	done           chan struct{}
+	closeOnce      sync.Once
+}
+
+// NewRecordLogic 创建流量录制逻辑实例并启动写入协程，配置应已通过 ProxyConfig.Validate 填充默认值
+func NewRecordLogic(cfg config.RecordConfig, userInfoHeader string) *RecordLogic {
+	l := &RecordLogic{
+		cfg:            cfg,
+		userInfoHeader: userInfoHeader,
+		routes:         utils.SliceToSet(cfg.Routes),
+		clientIDs:      utils.SliceToSet(cfg.ClientIDs),
+		users:          utils.SliceToSet(cfg.Users),
+		redact:         append([]string{userInfoHeader}, cfg.RedactHeaders...),
+		queue:          make(chan *RecordedExchange, cfg.QueueSize),
+		done:           make(chan struct{}),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.File), 0o755); err != nil {
+		logx.Errorf("Failed to create record dir: %v", err)
+	} else if cfg.Format == config.RecordFormatHAR {
+		w, err := newHARWriter(cfg.File)
+		if err != nil {
+			logx.Errorf("Failed to open record file %s: %v", cfg.File, err)
+		}
+		l.har = w
+	} else {
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			logx.Errorf("Failed to open record file %s: %v", cfg.File, err)
+		}
+		l.file = f
+	}
+
+	go l.worker()
+	logx.Infof("Recording proxied traffic to %s (%s)", cfg.File, cfg.Format)
+	return l
+}
+
+// MaxBodyBytes 返回录制请求体与响应体的最大字节数
+func (l *RecordLogic) MaxBodyBytes() int64 {
+	return l.cfg.MaxBodyBytes
+}
+
+// Match 请求是否命中录制的路由、clientId 与用户过滤条件，未配置的条件视为命中
+func (l *RecordLogic) Match(r *http.Request, route config.RouteConfig) bool {
+	if len(l.routes) > 0 && !l.routes[route.PathPrefix] && (route.Name == "" || !l.routes[route.Name]) {
+		return false
+	}
+	if len(l.clientIDs) > 0 && !l.clientIDs[proxy.PeekClientID(r)] {
+		return false
+	}
+	if len(l.users) > 0 && !l.users[utils.ParseJWTUserInfo(r, l.userInfoHeader)] {
+		return false
+	}
+	return true
+}
+
+// User 返回请求的用户名
+func (l *RecordLogic) User(r *http.Request) string {
+	return utils.ParseJWTUserInfo(r, l.userInfoHeader)
+}
+
+// Record 隐藏敏感请求头后提交写入，队列满或已关闭时丢弃并返回 false
+func (l *RecordLogic) Record(exchange *RecordedExchange) bool {
+	exchange.Request.Header = proxy.RedactHeaders(exchange.Request.Header, l.redact...)
+	exchange.Response.Header = proxy.RedactHeaders(exchange.Response.Header, l.redact...)
+	l.queueMu.RLock()
+	defer l.queueMu.RUnlock()
+	if l.closed {
+		exchange.logger().Infof("Record logic closed, dropping request %s %s", exchange.Request.Method, exchange.Request.Path)
+		metrics.RecordedRequests.Inc(RecordResultDropped)
+		return false
+	}
+	select {
+	case l.queue <- exchange:
+		return true
+	default:
+		exchange.logger().Errorf("Record queue full, dropping request %s %s", exchange.Request.Method, exchange.Request.Path)
+		metrics.RecordedRequests.Inc(RecordResultDropped)
+		return false
+	}
+}
+
+// Close 停止录制，写完队列中的记录后关闭录制文件，har 格式在此时补全文件尾
+func (l *RecordLogic) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		l.queueMu.Lock()
+		l.closed = true
+		close(l.queue)
+		l.queueMu.Unlock()
+		<-l.done
+		if l.har != nil {
+			err = l.har.close()
+		}
+		if l.file != nil {
+			err = l.file.Close()
+		}
+		logx.Infof("Recorded %d requests to %s", l.count, l.cfg.File)
+	})
+	return err
+}
+
+func (l *RecordLogic) worker() {
+	defer close(l.done)
+	for exchange := range l.queue {
+		if l.count >= l.cfg.MaxEntries {
+			metrics.RecordedRequests.Inc(RecordResultSkipped)
+			continue
+		}
+		l.count++
+		if l.count == l.cfg.MaxEntries {
+			logx.Infof("Recorded %d requests, max_entries reached, recording stopped", l.count)
+		}
+		metrics.RecordedRequests.Inc(RecordResultRecorded)
+		l.write(exchange)
+	}
+}
+
+// write 将记录追加写入录制文件
+func (l *RecordLogic) write(exchange *RecordedExchange) {
+	if l.har != nil {
+		if err := l.har.write(exchange); err != nil {
+			exchange.logger().Errorf("Failed to write record: %v", err)
+		}
+		return
+	}
+	if l.file == nil {
+		return
+	}
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		exchange.logger().Errorf("Failed to write record: %v", err)
+	}
+}
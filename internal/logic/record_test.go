@@ -0,0 +1,75 @@
+package logic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+func newTestRecordLogic(t *testing.T, format string) (*RecordLogic, string) {
+	file := filepath.Join(t.TempDir(), "record."+format)
+	return NewRecordLogic(config.RecordConfig{File: file, Format: format, MaxEntries: 100, QueueSize: 1}, "x-userinfo"), file
+}
+
+func newTestExchange() *RecordedExchange {
+	return &RecordedExchange{Request: RecordedRequest{Method: "GET", Path: "/"}, Response: RecordedResponse{Status: 200}}
+}
+
+func TestRecordAfterClose(t *testing.T) {
+	l, _ := newTestRecordLogic(t, config.RecordFormatJSONL)
+
+	// 关闭期间并发提交不能向已关闭的队列发送
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Record(newTestExchange())
+		}()
+	}
+	assert.NoError(t, l.Close())
+	wg.Wait()
+
+	assert.False(t, l.Record(newTestExchange()))
+}
+
+func TestRecordHARStreaming(t *testing.T) {
+	l, file := newTestRecordLogic(t, config.RecordFormatHAR)
+
+	paths := []string{"/first", "/second"}
+	for _, path := range paths {
+		exchange := newTestExchange()
+		exchange.Request.Path = path
+		require.True(t, l.Record(exchange))
+		// 记录在关闭前即写入文件，不在内存中累积
+		assert.Eventually(t, func() bool {
+			data, _ := os.ReadFile(file)
+			return strings.Contains(string(data), path)
+		}, time.Second, 10*time.Millisecond)
+	}
+	require.NoError(t, l.Close())
+
+	exchanges, err := readHAR(file)
+	require.NoError(t, err)
+	require.Len(t, exchanges, len(paths))
+	for i, path := range paths {
+		assert.Equal(t, path, exchanges[i].Request.Path)
+		assert.Equal(t, 200, exchanges[i].Response.Status)
+	}
+}
+
+func TestRecordHAREmpty(t *testing.T) {
+	l, file := newTestRecordLogic(t, config.RecordFormatHAR)
+	require.NoError(t, l.Close())
+
+	exchanges, err := readHAR(file)
+	require.NoError(t, err)
+	assert.Empty(t, exchanges)
+}
@@ -0,0 +1,199 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// 回放结果常量，与影子流量比对结果一致
+const (
+	ReplayResultMatch          = ShadowResultMatch
+	ReplayResultStatusMismatch = ShadowResultStatusMismatch
+	ReplayResultBodyMismatch   = ShadowResultBodyMismatch
+	ReplayResultError          = ShadowResultError
+	ReplayResultSkipped        = "skipped"
+)
+
+// ReplayHeader 回放请求标识头
+const ReplayHeader = "X-Proxy-Replay"
+
+// ReplayResult 单条录制记录的回放结果
+type ReplayResult struct {
+	RequestID      string `json:"requestId,omitempty"`
+	Method         string `json:"method"`
+	Path           string `json:"path"`
+	Result         string `json:"result"`
+	RecordedStatus int    `json:"recordedStatus"`
+	Status         int    `json:"status,omitempty"`
+	DiffPath       string `json:"diffPath,omitempty"`
+	Error          string `json:"error,omitempty"`
+	Duration       string `json:"duration"`
+}
+
+// ReplayLogic 回放逻辑
+// 将录制的请求依次发送到目标地址，并与录制的响应比对
+type ReplayLogic struct {
+	target       string
+	header       http.Header
+	ignoreFields map[string]struct{}
+	client       *http.Client
+}
+
+// NewReplayLogic 创建回放逻辑实例
+// header 覆盖录制的请求头，用于补充录制时被隐藏的凭据；ignoreFields 为比对 JSON 时忽略的字段名
+func NewReplayLogic(target string, header http.Header, ignoreFields []string, timeout time.Duration) *ReplayLogic {
+	l := &ReplayLogic{
+		target:       strings.TrimRight(target, "/"),
+		header:       header,
+		ignoreFields: make(map[string]struct{}, len(ignoreFields)),
+		client: &http.Client{
+			Timeout: timeout,
+			// 回放时不跟随重定向，与录制的响应比对
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	for _, field := range ignoreFields {
+		l.ignoreFields[field] = struct{}{}
+	}
+	return l
+}
+
+// LoadRecording 读取录制文件，.har 文件按 HAR 解析，其余按 JSONL 解析
+func LoadRecording(file string) ([]*RecordedExchange, error) {
+	if strings.EqualFold(filepath.Ext(file), ".har") {
+		return readHAR(file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var exchanges []*RecordedExchange
+	reader := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			exchange := &RecordedExchange{}
+			if err := json.Unmarshal(data, exchange); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			exchanges = append(exchanges, exchange)
+		}
+		if errors.Is(err, io.EOF) {
+			return exchanges, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Replay 回放一条录制记录，录制时被截断的请求不回放，被截断的响应只比对状态码
+func (l *ReplayLogic) Replay(ctx context.Context, exchange *RecordedExchange) ReplayResult {
+	result := ReplayResult{
+		RequestID:      exchange.RequestID,
+		Method:         exchange.Request.Method,
+		Path:           exchange.Request.Path,
+		RecordedStatus: exchange.Response.Status,
+	}
+	if exchange.Request.Truncated {
+		result.Result = ReplayResultSkipped
+		result.Error = "request body was truncated when recorded"
+		return result
+	}
+
+	start := time.Now()
+	status, header, body, err := l.send(ctx, exchange)
+	result.Duration = time.Since(start).String()
+	result.Status = status
+	if err != nil {
+		result.Result = ReplayResultError
+		result.Error = err.Error()
+		return result
+	}
+	if status != exchange.Response.Status {
+		result.Result = ReplayResultStatusMismatch
+		return result
+	}
+	if exchange.Response.Truncated {
+		result.Result = ReplayResultMatch
+		return result
+	}
+
+	recorded, err := exchange.Response.Bytes()
+	if err != nil {
+		result.Result = ReplayResultError
+		result.Error = fmt.Sprintf("invalid recorded response body: %v", err)
+		return result
+	}
+	result.DiffPath = compareBodies(l.ignoreFields, exchange.Response.Header, recorded, header, body)
+	if result.DiffPath != "" {
+		result.Result = ReplayResultBodyMismatch
+	} else {
+		result.Result = ReplayResultMatch
+	}
+	return result
+}
+
+// send 发送录制的请求并读取响应，取值被隐藏的请求头不发送
+func (l *ReplayLogic) send(ctx context.Context, exchange *RecordedExchange) (int, http.Header, []byte, error) {
+	body, err := exchange.Request.Bytes()
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("invalid recorded request body: %w", err)
+	}
+
+	targetURL := l.target + exchange.Request.Path
+	if exchange.Request.Query != "" {
+		targetURL += "?" + exchange.Request.Query
+	}
+	req, err := http.NewRequestWithContext(ctx, exchange.Request.Method, targetURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to create replay request: %w", err)
+	}
+
+	for key, values := range exchange.Request.Header {
+		if len(values) == 1 && values[0] == proxy.RedactedValue {
+			continue
+		}
+		req.Header[key] = values
+	}
+	req.Header.Del("Content-Length")
+	for key, values := range l.header {
+		req.Header[key] = values
+	}
+	req.Header.Set(ReplayHeader, "true")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, resp.Header, nil, fmt.Errorf("failed to read replay response: %w", err)
+	}
+	return resp.StatusCode, resp.Header, respBody, nil
+}
+
+// Close 关闭回放使用的空闲连接
+func (l *ReplayLogic) Close() error {
+	l.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,352 @@
+package logic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/metrics"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// 影子请求结果常量
+const (
+	ShadowResultMatch          = "match"
+	ShadowResultStatusMismatch = "status_mismatch"
+	ShadowResultBodyMismatch   = "body_mismatch"
+	ShadowResultSent           = "sent"
+	ShadowResultError          = "error"
+	ShadowResultDropped        = "dropped"
+	ShadowResultSkipped        = "skipped"
+)
+
+// ShadowHeader 影子请求标识头
+const ShadowHeader = "X-Proxy-Mirror"
+
+// ShadowJob 待发送的影子请求
+type ShadowJob struct {
+	Source    string      // 来源规则名称
+	RequestID string      // 原始请求 ID
+	BaseURL   string      // 影子目标基础地址
+	Method    string      // 原始请求方法
+	Path      string      // 原始请求路径
+	Query     string      // 原始查询参数
+	Header    http.Header // 原始请求头
+	Body      []byte      // 原始请求体
+
+	Diff          bool        // 是否与主后端响应比对
+	PrimaryStatus int         // 主后端状态码
+	PrimaryHeader http.Header // 主后端响应头
+	PrimaryBody   []byte      // 主后端响应体
+}
+
+// logger 返回带请求 ID 字段的日志记录器
+func (j *ShadowJob) logger() logx.Logger {
+	return logx.WithContext(proxy.WithRequestID(context.Background(), j.RequestID))
+}
+
+// ShadowDiff 单次比对结果，按行写入比对文件
+type ShadowDiff struct {
+	Time          time.Time `json:"time"`
+	Source        string    `json:"source"`
+	RequestID     string    `json:"requestId,omitempty"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	Result        string    `json:"result"`
+	PrimaryStatus int       `json:"primaryStatus"`
+	ShadowStatus  int       `json:"shadowStatus,omitempty"`
+	DiffPath      string    `json:"diffPath,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	Duration      string    `json:"duration"`
+}
+
+// ShadowLogic 影子流量逻辑
+// 通过有界队列异步发送影子请求，队列满时直接丢弃，保证不阻塞主请求
+type ShadowLogic struct {
+	cfg          config.ShadowConfig
+	client       *http.Client
+	queue        chan *ShadowJob
+	ignoreFields map[string]struct{}
+	diffFile     *os.File
+	fileMu       sync.Mutex
+	queueMu      sync.RWMutex // 保护 closed，防止向已关闭的队列发送
+	closed       bool
+	wg           sync.WaitGroup
+	closeOnce    sync.Once
+}
+
+// NewShadowLogic 创建影子流量逻辑实例并启动工作协程
+func NewShadowLogic(cfg config.ShadowConfig, transports *proxy.TransportRegistry) *ShadowLogic {
+	cfg.SetDefaults()
+	l := &ShadowLogic{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transports,
+		},
+		queue:        make(chan *ShadowJob, cfg.QueueSize),
+		ignoreFields: make(map[string]struct{}, len(cfg.IgnoreFields)),
+	}
+	for _, field := range cfg.IgnoreFields {
+		l.ignoreFields[field] = struct{}{}
+	}
+
+	if cfg.DiffFile != "" {
+		if err := os.MkdirAll(filepath.Dir(cfg.DiffFile), 0o755); err != nil {
+			logx.Errorf("Failed to create shadow diff dir: %v", err)
+		} else if f, err := os.OpenFile(cfg.DiffFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err != nil {
+			logx.Errorf("Failed to open shadow diff file %s: %v", cfg.DiffFile, err)
+		} else {
+			l.diffFile = f
+		}
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		l.wg.Add(1)
+		go l.worker()
+	}
+	return l
+}
+
+// MaxBodyBytes 返回参与比对的最大字节数
+func (l *ShadowLogic) MaxBodyBytes() int64 {
+	return l.cfg.MaxBodyBytes
+}
+
+// Submit 提交影子请求，队列满或已关闭时丢弃并返回 false
+func (l *ShadowLogic) Submit(job *ShadowJob) bool {
+	l.queueMu.RLock()
+	defer l.queueMu.RUnlock()
+	if l.closed {
+		job.logger().Infof("Shadow logic closed, dropping request %s %s from %s", job.Method, job.Path, job.Source)
+		metrics.MirrorRequests.Inc(job.Source, ShadowResultDropped)
+		return false
+	}
+	select {
+	case l.queue <- job:
+		return true
+	default:
+		job.logger().Errorf("Shadow queue full, dropping request %s %s from %s", job.Method, job.Path, job.Source)
+		metrics.MirrorRequests.Inc(job.Source, ShadowResultDropped)
+		return false
+	}
+}
+
+// Close 停止接收影子请求并等待队列中的请求处理完毕
+func (l *ShadowLogic) Close() error {
+	l.closeOnce.Do(func() {
+		l.queueMu.Lock()
+		l.closed = true
+		close(l.queue)
+		l.queueMu.Unlock()
+		l.wg.Wait()
+		l.client.CloseIdleConnections()
+		if l.diffFile != nil {
+			_ = l.diffFile.Close()
+		}
+	})
+	return nil
+}
+
+func (l *ShadowLogic) worker() {
+	defer l.wg.Done()
+	for job := range l.queue {
+		l.process(job)
+	}
+}
+
+// process 发送影子请求，并在需要时与主后端响应比对
+func (l *ShadowLogic) process(job *ShadowJob) {
+	start := time.Now()
+	status, header, body, truncated, err := l.send(job)
+
+	if !job.Diff {
+		result := ShadowResultSent
+		if err != nil {
+			job.logger().Errorf("Mirror request %s %s to %s failed: %v", job.Method, job.Path, job.BaseURL, err)
+			result = ShadowResultError
+		}
+		metrics.MirrorRequests.Inc(job.Source, result)
+		return
+	}
+
+	diff := &ShadowDiff{
+		Time:          start,
+		Source:        job.Source,
+		RequestID:     job.RequestID,
+		Method:        job.Method,
+		Path:          job.Path,
+		PrimaryStatus: job.PrimaryStatus,
+		ShadowStatus:  status,
+		Duration:      time.Since(start).String(),
+	}
+	switch {
+	case err != nil:
+		diff.Result = ShadowResultError
+		diff.Error = err.Error()
+	case status != job.PrimaryStatus:
+		diff.Result = ShadowResultStatusMismatch
+	case truncated:
+		// 影子响应体不完整，比对结果不可信
+		job.logger().Infof("Skip shadow diff for %s %s: shadow response body exceeds %d bytes", job.Method, job.Path, l.cfg.MaxBodyBytes)
+		metrics.MirrorRequests.Inc(job.Source, ShadowResultSkipped)
+		return
+	default:
+		diff.DiffPath = compareBodies(l.ignoreFields, job.PrimaryHeader, job.PrimaryBody, header, body)
+		if diff.DiffPath != "" {
+			diff.Result = ShadowResultBodyMismatch
+		} else {
+			diff.Result = ShadowResultMatch
+		}
+	}
+
+	metrics.MirrorRequests.Inc(job.Source, diff.Result)
+	l.writeDiff(diff)
+}
+
+// send 发送影子请求并读取响应，需要比对时最多读取 max_body_bytes 字节，响应体超过上限时 truncated 为 true
+func (l *ShadowLogic) send(job *ShadowJob) (status int, header http.Header, body []byte, truncated bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.Timeout)
+	defer cancel()
+
+	targetURL := strings.TrimRight(job.BaseURL, "/") + "/" + strings.TrimLeft(job.Path, "/")
+	if job.Query != "" {
+		targetURL += "?" + job.Query
+	}
+
+	req, err := http.NewRequestWithContext(ctx, job.Method, targetURL, bytes.NewReader(job.Body))
+	if err != nil {
+		return 0, nil, nil, false, fmt.Errorf("failed to create shadow request: %w", err)
+	}
+	req.Header = job.Header.Clone()
+	req.Header.Set(ShadowHeader, "true")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, nil, nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if !job.Diff {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, resp.Header, nil, false, nil
+	}
+
+	// 多读 1 字节用于判断是否超限
+	body, err = io.ReadAll(io.LimitReader(resp.Body, l.cfg.MaxBodyBytes+1))
+	if err != nil {
+		return resp.StatusCode, resp.Header, nil, false, fmt.Errorf("failed to read shadow response: %w", err)
+	}
+	if int64(len(body)) > l.cfg.MaxBodyBytes {
+		return resp.StatusCode, resp.Header, nil, true, nil
+	}
+	return resp.StatusCode, resp.Header, body, false, nil
+}
+
+// compareBodies 比对响应体，一致时返回空字符串，否则返回第一处差异的位置
+// 双方均为 JSON 时按忽略字段规范化后比对，否则按字节比对
+func compareBodies(ignoreFields map[string]struct{}, primaryHeader http.Header, primaryBody []byte, shadowHeader http.Header, shadowBody []byte) string {
+	primaryBody = decodeBody(primaryHeader, primaryBody)
+	shadowBody = decodeBody(shadowHeader, shadowBody)
+
+	var primary, shadow interface{}
+	if json.Unmarshal(primaryBody, &primary) == nil && json.Unmarshal(shadowBody, &shadow) == nil {
+		return firstDiff(ignoreFields, primary, shadow, "$")
+	}
+	if bytes.Equal(primaryBody, shadowBody) {
+		return ""
+	}
+	return "$"
+}
+
+// firstDiff 递归比对规范化后的 JSON，返回第一处差异的路径
+func firstDiff(ignoreFields map[string]struct{}, a, b interface{}, path string) string {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			return path
+		}
+		keys := make(map[string]struct{}, len(av)+len(bv))
+		for k := range av {
+			keys[k] = struct{}{}
+		}
+		for k := range bv {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			if _, ignored := ignoreFields[k]; !ignored {
+				sorted = append(sorted, k)
+			}
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			if d := firstDiff(ignoreFields, av[k], bv[k], path+"."+k); d != "" {
+				return d
+			}
+		}
+		return ""
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return path
+		}
+		for i := range av {
+			if d := firstDiff(ignoreFields, av[i], bv[i], fmt.Sprintf("%s[%d]", path, i)); d != "" {
+				return d
+			}
+		}
+		return ""
+	default:
+		if a != b {
+			return path
+		}
+		return ""
+	}
+}
+
+// writeDiff 将比对结果追加写入比对文件
+func (l *ShadowLogic) writeDiff(diff *ShadowDiff) {
+	if l.diffFile == nil {
+		return
+	}
+	line, err := json.Marshal(diff)
+	if err != nil {
+		return
+	}
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if _, err := l.diffFile.Write(append(line, '\n')); err != nil {
+		logx.WithContext(proxy.WithRequestID(context.Background(), diff.RequestID)).Errorf("Failed to write shadow diff: %v", err)
+	}
+}
+
+// decodeBody 解压 gzip 编码的响应体，失败时返回原始内容
+func decodeBody(header http.Header, body []byte) []byte {
+	if header == nil || !strings.EqualFold(header.Get("Content-Encoding"), "gzip") {
+		return body
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+	defer reader.Close()
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return body
+	}
+	return decoded
+}
@@ -0,0 +1,83 @@
+package logic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+func TestShadowSubmitAfterClose(t *testing.T) {
+	transports := proxy.NewTransportRegistry(config.TransportConfig{})
+	t.Cleanup(func() { transports.Close() })
+	l := NewShadowLogic(config.ShadowConfig{Workers: 1, QueueSize: 1}, transports)
+
+	// 关闭期间并发提交不能向已关闭的队列发送
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Submit(&ShadowJob{Source: "test", BaseURL: "http://127.0.0.1:1", Method: "GET", Path: "/"})
+		}()
+	}
+	assert.NoError(t, l.Close())
+	wg.Wait()
+
+	assert.False(t, l.Submit(&ShadowJob{Source: "test", Method: "GET", Path: "/"}))
+}
+
+func TestShadowSkipsDiffOnTruncatedResponse(t *testing.T) {
+	body := `{"items":["` + strings.Repeat("a", 64) + `"]}`
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(shadow.Close)
+
+	tests := []struct {
+		name         string
+		maxBodyBytes int64
+		want         string
+	}{
+		{"truncated shadow response", 32, ""},
+		{"complete shadow response", 1024, `"result":"match"`},
+	}
+
+	for _, tt := range tests {
+		transports := proxy.NewTransportRegistry(config.TransportConfig{})
+		t.Cleanup(func() { transports.Close() })
+		diffFile := filepath.Join(t.TempDir(), "diff.jsonl")
+		l := NewShadowLogic(config.ShadowConfig{Workers: 1, QueueSize: 1, MaxBodyBytes: tt.maxBodyBytes, DiffFile: diffFile}, transports)
+
+		require.True(t, l.Submit(&ShadowJob{
+			Source:        "test",
+			BaseURL:       shadow.URL,
+			Method:        http.MethodGet,
+			Path:          "/search",
+			Header:        http.Header{},
+			Diff:          true,
+			PrimaryStatus: http.StatusOK,
+			PrimaryHeader: http.Header{"Content-Type": {"application/json"}},
+			PrimaryBody:   []byte(body),
+		}), tt.name)
+		require.NoError(t, l.Close(), tt.name)
+
+		diffs, err := os.ReadFile(diffFile)
+		require.NoError(t, err, tt.name)
+		if tt.want == "" {
+			// 影子响应体超过上限时不比对，不能记为 body_mismatch
+			assert.Empty(t, string(diffs), tt.name)
+		} else {
+			assert.Contains(t, string(diffs), tt.want, tt.name)
+		}
+	}
+}
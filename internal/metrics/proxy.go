@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"github.com/zeromicro/go-zero/core/metric"
+)
+
+const (
+	namespace      = "codebase_querier"
+	proxySubsystem = "proxy"
+)
+
+var (
+	// SplitRequests 分流请求数，按分流名称、后端和响应状态码统计
+	SplitRequests = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "split_requests_total",
+		Help:      "proxy traffic split requests count.",
+		Labels:    []string{"split", "backend", "code"},
+	})
+
+	// SplitDuration 分流请求耗时（毫秒），按分流名称和后端统计
+	SplitDuration = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "split_duration_ms",
+		Help:      "proxy traffic split requests duration(ms).",
+		Labels:    []string{"split", "backend"},
+		Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000},
+	})
+
+	// MirrorRequests 镜像请求数，按来源和结果统计
+	MirrorRequests = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "mirror_requests_total",
+		Help:      "proxy mirrored requests count.",
+		Labels:    []string{"source", "result"},
+	})
+
+	// InflightRequests 进行中的请求数
+	InflightRequests = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "inflight_requests",
+		Help:      "proxy in-flight requests count.",
+	})
+
+	// ShutdownPhase 优雅停机阶段：0 运行中，1 未就绪，2 排空中，3 已关闭
+	ShutdownPhase = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "shutdown_phase",
+		Help:      "proxy graceful shutdown phase, 0: serving, 1: not ready, 2: draining, 3: closed.",
+	})
+
+	// DrainedRequests 优雅停机期间结束的进行中请求数，按结果（completed、aborted）统计
+	DrainedRequests = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "drained_requests_total",
+		Help:      "proxy requests finished during graceful shutdown.",
+		Labels:    []string{"result"},
+	})
+
+	// UpstreamConnections 上游连接池的连接数，按连接池（上游主机或 default）和状态（idle、active）统计
+	UpstreamConnections = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "upstream_connections",
+		Help:      "proxy upstream connection pool connections.",
+		Labels:    []string{"pool", "state"},
+	})
+
+	// UpstreamDials 上游连接池新建连接数，按连接池和结果（ok、error）统计
+	UpstreamDials = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "upstream_dials_total",
+		Help:      "proxy upstream connection pool dials count.",
+		Labels:    []string{"pool", "result"},
+	})
+
+	// RecordedRequests 流量录制的请求数，按结果（recorded、dropped、skipped）统计
+	RecordedRequests = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "recorded_requests_total",
+		Help:      "proxy recorded requests count.",
+		Labels:    []string{"result"},
+	})
+
+	// InjectedFaults 故障注入的请求数，按规则和故障类型（delay、abort、reset、throttle、partial）统计
+	InjectedFaults = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "injected_faults_total",
+		Help:      "proxy injected faults count.",
+		Labels:    []string{"rule", "fault"},
+	})
+
+	// ThrottledDuration 带宽限制导致的等待时间（毫秒），按方向（upload、download）统计
+	ThrottledDuration = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: proxySubsystem,
+		Name:      "throttled_milliseconds_total",
+		Help:      "proxy time spent waiting for bandwidth tokens in milliseconds.",
+		Labels:    []string{"direction"},
+	})
+)
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// RequestIDMiddleware 请求 ID 中间件
+// 沿用请求携带的 X-Request-Id，没有或不合法时生成新的，写回请求头与响应头并附加到上下文
+type RequestIDMiddleware struct{}
+
+// NewRequestIDMiddleware 创建请求 ID 中间件
+func NewRequestIDMiddleware() *RequestIDMiddleware {
+	return &RequestIDMiddleware{}
+}
+
+// Handle 处理请求
+func (m *RequestIDMiddleware) Handle(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := proxy.ResolveRequestID(r)
+		r.Header.Set(proxy.RequestIDHeader, id)
+		w.Header().Set(proxy.RequestIDHeader, id)
+		next(w, r.WithContext(proxy.WithRequestID(r.Context(), id)))
+	}
+}
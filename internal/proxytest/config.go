@@ -0,0 +1,92 @@
+package proxytest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
+)
+
+// 测试配置的默认超时
+const (
+	DefaultRouteTimeout       = 5 * time.Second
+	DefaultPortManagerTimeout = 2 * time.Second
+)
+
+// tunnelForwardURL 模拟服务均监听本机，隧道端口拼接在其后
+const tunnelForwardURL = "http://127.0.0.1"
+
+// NewProxyConfig 返回全路径模式、通过 tunnels 动态转发的代理配置，routes 为转发路由
+// 调用方可修改其余字段后执行 Validate
+func NewProxyConfig(tunnels *TunnelManager, routes ...config.RouteConfig) *config.ProxyConfig {
+	return &config.ProxyConfig{
+		Mode:        config.ProxyModeFullPath,
+		Routes:      routes,
+		DynamicPort: true,
+		PortManager: config.PortManagerConfig{
+			URL:        tunnels.URL(),
+			ForwardURL: tunnelForwardURL,
+			Timeout:    DefaultPortManagerTimeout,
+			CacheExp:   time.Minute,
+		},
+		Headers: config.HeadersConfig{PassThrough: true},
+	}
+}
+
+// Route 返回转发到模拟上游的路由配置
+func Route(pathPrefix string, upstream *Upstream) config.RouteConfig {
+	return config.RouteConfig{
+		PathPrefix: pathPrefix,
+		Target:     config.TargetConfig{URL: upstream.URL(), Timeout: DefaultRouteTimeout},
+	}
+}
+
+// Validate 校验配置并填充默认值，失败时终止测试
+func Validate(t testing.TB, cfg *config.ProxyConfig) *config.ProxyConfig {
+	t.Helper()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid proxy config: %v", err)
+	}
+	return cfg
+}
+
+// NewTransports 按配置创建转发连接池，测试结束时关闭
+func NewTransports(t testing.TB, cfg *config.ProxyConfig) *proxy.TransportRegistry {
+	t.Helper()
+	transports := proxy.NewTransportRegistry(cfg.Transport)
+	t.Cleanup(func() { transports.Close() })
+	return transports
+}
+
+// NewPortManager 按配置创建端口管理器，测试结束时关闭
+func NewPortManager(t testing.TB, cfg *config.ProxyConfig) *proxy.PortManager {
+	t.Helper()
+	portManager := proxy.NewPortManagerWithConfig(cfg.PortManager)
+	t.Cleanup(func() { portManager.Close() })
+	return portManager
+}
+
+// ErrorCode 返回代理错误响应中的错误码，兼容 envelope 与 problem 两种格式，不是代理错误时返回空字符串
+func ErrorCode(rec *httptest.ResponseRecorder) errs.Code {
+	var body struct {
+		Code json.RawMessage `json:"code"`
+		Data struct {
+			Code errs.Code `json:"code"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return ""
+	}
+	if body.Data.Code != "" {
+		return body.Data.Code
+	}
+	var code errs.Code
+	if err := json.Unmarshal(body.Code, &code); err != nil {
+		return ""
+	}
+	return code
+}
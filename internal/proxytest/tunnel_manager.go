@@ -0,0 +1,110 @@
+package proxytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TunnelManagerPath 模拟端口管理器响应端口查询的路径
+const TunnelManagerPath = "/tunnel-manager/api/v1/ports"
+
+// TunnelManager 模拟的端口管理器
+// 按 clientId 返回登记的隧道端口（mappingPort），未登记的 clientId 返回 404
+type TunnelManager struct {
+	server  *httptest.Server
+	mu      sync.Mutex
+	ports   map[string]int
+	lookups []string
+	faults  faultQueue
+}
+
+// NewTunnelManager 启动模拟端口管理器，测试结束时关闭
+func NewTunnelManager(t testing.TB) *TunnelManager {
+	t.Helper()
+	m := &TunnelManager{ports: make(map[string]int)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(TunnelManagerPath, m.servePorts)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// URL 返回端口管理器地址
+func (m *TunnelManager) URL() string {
+	return m.server.URL
+}
+
+// SetPort 登记 clientId 的隧道端口
+func (m *TunnelManager) SetPort(clientID string, port int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ports[clientID] = port
+}
+
+// AddTunnel 将 clientId 的隧道指向模拟上游
+func (m *TunnelManager) AddTunnel(clientID string, upstream *Upstream) {
+	m.SetPort(clientID, upstream.Port())
+}
+
+// RemoveTunnel 移除 clientId 的隧道，之后的查询返回 404
+func (m *TunnelManager) RemoveTunnel(clientID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.ports, clientID)
+}
+
+// InjectFault 对之后的所有端口查询注入故障，直到 ClearFaults
+func (m *TunnelManager) InjectFault(f Fault) {
+	m.faults.set(f)
+}
+
+// InjectFaultOnce 对之后的下一次端口查询注入故障
+func (m *TunnelManager) InjectFaultOnce(f Fault) {
+	m.faults.push(f)
+}
+
+// ClearFaults 清除注入的故障
+func (m *TunnelManager) ClearFaults() {
+	m.faults.clear()
+}
+
+// Lookups 返回收到的端口查询的 clientId，按查询顺序
+func (m *TunnelManager) Lookups() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.lookups...)
+}
+
+func (m *TunnelManager) servePorts(w http.ResponseWriter, r *http.Request) {
+	clientID := r.URL.Query().Get("clientId")
+	m.mu.Lock()
+	m.lookups = append(m.lookups, clientID)
+	port, ok := m.ports[clientID]
+	m.mu.Unlock()
+
+	fault, faulted := m.faults.next()
+	if faulted && applyFault(w, r, fault) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if clientID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"clientId is required"}`))
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"tunnel not found"}`))
+		return
+	}
+	body, _ := json.Marshal(map[string]int{"mappingPort": port})
+	if faulted && fault.Truncate {
+		writeTruncated(w, body)
+		return
+	}
+	w.Write(body)
+}
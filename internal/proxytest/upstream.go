@@ -0,0 +1,270 @@
+// Package proxytest 提供代理处理器集成测试使用的进程内模拟服务：
+// 模拟端口管理器（tunnel-manager）、可注入故障的模拟索引服务上游，以及构建 ProxyConfig 的辅助函数
+package proxytest
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// UpstreamHeader 模拟上游在响应头中返回自己的名称
+const UpstreamHeader = "X-Upstream"
+
+// Fault 注入的故障，各项可组合，Delay 先于其他故障生效
+type Fault struct {
+	Delay    time.Duration // 响应前等待，请求被取消时提前返回
+	Reset    bool          // 读取请求后直接重置连接
+	Status   int           // 以该状态码响应，如 500、502、503
+	Truncate bool          // 声明完整的 Content-Length，只写出一半响应体后断开连接
+}
+
+// Request 上游收到的请求
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Echo 模拟上游的默认响应体，回显收到的请求
+type Echo struct {
+	Upstream string `json:"upstream"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Query    string `json:"query,omitempty"`
+	Body     string `json:"body,omitempty"`
+}
+
+// Upstream 模拟的索引服务上游，默认以 Echo 响应所有请求
+type Upstream struct {
+	name     string
+	server   *httptest.Server
+	mu       sync.Mutex
+	requests []Request
+	handlers map[string]http.HandlerFunc
+	faults   faultQueue
+}
+
+// NewUpstream 启动模拟上游，测试结束时关闭
+func NewUpstream(t testing.TB, name string) *Upstream {
+	t.Helper()
+	u := &Upstream{name: name, handlers: make(map[string]http.HandlerFunc)}
+	u.server = httptest.NewServer(http.HandlerFunc(u.serveHTTP))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// Name 返回上游名称
+func (u *Upstream) Name() string {
+	return u.name
+}
+
+// URL 返回上游基础地址，如 http://127.0.0.1:12345
+func (u *Upstream) URL() string {
+	return u.server.URL
+}
+
+// Port 返回上游监听的端口，用于登记到模拟端口管理器
+func (u *Upstream) Port() int {
+	return serverPort(u.server)
+}
+
+// Handle 为路径 path 注册自定义处理函数，替代默认的 Echo 响应，注入的故障仍然生效
+func (u *Upstream) Handle(path string, handler http.HandlerFunc) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.handlers[path] = handler
+}
+
+// InjectFault 对之后的所有请求注入故障，直到 ClearFaults
+func (u *Upstream) InjectFault(f Fault) {
+	u.faults.set(f)
+}
+
+// InjectFaultOnce 对之后的下一个请求注入故障，多次调用依次生效，优先于 InjectFault
+func (u *Upstream) InjectFaultOnce(f Fault) {
+	u.faults.push(f)
+}
+
+// ClearFaults 清除注入的故障
+func (u *Upstream) ClearFaults() {
+	u.faults.clear()
+}
+
+// Requests 返回上游收到的请求
+func (u *Upstream) Requests() []Request {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]Request(nil), u.requests...)
+}
+
+// LastRequest 返回上游收到的最后一个请求，未收到请求时返回 nil
+func (u *Upstream) LastRequest() *Request {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.requests) == 0 {
+		return nil
+	}
+	r := u.requests[len(u.requests)-1]
+	return &r
+}
+
+// Close 关闭上游，之后的连接会被拒绝
+func (u *Upstream) Close() {
+	u.server.CloseClientConnections()
+	u.server.Close()
+}
+
+func (u *Upstream) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	u.mu.Lock()
+	u.requests = append(u.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	handler := u.handlers[r.URL.Path]
+	u.mu.Unlock()
+
+	w.Header().Set(UpstreamHeader, u.name)
+	fault, ok := u.faults.next()
+	if ok && applyFault(w, r, fault) {
+		return
+	}
+
+	if handler != nil {
+		handler(w, r)
+		return
+	}
+	echo, _ := json.Marshal(Echo{
+		Upstream: u.name,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Query:    r.URL.RawQuery,
+		Body:     string(body),
+	})
+	if ok && fault.Truncate {
+		writeTruncated(w, echo)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(echo)
+}
+
+// DecodeEcho 解析模拟上游的默认响应体，失败时终止测试
+func DecodeEcho(t testing.TB, body []byte) Echo {
+	t.Helper()
+	var echo Echo
+	if err := json.Unmarshal(body, &echo); err != nil {
+		t.Fatalf("invalid upstream echo %q: %v", body, err)
+	}
+	return echo
+}
+
+// faultQueue 注入的故障：一次性故障按顺序消费，之后使用持续故障
+type faultQueue struct {
+	mu     sync.Mutex
+	once   []Fault
+	always *Fault
+}
+
+func (q *faultQueue) set(f Fault) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.always = &f
+}
+
+func (q *faultQueue) push(f Fault) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.once = append(q.once, f)
+}
+
+func (q *faultQueue) clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.once = nil
+	q.always = nil
+}
+
+func (q *faultQueue) next() (Fault, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.once) > 0 {
+		f := q.once[0]
+		q.once = q.once[1:]
+		return f, true
+	}
+	if q.always != nil {
+		return *q.always, true
+	}
+	return Fault{}, false
+}
+
+// applyFault 执行延迟、重置与错误状态码故障，已写出响应时返回 true
+// 截断故障由调用方在生成响应体后执行
+func applyFault(w http.ResponseWriter, r *http.Request, f Fault) bool {
+	if f.Delay > 0 {
+		timer := time.NewTimer(f.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if f.Reset {
+		resetConnection(w)
+		return true
+	}
+	if f.Status != 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(f.Status)
+		w.Write([]byte(`{"error":"injected fault"}`))
+		return true
+	}
+	return false
+}
+
+// resetConnection 以 RST 关闭连接
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// writeTruncated 声明完整的 Content-Length，写出一半响应体后中断连接
+func writeTruncated(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body[:len(body)/2])
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	panic(http.ErrAbortHandler)
+}
+
+func serverPort(server *httptest.Server) int {
+	return server.Listener.Addr().(*net.TCPAddr).Port
+}
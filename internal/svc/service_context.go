@@ -2,25 +2,36 @@ package svc
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/logic"
+	"github.com/zgsm-ai/codebase-indexer/internal/utils/proxy"
 )
 
 type ServiceContext struct {
 	Config            config.Config
 	serverContext     context.Context
 	ProxyHandler      *ProxyHandler
-	MultiProxyHandler interface{} // 使用interface{}避免循环导入，实际使用时需要类型断言
+	ErrorWriter       *proxy.ErrorWriter       // 按配置格式写出代理错误
+	Drainer           *proxy.Drainer           // 优雅停机协调器，统计进行中请求并在停机时关闭转发连接
+	Transports        *proxy.TransportRegistry // 按上游主机共享的转发连接池
+	PortManager       *proxy.PortManager       // 端口管理器，动态转发、聚合检索与就绪检查共用，未配置代理时为 nil
+	Readiness         *logic.ReadinessLogic    // 就绪检查，就绪探针与代理健康检查共用
+	MultiProxyHandler interface{}              // 使用interface{}避免循环导入，实际使用时需要类型断言
 }
 
 // ProxyHandler 代理处理器
 type ProxyHandler struct {
 	healthCheckHandler http.HandlerFunc
 	proxyHandler       http.HandlerFunc
+	errWriter          *proxy.ErrorWriter
 	ProxyLogic         interface{} // 使用interface{}避免循环导入
 }
 
@@ -28,7 +39,7 @@ func (p *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if p.proxyHandler != nil {
 		p.proxyHandler(w, r)
 	} else {
-		http.Error(w, "Proxy not configured", http.StatusServiceUnavailable)
+		p.errWriter.Write(w, r, errs.NewProxyError(errs.CodeNotConfigured, "proxy not configured"))
 	}
 }
 
@@ -36,7 +47,7 @@ func (p *ProxyHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	if p.healthCheckHandler != nil {
 		p.healthCheckHandler(w, r)
 	} else {
-		http.Error(w, "Proxy not configured", http.StatusServiceUnavailable)
+		p.errWriter.Write(w, r, errs.NewProxyError(errs.CodeNotConfigured, "proxy not configured"))
 	}
 }
 
@@ -52,18 +63,32 @@ func (s *ServiceContext) Close() {
 
 func NewServiceContext(ctx context.Context, c config.Config) (*ServiceContext, error) {
 	var err error
+	var errorsConfig config.ErrorsConfig
+	var transportConfig config.TransportConfig
+	if c.ProxyConfig != nil {
+		errorsConfig = c.ProxyConfig.Errors
+		transportConfig = c.ProxyConfig.Transport
+	}
 	svcCtx := &ServiceContext{
 		Config:        c,
 		serverContext: ctx,
+		ErrorWriter:   proxy.NewErrorWriter(errorsConfig),
+		Drainer:       proxy.NewDrainer(c.GracefulShutdown),
+		Transports:    proxy.NewTransportRegistry(transportConfig),
+	}
+	if c.ProxyConfig != nil {
+		svcCtx.PortManager = proxy.NewPortManagerWithConfig(c.ProxyConfig.PortManager)
 	}
+	svcCtx.Readiness = logic.NewReadinessLogic(c.ProxyConfig, svcCtx.PortManager, svcCtx.Drainer, svcCtx.Transports)
 
 	// 初始化代理处理器
 	if c.ProxyConfig != nil && len(c.ProxyConfig.Routes) > 0 {
 		// 使用第一个路由作为默认配置
 		firstRoute := c.ProxyConfig.Routes[0]
 		svcCtx.ProxyHandler = &ProxyHandler{
-			healthCheckHandler: createHealthCheckHandler(firstRoute.Target.URL),
-			proxyHandler:       createProxyHandler(c.ProxyConfig, firstRoute),
+			healthCheckHandler: createHealthCheckHandler(svcCtx.Readiness, firstRoute.Target.URL),
+			proxyHandler:       createProxyHandler(c.ProxyConfig, firstRoute, svcCtx.Transports, svcCtx.ErrorWriter),
+			errWriter:          svcCtx.ErrorWriter,
 		}
 		logx.Infof("Initialized proxy handler with route: %s -> %s", firstRoute.PathPrefix, firstRoute.Target.URL)
 	}
@@ -71,50 +96,77 @@ func NewServiceContext(ctx context.Context, c config.Config) (*ServiceContext, e
 	return svcCtx, err
 }
 
-func createHealthCheckHandler(targetURL string) http.HandlerFunc {
+// createHealthCheckHandler 探测目标服务并返回健康状态，目标不可达时返回 503
+func createHealthCheckHandler(readiness *logic.ReadinessLogic, targetURL string) http.HandlerFunc {
+	type proxyHealth struct {
+		TargetURL      string `json:"target_url"`
+		Reachable      bool   `json:"reachable"`
+		ResponseTimeMs int64  `json:"response_time_ms"`
+		Error          string `json:"error,omitempty"`
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		check := readiness.Probe(r.Context(), targetURL)
+		health := proxyHealth{
+			TargetURL:      targetURL,
+			Reachable:      check.Status == logic.ReadinessOK,
+			ResponseTimeMs: time.Since(start).Milliseconds(),
+			Error:          check.Error,
+		}
+
+		status, code := "ok", http.StatusOK
+		if !health.Reachable {
+			status, code = "error", http.StatusServiceUnavailable
+		}
 		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"ok","proxy":{"target_url":"` + targetURL + `","reachable":true,"response_time_ms":0}}`))
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "proxy": health})
 	}
 }
 
-func createProxyHandler(cfg *config.ProxyConfig, route config.RouteConfig) http.HandlerFunc {
+func createProxyHandler(cfg *config.ProxyConfig, route config.RouteConfig, transports *proxy.TransportRegistry, errWriter *proxy.ErrorWriter) http.HandlerFunc {
+	rules := make([]proxy.RewriteRule, len(cfg.Rewrite.Rules))
+	for i, rule := range cfg.Rewrite.Rules {
+		rules[i] = proxy.RewriteRule{Type: rule.Type, From: rule.From, To: rule.To, SetQuery: rule.SetQuery}
+	}
+	rewriter, err := proxy.NewRewriter(cfg.Rewrite.StripPrefixes, rules)
+	logx.Must(err)
+	timeouts := proxy.NewTimeoutBudget(cfg.Timeouts, route.Target.Timeout, route.Timeouts)
+	// 简单的代理实现，所有请求共用按上游主机共享连接池的客户端
+	client := transports.Client()
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 根据代理模式选择不同的处理逻辑
 		if cfg.Mode == "full_path" {
-			handleFullPathProxy(w, r, &route)
+			handleFullPathProxy(w, r, &route, timeouts, client, errWriter)
 		} else {
-			handleRewriteProxy(w, r, &route)
+			handleRewriteProxy(w, r, &route, rewriter, cfg.Rewrite.Enabled, timeouts, client, errWriter)
 		}
 	}
 }
 
 // handleFullPathProxy 处理全路径模式的代理请求
-func handleFullPathProxy(w http.ResponseWriter, r *http.Request, route *config.RouteConfig) {
-	logx.Infof("[PROXY_DEBUG] === Full Path Proxy Processing Start ===")
-	logx.Infof("[PROXY_DEBUG] Original request: %s %s", r.Method, r.URL.Path)
-	logx.Infof("[PROXY_DEBUG] Full URL: %s", r.URL.String())
+func handleFullPathProxy(w http.ResponseWriter, r *http.Request, route *config.RouteConfig, timeouts *proxy.TimeoutBudget, client *http.Client, errWriter *proxy.ErrorWriter) {
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] === Full Path Proxy Processing Start ===")
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Original request: %s %s", r.Method, r.URL.Path)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Full URL: %s", r.URL.String())
 
 	// 记录配置信息
-	logx.Infof("[PROXY_DEBUG] Config Target URL: %s", route.Target.URL)
-	logx.Infof("[PROXY_DEBUG] Config Timeout: %v", route.Target.Timeout)
-
-	// 简单的代理实现，实际项目中应该使用更完整的代理逻辑
-	client := &http.Client{
-		Timeout: route.Target.Timeout,
-	}
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Config Target URL: %s", route.Target.URL)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Config Timeout: %v", route.Target.Timeout)
 
 	// 构建目标URL - 全路径模式：直接拼接目标URL和原始路径
-	logx.Infof("[PROXY_DEBUG] === Path Processing Start ===")
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] === Path Processing Start ===")
 	remainingPath := r.URL.Path
-	logx.Infof("[PROXY_DEBUG] Full path mode - remainingPath: %s", remainingPath)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Full path mode - remainingPath: %s", remainingPath)
 
-	logx.Infof("[PROXY_DEBUG] Base URL from config: '%s'", route.Target.URL)
-	logx.Infof("[PROXY_DEBUG] Full path to append: '%s'", remainingPath)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Base URL from config: '%s'", route.Target.URL)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Full path to append: '%s'", remainingPath)
 
 	// 检查URL是否以/结尾，路径是否以/开头
 	needsSlash := !strings.HasSuffix(route.Target.URL, "/") && !strings.HasPrefix(remainingPath, "/")
-	logx.Infof("[PROXY_DEBUG] URL needs slash separator: %v", needsSlash)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] URL needs slash separator: %v", needsSlash)
 
 	var targetURL string
 	if needsSlash {
@@ -122,22 +174,24 @@ func handleFullPathProxy(w http.ResponseWriter, r *http.Request, route *config.R
 	} else {
 		targetURL = route.Target.URL + remainingPath
 	}
-	logx.Infof("[PROXY_DEBUG] Target URL before query: %s", targetURL)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Target URL before query: %s", targetURL)
 
 	if r.URL.RawQuery != "" {
 		targetURL += "?" + r.URL.RawQuery
-		logx.Infof("[PROXY_DEBUG] Target URL with query: %s", targetURL)
+		logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Target URL with query: %s", targetURL)
 	}
 
 	// 添加更多诊断日志
-	logx.Infof("[PROXY_DEBUG] Final Target URL: %s", targetURL)
-	logx.Infof("[PROXY_DEBUG] Target URL length: %d", len(targetURL))
-	logx.Infof("[PROXY_DEBUG] === Full Path Proxy Processing End ===")
-
-	// 创建新的请求
-	req, err := http.NewRequest(r.Method, targetURL, r.Body)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Final Target URL: %s", targetURL)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Target URL length: %d", len(targetURL))
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] === Full Path Proxy Processing End ===")
+
+	// 创建新的请求，总超时由超时预算决定
+	ctx, cancel := timeouts.Start(r)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeInternal, "failed to create target request: %v", err))
 		return
 	}
 
@@ -151,7 +205,7 @@ func handleFullPathProxy(w http.ResponseWriter, r *http.Request, route *config.R
 	// 发送请求
 	resp, err := client.Do(req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		errWriter.Write(w, r, errs.FromUpstream(err))
 		return
 	}
 	defer resp.Body.Close()
@@ -167,41 +221,36 @@ func handleFullPathProxy(w http.ResponseWriter, r *http.Request, route *config.R
 }
 
 // handleRewriteProxy 处理重写模式的代理请求
-func handleRewriteProxy(w http.ResponseWriter, r *http.Request, route *config.RouteConfig) {
+func handleRewriteProxy(w http.ResponseWriter, r *http.Request, route *config.RouteConfig, rewriter *proxy.Rewriter, rewriteEnabled bool, timeouts *proxy.TimeoutBudget, client *http.Client, errWriter *proxy.ErrorWriter) {
 	// 添加诊断日志
-	logx.Infof("[PROXY_DEBUG] === Rewrite Proxy Processing Start ===")
-	logx.Infof("[PROXY_DEBUG] Original request: %s %s", r.Method, r.URL.Path)
-	logx.Infof("[PROXY_DEBUG] Full URL: %s", r.URL.String())
-	logx.Infof("[PROXY_DEBUG] Path prefix to remove: /api/v1/proxy")
-	logx.Infof("[PROXY_DEBUG] Path length: %d, prefix length: %d", len(r.URL.Path), len("/api/v1/proxy"))
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] === Rewrite Proxy Processing Start ===")
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Original request: %s %s", r.Method, r.URL.Path)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Full URL: %s", r.URL.String())
 
 	// 记录配置信息
-	logx.Infof("[PROXY_DEBUG] Config Target URL: %s", route.Target.URL)
-	logx.Infof("[PROXY_DEBUG] Config Timeout: %v", route.Target.Timeout)
-
-	// 简单的代理实现，实际项目中应该使用更完整的代理逻辑
-	client := &http.Client{
-		Timeout: route.Target.Timeout,
-	}
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Config Target URL: %s", route.Target.URL)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Config Timeout: %v", route.Target.Timeout)
 
 	// 构建目标URL - 添加详细日志
-	logx.Infof("[PROXY_DEBUG] === Path Processing Start ===")
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] === Path Processing Start ===")
 	remainingPath := r.URL.Path
-	logx.Infof("[PROXY_DEBUG] Initial remainingPath: %s", remainingPath)
+	rawQuery := r.URL.RawQuery
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Initial remainingPath: %s", remainingPath)
 
-	if len(r.URL.Path) > len("/api/v1/proxy") {
-		remainingPath = r.URL.Path[len("/api/v1/proxy"):]
-		logx.Infof("[PROXY_DEBUG] Path after prefix removal: %s", remainingPath)
-	} else {
-		logx.Infof("[PROXY_DEBUG] Path length <= prefix length, keeping original: %s", remainingPath)
+	remainingPath = rewriter.StripPrefix(remainingPath)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Path after prefix removal: %s", remainingPath)
+
+	if rewriteEnabled {
+		remainingPath, rawQuery = rewriter.Rewrite(remainingPath, rawQuery)
+		logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Path after rewrite: %s", remainingPath)
 	}
 
-	logx.Infof("[PROXY_DEBUG] Base URL from config: '%s'", route.Target.URL)
-	logx.Infof("[PROXY_DEBUG] Remaining path to append: '%s'", remainingPath)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Base URL from config: '%s'", route.Target.URL)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Remaining path to append: '%s'", remainingPath)
 
 	// 检查URL是否以/结尾，路径是否以/开头
 	needsSlash := !strings.HasSuffix(route.Target.URL, "/") && !strings.HasPrefix(remainingPath, "/")
-	logx.Infof("[PROXY_DEBUG] URL needs slash separator: %v", needsSlash)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] URL needs slash separator: %v", needsSlash)
 
 	var targetURL string
 	if needsSlash {
@@ -209,22 +258,24 @@ func handleRewriteProxy(w http.ResponseWriter, r *http.Request, route *config.Ro
 	} else {
 		targetURL = route.Target.URL + remainingPath
 	}
-	logx.Infof("[PROXY_DEBUG] Target URL before query: %s", targetURL)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Target URL before query: %s", targetURL)
 
-	if r.URL.RawQuery != "" {
-		targetURL += "?" + r.URL.RawQuery
-		logx.Infof("[PROXY_DEBUG] Target URL with query: %s", targetURL)
+	if rawQuery != "" {
+		targetURL += "?" + rawQuery
+		logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Target URL with query: %s", targetURL)
 	}
 
 	// 添加更多诊断日志
-	logx.Infof("[PROXY_DEBUG] Final Target URL: %s", targetURL)
-	logx.Infof("[PROXY_DEBUG] Target URL length: %d", len(targetURL))
-	logx.Infof("[PROXY_DEBUG] === Rewrite Proxy Processing End ===")
-
-	// 创建新的请求
-	req, err := http.NewRequest(r.Method, targetURL, r.Body)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Final Target URL: %s", targetURL)
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] Target URL length: %d", len(targetURL))
+	logx.WithContext(r.Context()).Infof("[PROXY_DEBUG] === Rewrite Proxy Processing End ===")
+
+	// 创建新的请求，总超时由超时预算决定
+	ctx, cancel := timeouts.Start(r)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		errWriter.Write(w, r, errs.NewProxyErrorf(errs.CodeInternal, "failed to create target request: %v", err))
 		return
 	}
 
@@ -238,7 +289,7 @@ func handleRewriteProxy(w http.ResponseWriter, r *http.Request, route *config.Ro
 	// 发送请求
 	resp, err := client.Do(req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		errWriter.Write(w, r, errs.FromUpstream(err))
 		return
 	}
 	defer resp.Body.Close()
@@ -0,0 +1,270 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/metrics"
+)
+
+// 限速方向
+const (
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+)
+
+// bandwidthBucketIdle 共享令牌桶闲置超过该时间后被清理
+const bandwidthBucketIdle = time.Minute
+
+// bandwidthKey 上下文中带宽限制状态的键
+type bandwidthKey struct{}
+
+// bandwidthState 请求上下文中的带宽限制状态
+type bandwidthState struct {
+	limit    *BandwidthLimit
+	clientID string // 附加时提取的 clientId，转发时请求体可能已被目标请求引用，不能再读取
+}
+
+// TokenBucket 令牌桶，令牌按 rate 每秒匀速补充，最多积累 burst 个
+// 令牌不足时按预约顺序等待，并发取令牌的调用方平分速率
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket 创建装满令牌的令牌桶
+func NewTokenBucket(rate, burst int64) *TokenBucket {
+	return &TokenBucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Burst 返回令牌桶容量，单次取出的令牌数不应超过该值
+func (b *TokenBucket) Burst() int {
+	return int(b.burst)
+}
+
+// WaitN 取出 n 个令牌，令牌不足时等待补充，返回等待的时间；ctx 先结束时返回其错误
+func (b *TokenBucket) WaitN(ctx context.Context, n int) (time.Duration, error) {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if wait > 0 && !sleepContext(ctx, wait) {
+		return wait, context.Cause(ctx)
+	}
+	return wait, nil
+}
+
+// setRate 调整令牌补充速率，调整前已积累的令牌按原速率计算
+func (b *TokenBucket) setRate(rate int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate == float64(rate) {
+		return
+	}
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.rate = float64(rate)
+}
+
+// idle 令牌桶最近一次取令牌距今的时间
+func (b *TokenBucket) idle(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.last)
+}
+
+// BandwidthLimit 路由级上传与下载带宽限制
+// 同一 clientId 的请求按方向共享令牌桶，没有 clientId 的请求共享路由级令牌桶，令牌桶按 Rate 返回的速率补充令牌
+type BandwidthLimit struct {
+	upload         int64
+	download       int64
+	burst          int64
+	maxUpload      int64
+	maxDownload    int64
+	uploadHeader   string
+	downloadHeader string
+
+	mu        sync.Mutex
+	buckets   map[string]*TokenBucket
+	lastSweep time.Time
+}
+
+// NewBandwidthLimit 合并全局与路由的带宽限制，路由配置覆盖全局配置，不限速且不接受请求头时返回 nil
+func NewBandwidthLimit(global, route config.BandwidthConfig) *BandwidthLimit {
+	l := &BandwidthLimit{
+		upload:         global.Upload,
+		download:       global.Download,
+		burst:          global.Burst,
+		maxUpload:      global.MaxUpload,
+		maxDownload:    global.MaxDownload,
+		uploadHeader:   global.UploadHeader,
+		downloadHeader: global.DownloadHeader,
+		buckets:        make(map[string]*TokenBucket),
+		lastSweep:      time.Now(),
+	}
+	if route.Upload > 0 {
+		l.upload = route.Upload
+	}
+	if route.Download > 0 {
+		l.download = route.Download
+	}
+	if route.Burst > 0 {
+		l.burst = route.Burst
+	}
+	if route.MaxUpload > 0 {
+		l.maxUpload = route.MaxUpload
+	}
+	if route.MaxDownload > 0 {
+		l.maxDownload = route.MaxDownload
+	}
+	if l.upload == 0 && l.download == 0 && l.maxUpload == 0 && l.maxDownload == 0 {
+		return nil
+	}
+	if l.burst <= 0 {
+		l.burst = config.DefaultBandwidthBurst
+	}
+	return l
+}
+
+// WithBandwidthLimit 将路由带宽限制及请求的 clientId 附加到请求上下文，需在附加 clientId 提取规则之后调用
+func WithBandwidthLimit(r *http.Request, limit *BandwidthLimit) *http.Request {
+	if limit == nil {
+		return r
+	}
+	state := &bandwidthState{limit: limit, clientID: PeekClientID(r)}
+	return r.WithContext(context.WithValue(r.Context(), bandwidthKey{}, state))
+}
+
+// LimitUpload 返回按上传带宽限速读取的请求体，用于转发到上游的请求，请求上下文中没有带宽限制时原样返回
+func LimitUpload(r *http.Request, body io.ReadCloser) io.ReadCloser {
+	return limitBody(r, body, DirectionUpload)
+}
+
+// LimitDownload 返回按下载带宽限速读取的上游响应体，请求上下文中没有带宽限制时原样返回
+func LimitDownload(r *http.Request, body io.ReadCloser) io.ReadCloser {
+	return limitBody(r, body, DirectionDownload)
+}
+
+// limitBody 按请求在 direction 方向的速率限速读取 body
+func limitBody(r *http.Request, body io.ReadCloser, direction string) io.ReadCloser {
+	state, _ := r.Context().Value(bandwidthKey{}).(*bandwidthState)
+	if state == nil || body == nil || body == http.NoBody {
+		return body
+	}
+	rate := state.limit.Rate(r, direction)
+	if rate <= 0 {
+		return body
+	}
+	return &throttledBody{ReadCloser: body, ctx: r.Context(), bucket: state.limit.bucket(state.clientID, direction, rate), direction: direction}
+}
+
+// Rate 返回请求所属 clientId 在 direction 方向的共享速率，0 表示不限速
+// 配置了上限时客户端可以通过请求头覆盖该 clientId 的速率，超过上限时按上限限速
+func (l *BandwidthLimit) Rate(r *http.Request, direction string) int64 {
+	rate, maxRate, header := l.upload, l.maxUpload, l.uploadHeader
+	if direction == DirectionDownload {
+		rate, maxRate, header = l.download, l.maxDownload, l.downloadHeader
+	}
+	if maxRate <= 0 || header == "" {
+		return rate
+	}
+	if requested, ok := parseRate(r.Header.Get(header)); ok {
+		return min(requested, maxRate)
+	}
+	return rate
+}
+
+// bucket 返回请求的共享令牌桶，按 clientId 与方向区分，clientId 为空的请求共享同一个令牌桶
+// 令牌桶已存在时按本次请求的速率调整，同一 clientId 的并发请求合计不超过最近一个请求的速率
+func (l *BandwidthLimit) bucket(clientID, direction string, rate int64) *TokenBucket {
+	key := direction + "/" + clientID
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = NewTokenBucket(rate, l.burst)
+		l.buckets[key] = bucket
+	} else {
+		bucket.setRate(rate)
+	}
+	return bucket
+}
+
+// sweep 清理闲置的共享令牌桶，调用方需持有锁
+func (l *BandwidthLimit) sweep() {
+	now := time.Now()
+	if now.Sub(l.lastSweep) < bandwidthBucketIdle {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if bucket.idle(now) > bandwidthBucketIdle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// throttledBody 每次读取后从令牌桶取出读到的字节数，令牌不足时等待
+type throttledBody struct {
+	io.ReadCloser
+	ctx       context.Context
+	bucket    *TokenBucket // 同一 clientId 共享的令牌桶
+	direction string
+}
+
+// Read 单次最多读取令牌桶容量的字节数
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if burst := b.bucket.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		wait, waitErr := b.bucket.WaitN(b.ctx, n)
+		if wait > 0 {
+			metrics.ThrottledDuration.Add(float64(wait.Milliseconds()), b.direction)
+		}
+		if waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// parseRate 解析请求头中的速率，单位为字节/秒，支持 K、M 后缀（1024 进制）
+func parseRate(value string) (int64, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, false
+	}
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		unit, value = 1024, strings.TrimSuffix(value, "K")
+	case strings.HasSuffix(value, "M"):
+		unit, value = 1024*1024, strings.TrimSuffix(value, "M")
+	}
+	rate, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || rate <= 0 || rate > math.MaxInt64/unit {
+		return 0, false
+	}
+	return rate * unit, true
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+func TestBandwidthLimitSharedBucket(t *testing.T) {
+	payload := strings.Repeat("x", 600)
+
+	tests := []struct {
+		name     string
+		requests []http.Header // 并发发出的请求，clientId 取自请求头
+		minimum  time.Duration // 超出 burst 的字节按共享速率限速所需的最短时间
+	}{
+		// 三个请求共 1800 字节，超出 burst 的 1600 字节按配置速率 2000 限速
+		{"concurrent requests share configured rate", []http.Header{
+			{"clientId": {"client-a"}},
+			{"clientId": {"client-a"}},
+			{"clientId": {"client-a"}},
+		}, 800 * time.Millisecond},
+		// 请求头超过上限时按上限 4000 限速，并发请求合计不超过该速率
+		{"header overrides client rate up to max", []http.Header{
+			{"clientId": {"client-a"}, config.DefaultDownloadRateHeader: {"8000"}},
+			{"clientId": {"client-a"}, config.DefaultDownloadRateHeader: {"8000"}},
+			{"clientId": {"client-a"}, config.DefaultDownloadRateHeader: {"8000"}},
+		}, 400 * time.Millisecond},
+		{"anonymous requests share route bucket", []http.Header{
+			{},
+			{},
+		}, 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := NewBandwidthLimit(config.BandwidthConfig{
+				Download:       2000,
+				Burst:          200,
+				MaxDownload:    4000,
+				DownloadHeader: config.DefaultDownloadRateHeader,
+			}, config.BandwidthConfig{})
+			require.NotNil(t, limit)
+
+			start := time.Now()
+			var wg sync.WaitGroup
+			for _, header := range tt.requests {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.Header = header
+				r = WithBandwidthLimit(r, limit)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					data, err := io.ReadAll(LimitDownload(r, io.NopCloser(strings.NewReader(payload))))
+					assert.NoError(t, err)
+					assert.Equal(t, payload, string(data))
+				}()
+			}
+			wg.Wait()
+
+			assert.GreaterOrEqual(t, time.Since(start), tt.minimum-20*time.Millisecond)
+		})
+	}
+}
+
+func TestBandwidthLimitUnlimitedWithoutHeader(t *testing.T) {
+	limit := NewBandwidthLimit(config.BandwidthConfig{
+		MaxDownload:    4000,
+		DownloadHeader: config.DefaultDownloadRateHeader,
+	}, config.BandwidthConfig{})
+	require.NotNil(t, limit)
+	body := io.NopCloser(strings.NewReader("payload"))
+
+	r := WithBandwidthLimit(httptest.NewRequest(http.MethodGet, "/", nil), limit)
+	_, throttled := LimitDownload(r, body).(*throttledBody)
+	assert.False(t, throttled, "requests without rate header must not be throttled")
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(config.DefaultDownloadRateHeader, "1000")
+	r = WithBandwidthLimit(r, limit)
+	_, throttled = LimitDownload(r, body).(*throttledBody)
+	assert.True(t, throttled)
+}
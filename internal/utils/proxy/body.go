@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// PeekBody 读取最多 limit 字节的请求体，并将已读部分与剩余部分重新拼接为请求体，
+// 不影响后续处理器读取完整请求体
+func PeekBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	original := r.Body
+	body, err := io.ReadAll(io.LimitReader(original, limit))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), original), Closer: original}
+	return body, err
+}
+
+// readCloser 组合 Reader 与 Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+)
+
+// bodyLimitKey 上下文中路由请求体限制的键
+type bodyLimitKey struct{}
+
+// BodyLimit 路由级请求体与响应体限制
+type BodyLimit struct {
+	maxSize         int64
+	methods         map[string]int64
+	maxDecompressed int64
+	contentTypes    []string
+	maxResponse     int64
+}
+
+// NewBodyLimit 合并全局与路由的请求体限制，路由配置覆盖全局配置
+func NewBodyLimit(global, route config.BodyConfig) *BodyLimit {
+	l := &BodyLimit{
+		maxSize:         global.MaxSize,
+		methods:         make(map[string]int64, len(global.Methods)+len(route.Methods)),
+		maxDecompressed: global.MaxDecompressedSize,
+		contentTypes:    global.ContentTypes,
+		maxResponse:     global.MaxResponseSize,
+	}
+	for method, size := range global.Methods {
+		if size > 0 {
+			l.methods[method] = size
+		}
+	}
+	if route.MaxSize > 0 {
+		l.maxSize = route.MaxSize
+	}
+	for method, size := range route.Methods {
+		if size > 0 {
+			l.methods[method] = size
+		}
+	}
+	if route.MaxDecompressedSize > 0 {
+		l.maxDecompressed = route.MaxDecompressedSize
+	}
+	if len(route.ContentTypes) > 0 {
+		l.contentTypes = route.ContentTypes
+	}
+	if route.MaxResponseSize > 0 {
+		l.maxResponse = route.MaxResponseSize
+	}
+	return l
+}
+
+// Apply 检查请求的 Content-Type 与声明的长度，并限制后续读取的请求体大小
+// Content-Length 超限时不读取请求体直接返回 413；未声明长度时读取超限后请求体返回 413 错误
+func (l *BodyLimit) Apply(r *http.Request) *errs.ProxyError {
+	if l == nil || r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+
+	if len(l.contentTypes) > 0 {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !matchMediaType(l.contentTypes, mediaType) {
+			return errs.NewProxyErrorf(errs.CodeUnsupportedMediaType, "content type %q is not allowed", r.Header.Get("Content-Type"))
+		}
+	}
+
+	if maxSize := l.MaxSize(r.Method); maxSize > 0 {
+		if r.ContentLength > maxSize {
+			return errs.NewProxyErrorf(errs.CodeBodyTooLarge, "request body of %d bytes exceeds %d bytes", r.ContentLength, maxSize)
+		}
+		r.Body = &limitedBody{ReadCloser: r.Body, limit: maxSize}
+	}
+
+	return l.checkDecompressed(r)
+}
+
+// MaxSize 返回 method 请求的请求体最大字节数，按请求方法的配置覆盖 max_size，0 表示不限制
+func (l *BodyLimit) MaxSize(method string) int64 {
+	if l == nil {
+		return 0
+	}
+	if size, ok := l.methods[method]; ok {
+		return size
+	}
+	return l.maxSize
+}
+
+// WithBodyLimit 将路由请求体限制附加到请求上下文，需在附加 clientId 提取规则之前调用，
+// 之后缓存请求体、查找 clientId 时按路由与请求方法的上限读取
+func WithBodyLimit(r *http.Request, limit *BodyLimit) *http.Request {
+	if limit == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), bodyLimitKey{}, limit))
+}
+
+// MaxBodySize 返回请求上下文中路由请求体限制对该请求方法的上限，未附加路由限制或不限制时返回 0
+func MaxBodySize(r *http.Request) int64 {
+	limit, _ := r.Context().Value(bodyLimitKey{}).(*BodyLimit)
+	return limit.MaxSize(r.Method)
+}
+
+// checkDecompressed 对压缩的请求体试解压，防止解压后体积过大
+func (l *BodyLimit) checkDecompressed(r *http.Request) *errs.ProxyError {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if l.maxDecompressed <= 0 || !decodable(encoding) {
+		return nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return BodyReadError(err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	reader, err := newDecoder(encoding, bytes.NewReader(body))
+	if err != nil {
+		return errs.NewProxyErrorf(errs.CodeBadRequest, "invalid %s request body: %v", encoding, err)
+	}
+	defer reader.Close()
+
+	n, err := io.Copy(io.Discard, io.LimitReader(reader, l.maxDecompressed+1))
+	if err != nil {
+		return errs.NewProxyErrorf(errs.CodeBadRequest, "invalid %s request body: %v", encoding, err)
+	}
+	if n > l.maxDecompressed {
+		return errs.NewProxyErrorf(errs.CodeBodyTooLarge, "decompressed request body exceeds %d bytes", l.maxDecompressed)
+	}
+	return nil
+}
+
+// LimitResponse 限制写出的响应体大小
+// 声明的 Content-Length 超限时改为返回 502 错误；未声明长度时写出超限后截断响应
+func (l *BodyLimit) LimitResponse(w http.ResponseWriter, r *http.Request, errWriter *ErrorWriter) http.ResponseWriter {
+	if l == nil || l.maxResponse <= 0 {
+		return w
+	}
+	return &limitedResponse{ResponseWriter: w, r: r, errWriter: errWriter, limit: l.maxResponse}
+}
+
+// matchMediaType 判断媒体类型是否匹配列表中的任一项，支持 text/* 形式的通配
+func matchMediaType(patterns []string, mediaType string) bool {
+	for _, allowed := range patterns {
+		if allowed == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// BodyReadError 将读取请求体的错误归类为代理错误，超出请求体限制时返回 413
+func BodyReadError(err error) *errs.ProxyError {
+	var proxyErr *errs.ProxyError
+	if errors.As(err, &proxyErr) {
+		return proxyErr
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errs.NewProxyErrorf(errs.CodeBodyTooLarge, "request body exceeds %d bytes", maxBytesErr.Limit)
+	}
+	return errs.NewProxyErrorf(errs.CodeBadRequest, "failed to read request body: %v", err)
+}
+
+// limitedBody 读取超过 limit 字节后返回 413 错误的请求体
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+	err   *errs.ProxyError
+}
+
+// Read 实现 io.Reader
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	// 多读 1 字节用于判断是否超限
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		b.err = errs.NewProxyErrorf(errs.CodeBodyTooLarge, "request body exceeds %d bytes", b.limit)
+		return n - int(b.read-b.limit), b.err
+	}
+	return n, err
+}
+
+// limitedResponse 限制响应体大小的 ResponseWriter
+type limitedResponse struct {
+	http.ResponseWriter
+	r         *http.Request
+	errWriter *ErrorWriter
+	limit     int64
+	written   int64
+	rejected  bool
+}
+
+// WriteHeader 声明的 Content-Length 超限时改为输出错误
+func (w *limitedResponse) WriteHeader(statusCode int) {
+	if length, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil && length > w.limit {
+		w.rejected = true
+		w.Header().Del("Content-Length")
+		w.Header().Del("Content-Encoding")
+		w.errWriter.Write(w.ResponseWriter, w.r, errs.NewProxyErrorf(errs.CodeResponseTooLarge, "upstream response of %d bytes exceeds %d bytes", length, w.limit))
+		return
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write 写出超限时截断响应并返回错误，使转发停止复制响应体
+func (w *limitedResponse) Write(data []byte) (int, error) {
+	if w.rejected {
+		return len(data), nil
+	}
+	if w.written+int64(len(data)) > w.limit {
+		n, _ := w.ResponseWriter.Write(data[:w.limit-w.written])
+		w.written += int64(n)
+		logx.WithContext(w.r.Context()).Errorf("Upstream response truncated: exceeds %d bytes", w.limit)
+		return n, errs.NewProxyErrorf(errs.CodeResponseTooLarge, "upstream response exceeds %d bytes", w.limit)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush 透传 Flush，保证流式响应可用
+func (w *limitedResponse) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 使用
+func (w *limitedResponse) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
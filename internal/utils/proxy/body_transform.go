@@ -0,0 +1,272 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// BodyTransformer 路由级 JSON 请求体转换
+type BodyTransformer struct {
+	transforms []config.BodyTransformConfig
+}
+
+// NewBodyTransformer 根据路由配置创建请求体转换，未配置时返回 nil
+func NewBodyTransformer(cfgs []config.BodyTransformConfig) *BodyTransformer {
+	if len(cfgs) == 0 {
+		return nil
+	}
+	return &BodyTransformer{transforms: cfgs}
+}
+
+// Apply 按顺序执行转换并重写请求体与 Content-Length
+// 请求体不是 JSON 对象时只执行不涉及请求体的 copy
+func (t *BodyTransformer) Apply(r *http.Request) *errs.ProxyError {
+	if t == nil {
+		return nil
+	}
+
+	body, err := t.readJSONBody(r)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, transform := range t.transforms {
+		if transform.Op != config.TransformCopy && body == nil {
+			continue
+		}
+		if t.apply(transform, body, r) {
+			changed = true
+		}
+	}
+	if !changed || body == nil {
+		return nil
+	}
+
+	data, marshalErr := json.Marshal(body)
+	if marshalErr != nil {
+		return errs.NewProxyErrorf(errs.CodeInternal, "failed to encode transformed request body: %v", marshalErr)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return nil
+}
+
+// readJSONBody 读取 JSON 对象请求体，非 JSON 或压缩的请求体返回 nil 并保留原请求体
+func (t *BodyTransformer) readJSONBody(r *http.Request) (map[string]interface{}, *errs.ProxyError) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return nil, nil
+	}
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		logx.WithContext(r.Context()).Infof("Skip body transforms for %s encoded request body", encoding)
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, BodyReadError(err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	var body map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil || body == nil {
+		logx.WithContext(r.Context()).Infof("Skip body transforms: request body is not a JSON object")
+		return nil, nil
+	}
+	return body, nil
+}
+
+// apply 执行一个转换，返回请求体是否被修改
+func (t *BodyTransformer) apply(transform config.BodyTransformConfig, body map[string]interface{}, r *http.Request) bool {
+	switch transform.Op {
+	case config.TransformSet:
+		if transform.IfMissing {
+			if _, ok := getField(body, transform.Field); ok {
+				return false
+			}
+		}
+		value := utils.ExpandPathTemplate(transform.Value, func(name string) string {
+			switch name {
+			case config.HeaderVarClientIP:
+				return ClientIP(r)
+			case config.HeaderVarRequestID:
+				return RequestID(r)
+			}
+			return ""
+		})
+		return setField(body, transform.Field, value)
+	case config.TransformRename:
+		value, ok := getField(body, transform.Field)
+		if !ok {
+			return false
+		}
+		deleteField(body, transform.Field)
+		return setField(body, transform.To, value)
+	case config.TransformRemove:
+		return deleteField(body, transform.Field)
+	case config.TransformNormalizePath:
+		value, ok := getField(body, transform.Field)
+		if !ok {
+			return false
+		}
+		return setField(body, transform.Field, normalizePathValue(value))
+	case config.TransformCopy:
+		return t.copyValue(transform, body, r)
+	}
+	return false
+}
+
+// copyValue 在请求体、请求头与查询参数之间复制值
+func (t *BodyTransformer) copyValue(transform config.BodyTransformConfig, body map[string]interface{}, r *http.Request) bool {
+	fromKind, fromName, _ := config.ParseTransformLocation(transform.From)
+	toKind, toName, _ := config.ParseTransformLocation(transform.To)
+	if body == nil && (fromKind == config.TransformLocationBody || toKind == config.TransformLocationBody) {
+		return false
+	}
+
+	var value interface{}
+	switch fromKind {
+	case config.TransformLocationBody:
+		v, ok := getField(body, fromName)
+		if !ok {
+			return false
+		}
+		value = v
+	case config.TransformLocationHeader:
+		if r.Header.Get(fromName) == "" {
+			return false
+		}
+		value = r.Header.Get(fromName)
+	case config.TransformLocationQuery:
+		if r.URL.Query().Get(fromName) == "" {
+			return false
+		}
+		value = r.URL.Query().Get(fromName)
+	}
+
+	switch toKind {
+	case config.TransformLocationBody:
+		if _, ok := getField(body, toName); ok && transform.IfMissing {
+			return false
+		}
+		return setField(body, toName, value)
+	case config.TransformLocationHeader:
+		if r.Header.Get(toName) == "" || !transform.IfMissing {
+			r.Header.Set(toName, stringValue(value))
+		}
+	case config.TransformLocationQuery:
+		query := r.URL.Query()
+		if query.Get(toName) == "" || !transform.IfMissing {
+			query.Set(toName, stringValue(value))
+			r.URL.RawQuery = query.Encode()
+		}
+	}
+	return false
+}
+
+// normalizePathValue 将字符串或字符串数组中的路径统一为 / 分隔符
+func normalizePathValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return v
+		}
+		return utils.ToUnixPath(v)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizePathValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// stringValue 将 JSON 值转为请求头或查询参数使用的字符串
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case json.Number, bool:
+		return fmt.Sprint(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// getField 按点号分隔的字段路径取值
+func getField(body map[string]interface{}, field string) (interface{}, bool) {
+	parts := strings.Split(field, ".")
+	current := body
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	value, ok := current[parts[len(parts)-1]]
+	return value, ok
+}
+
+// setField 按点号分隔的字段路径设置值，缺少的中间对象会被创建，中间字段不是对象时不修改
+func setField(body map[string]interface{}, field string, value interface{}) bool {
+	parts := strings.Split(field, ".")
+	current := body
+	for _, part := range parts[:len(parts)-1] {
+		next, exists := current[part]
+		if !exists {
+			child := make(map[string]interface{})
+			current[part] = child
+			current = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		current = child
+	}
+	current[parts[len(parts)-1]] = value
+	return true
+}
+
+// deleteField 按点号分隔的字段路径删除字段
+func deleteField(body map[string]interface{}, field string) bool {
+	parts := strings.Split(field, ".")
+	current := body
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		current = next
+	}
+	if _, ok := current[parts[len(parts)-1]]; !ok {
+		return false
+	}
+	delete(current, parts[len(parts)-1])
+	return true
+}
@@ -0,0 +1,311 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// maxPeekBodySize 读取请求体查找 clientId 时的最大字节数，路由请求体上限更小时按路由上限读取
+const maxPeekBodySize = 100 * 1024 * 1024
+
+// clientIDKey 上下文中 clientId 提取状态的键
+type clientIDKey struct{}
+
+// clientIDState 请求上下文中的 clientId 提取规则与提取结果
+// 同一请求只提取一次，之后的查找直接返回缓存的结果，不再重复读取与解析请求体
+type clientIDState struct {
+	extractor *ClientIDExtractor
+	once      sync.Once
+	clientID  string
+	source    string
+}
+
+// defaultClientIDExtractor 请求上下文中没有提取规则时使用的默认规则
+var defaultClientIDExtractor = NewClientIDExtractor(nil, nil)
+
+// ClientIDExtractor 按配置顺序从请求中提取 clientId
+type ClientIDExtractor struct {
+	extractors []clientIDExtractor
+}
+
+type clientIDExtractor struct {
+	config.ClientIDExtractorConfig
+	pattern *regexp.Regexp
+}
+
+// NewClientIDExtractor 创建 clientId 提取规则，路由规则替换全局规则，均未配置时使用 config.DefaultClientIDExtractors
+// 配置应已通过 ProxyConfig.Validate 校验
+func NewClientIDExtractor(global, route []config.ClientIDExtractorConfig) *ClientIDExtractor {
+	cfgs := route
+	if len(cfgs) == 0 {
+		cfgs = global
+	}
+	if len(cfgs) == 0 {
+		cfgs = config.DefaultClientIDExtractors
+	}
+	e := &ClientIDExtractor{}
+	for _, cfg := range cfgs {
+		extractor := clientIDExtractor{ClientIDExtractorConfig: cfg}
+		if cfg.Source == config.ClientIDFromPath {
+			extractor.pattern, _ = utils.CompilePathPattern(cfg.Pattern)
+		}
+		e.extractors = append(e.extractors, extractor)
+	}
+	return e
+}
+
+// WithClientIDExtractor 将路由 clientId 提取规则附加到请求上下文，替换之前附加的规则与提取结果
+// 需在请求体转换之后调用，之后按该规则提取的 clientId 在整个请求内缓存
+func WithClientIDExtractor(r *http.Request, extractor *ClientIDExtractor) *http.Request {
+	if extractor == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), clientIDKey{}, &clientIDState{extractor: extractor}))
+}
+
+// WithDefaultClientIDExtractor 请求上下文中没有路由提取规则时附加全局提取规则
+func WithDefaultClientIDExtractor(r *http.Request, extractor *ClientIDExtractor) *http.Request {
+	if _, ok := r.Context().Value(clientIDKey{}).(*clientIDState); ok {
+		return r
+	}
+	return WithClientIDExtractor(r, extractor)
+}
+
+// PeekClientID 按请求上下文中的提取规则查找 clientId，不影响后续处理器读取请求体
+func PeekClientID(r *http.Request) string {
+	clientID, _ := PeekClientIDWithSource(r)
+	return clientID
+}
+
+// PeekClientIDWithSource 按请求上下文中的提取规则查找 clientId，同时返回其来源
+// 上下文中附加了提取规则时只在第一次调用时提取，否则每次按默认规则提取
+func PeekClientIDWithSource(r *http.Request) (string, string) {
+	state, ok := r.Context().Value(clientIDKey{}).(*clientIDState)
+	if !ok {
+		return defaultClientIDExtractor.Extract(r)
+	}
+	state.once.Do(func() {
+		state.clientID, state.source = state.extractor.Extract(r)
+	})
+	return state.clientID, state.source
+}
+
+// ClientIDSources 返回请求上下文中提取规则的描述，用于错误信息
+func ClientIDSources(r *http.Request) string {
+	if state, ok := r.Context().Value(clientIDKey{}).(*clientIDState); ok {
+		return state.extractor.String()
+	}
+	return defaultClientIDExtractor.String()
+}
+
+// Extract 依次尝试提取规则，返回第一个非空的 clientId 及其来源
+func (e *ClientIDExtractor) Extract(r *http.Request) (string, string) {
+	for _, extractor := range e.extractors {
+		if clientID := extractor.extract(r); clientID != "" {
+			return clientID, extractor.String()
+		}
+	}
+	return "", ""
+}
+
+// String 返回提取规则的描述，如 body:/clientId, query:clientId
+func (e *ClientIDExtractor) String() string {
+	sources := make([]string, len(e.extractors))
+	for i, extractor := range e.extractors {
+		sources[i] = extractor.String()
+	}
+	return strings.Join(sources, ", ")
+}
+
+// String 返回单条提取规则的描述
+func (e clientIDExtractor) String() string {
+	switch e.Source {
+	case config.ClientIDFromBody:
+		return e.Source + ":" + e.Pointer
+	case config.ClientIDFromPath:
+		return e.Source + ":" + e.Pattern
+	default:
+		return e.Source + ":" + e.Name
+	}
+}
+
+func (e clientIDExtractor) extract(r *http.Request) string {
+	switch e.Source {
+	case config.ClientIDFromQuery:
+		return r.URL.Query().Get(e.Name)
+	case config.ClientIDFromHeader:
+		return r.Header.Get(e.Name)
+	case config.ClientIDFromCookie:
+		if cookie, err := r.Cookie(e.Name); err == nil {
+			return cookie.Value
+		}
+	case config.ClientIDFromPath:
+		if e.pattern == nil {
+			return ""
+		}
+		if match := e.pattern.FindStringSubmatch(r.URL.Path); match != nil {
+			return match[e.pattern.SubexpIndex(e.Name)]
+		}
+	case config.ClientIDFromBody:
+		return extractJSONPointer(r, e.Pointer)
+	case config.ClientIDFromForm:
+		return extractFormField(r, e.Name)
+	case config.ClientIDFromJWT:
+		header := e.Header
+		if header == "" {
+			header = "Authorization"
+		}
+		claims, err := utils.ParseJWTClaims(r.Header.Get(header))
+		if err != nil {
+			return ""
+		}
+		return jsonString(lookupClaim(claims, e.Name))
+	}
+	return ""
+}
+
+// peekRequestBody 读取请求体用于查找 clientId，GET 请求与空请求体返回 nil
+func peekRequestBody(r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody || r.Method == http.MethodGet {
+		return nil
+	}
+	limit := int64(maxPeekBodySize)
+	if size := MaxBodySize(r); size > 0 && size < limit {
+		limit = size
+	}
+	body, err := PeekBody(r, limit)
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// extractJSONPointer 按 JSON Pointer（RFC 6901）从 JSON 请求体中取值
+// 只解析声明为 JSON 或未声明 Content-Type 的请求体，上传文件等其他类型的请求体不读取
+func extractJSONPointer(r *http.Request, pointer string) string {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		return ""
+	}
+	body := peekRequestBody(r)
+	if len(body) == 0 {
+		return ""
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return ""
+	}
+
+	if pointer == "" {
+		return jsonString(value)
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch current := value.(type) {
+		case map[string]interface{}:
+			value = current[token]
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(current) {
+				return ""
+			}
+			value = current[index]
+		default:
+			return ""
+		}
+	}
+	return jsonString(value)
+}
+
+// isJSONContentType 判断 Content-Type 是否为 JSON，未声明时按 JSON 处理以兼容旧版客户端
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// extractFormField 从 multipart 或 urlencoded 表单中取字段值
+func extractFormField(r *http.Request, name string) string {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(peekRequestBody(r)))
+		if err != nil {
+			return ""
+		}
+		return values.Get(name)
+	case "multipart/form-data":
+		body := peekRequestBody(r)
+		if len(body) == 0 || params["boundary"] == "" {
+			return ""
+		}
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) || err != nil {
+				return ""
+			}
+			if part.FormName() == name && part.FileName() == "" {
+				value, _ := io.ReadAll(io.LimitReader(part, 4096))
+				return strings.TrimSpace(string(value))
+			}
+		}
+	}
+	return ""
+}
+
+// lookupClaim 按点号分隔的名称查找 claim
+func lookupClaim(claims map[string]interface{}, name string) interface{} {
+	var value interface{} = claims
+	for _, key := range strings.Split(name, ".") {
+		current, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = current[key]
+	}
+	return value
+}
+
+// jsonString 将字符串或数字类型的 JSON 值转为字符串，其他类型返回空字符串
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// errClientIDMissing 描述未找到 clientId 时已尝试的来源
+func errClientIDMissing(r *http.Request) string {
+	return fmt.Sprintf("clientId is required, looked in: %s", ClientIDSources(r))
+}
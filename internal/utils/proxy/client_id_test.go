@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+func TestPeekClientIDContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json", "application/json", `{"clientId":"client-a"}`, "client-a"},
+		{"json with charset", "application/json; charset=utf-8", `{"clientId":"client-a"}`, "client-a"},
+		{"json suffix", "application/vnd.api+json", `{"clientId":"client-a"}`, "client-a"},
+		{"no content type", "", `{"clientId":"client-a"}`, "client-a"},
+		{"multipart upload", "multipart/form-data; boundary=x", `{"clientId":"client-a"}`, ""},
+		{"octet stream", "application/octet-stream", `{"clientId":"client-a"}`, ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		r = WithClientIDExtractor(r, NewClientIDExtractor(nil, nil))
+
+		assert.Equal(t, tt.want, PeekClientID(r), tt.name)
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, tt.body, string(body), tt.name)
+	}
+}
+
+func TestPeekClientIDExtractsOnce(t *testing.T) {
+	body := &countingReader{Reader: strings.NewReader(`{"clientId":"client-a"}`)}
+	r := httptest.NewRequest(http.MethodPost, "/search", io.NopCloser(body))
+	r = WithDefaultClientIDExtractor(r, NewClientIDExtractor(nil, nil))
+
+	clientID, source := PeekClientIDWithSource(r)
+	assert.Equal(t, "client-a", clientID)
+	assert.Equal(t, "body:/clientId", source)
+	reads := body.reads
+
+	// 之后的查找返回缓存的结果，不再读取请求体
+	for range 5 {
+		assert.Equal(t, "client-a", PeekClientID(r))
+	}
+	assert.Equal(t, reads, body.reads)
+
+	// 更换提取规则后按新规则重新提取
+	r.URL.RawQuery = "id=client-b"
+	r = WithClientIDExtractor(r, NewClientIDExtractor([]config.ClientIDExtractorConfig{{Source: config.ClientIDFromQuery, Name: "id"}}, nil))
+	assert.Equal(t, "client-b", PeekClientID(r))
+}
+
+func TestPeekClientIDRouteBodyLimit(t *testing.T) {
+	payload := `{"clientId":"client-a","content":"` + strings.Repeat("x", 64) + `"}`
+	tests := []struct {
+		name  string
+		body  config.BodyConfig
+		want  string
+		limit int
+	}{
+		{"method limit caps peek", config.BodyConfig{MaxSize: 1024, Methods: map[string]int64{http.MethodPost: 32}}, "", 32},
+		{"route limit caps peek", config.BodyConfig{MaxSize: 16}, "", 16},
+		{"body within limit", config.BodyConfig{MaxSize: 1024}, "client-a", len(payload)},
+	}
+
+	for _, tt := range tests {
+		body := &countingReader{Reader: strings.NewReader(payload)}
+		r := httptest.NewRequest(http.MethodPost, "/search", io.NopCloser(body))
+		r = WithBodyLimit(r, NewBodyLimit(config.BodyConfig{}, tt.body))
+		r = WithClientIDExtractor(r, NewClientIDExtractor(nil, nil))
+
+		assert.Equal(t, tt.want, PeekClientID(r), tt.name)
+		assert.LessOrEqual(t, body.bytes, tt.limit, tt.name)
+		// 查找 clientId 不影响后续读取完整请求体
+		read, _ := io.ReadAll(r.Body)
+		assert.Equal(t, payload, string(read), tt.name)
+	}
+}
+
+// countingReader 记录 Read 调用次数与读取的字节数
+type countingReader struct {
+	io.Reader
+	reads int
+	bytes int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.reads++
+	n, err := r.Reader.Read(p)
+	r.bytes += n
+	return n, err
+}
@@ -0,0 +1,300 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+)
+
+// Compressor 路由级网关侧压缩，按客户端 Accept-Encoding 压缩响应，并可解压请求体
+type Compressor struct {
+	enabled           bool
+	encodings         []string
+	minSize           int64
+	contentTypes      []string
+	decompressRequest bool
+}
+
+// NewCompressor 合并全局与路由的压缩配置，均未开启时返回 nil
+func NewCompressor(global, route config.CompressionConfig) *Compressor {
+	c := &Compressor{
+		enabled:           (global.Enabled || route.Enabled) && !route.Disabled,
+		encodings:         config.DefaultCompressionEncodings,
+		minSize:           config.DefaultCompressionMinSize,
+		contentTypes:      config.DefaultCompressionContentTypes,
+		decompressRequest: global.DecompressRequest || route.DecompressRequest,
+	}
+	for _, cfg := range []config.CompressionConfig{global, route} {
+		if len(cfg.Encodings) > 0 {
+			c.encodings = cfg.Encodings
+		}
+		if cfg.MinSize > 0 {
+			c.minSize = cfg.MinSize
+		}
+		if len(cfg.ContentTypes) > 0 {
+			c.contentTypes = cfg.ContentTypes
+		}
+	}
+	if !c.enabled && !c.decompressRequest {
+		return nil
+	}
+	return c
+}
+
+// DecompressRequest 开启请求解压时将压缩的请求体替换为解压后的内容，并移除 Content-Encoding
+// 解压后的大小由请求体限制中的 max_decompressed_size 预先校验
+func (c *Compressor) DecompressRequest(r *http.Request) *errs.ProxyError {
+	if c == nil || !c.decompressRequest || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+
+	if !decodable(encoding) {
+		return errs.NewProxyErrorf(errs.CodeUnsupportedMediaType, "unsupported content encoding: %s", encoding)
+	}
+	reader, err := newDecoder(encoding, r.Body)
+	if err != nil {
+		return errs.NewProxyErrorf(errs.CodeBadRequest, "invalid %s request body: %v", encoding, err)
+	}
+	r.Body = &decodedBody{ReadCloser: reader, original: r.Body}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// Compress 返回按协商的编码压缩响应的 ResponseWriter，处理完成后必须调用返回的 finish
+func (c *Compressor) Compress(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	if c == nil || !c.enabled || r.Method == http.MethodHead {
+		return w, func() {}
+	}
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), c.encodings)
+	if encoding == "" {
+		return w, func() {}
+	}
+	cw := &compressWriter{ResponseWriter: w, compressor: c, encoding: encoding}
+	return cw, cw.finish
+}
+
+// compressible 判断响应是否可以压缩
+func (c *Compressor) compressible(header http.Header, statusCode int) bool {
+	if statusCode < http.StatusOK || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		return false
+	}
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	return err == nil && matchMediaType(c.contentTypes, mediaType)
+}
+
+// negotiateEncoding 按 Accept-Encoding 的 q 值选择编码，q 值相同时按配置的优先级
+func negotiateEncoding(acceptEncoding string, encodings []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, ok := weights[encoding]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// newEncoder 创建指定编码的压缩器
+func newEncoder(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case config.EncodingGzip:
+		return gzip.NewWriter(w), nil
+	case config.EncodingBrotli:
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	case config.EncodingZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+// decodable 判断请求体编码是否可以解压
+func decodable(encoding string) bool {
+	switch encoding {
+	case config.EncodingGzip, config.EncodingDeflate, config.EncodingBrotli, config.EncodingZstd:
+		return true
+	}
+	return false
+}
+
+// newDecoder 创建指定编码的解压器
+func newDecoder(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case config.EncodingGzip:
+		return gzip.NewReader(r)
+	case config.EncodingDeflate:
+		return flate.NewReader(r), nil
+	case config.EncodingBrotli:
+		return io.NopCloser(brotli.NewReader(r)), nil
+	case config.EncodingZstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+// decodedBody 解压后的请求体，关闭时同时关闭原始请求体
+type decodedBody struct {
+	io.ReadCloser
+	original io.Closer
+}
+
+// Close 关闭解压器与原始请求体
+func (b *decodedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.original.Close()
+}
+
+// compressWriter 压缩响应的 ResponseWriter
+// 未声明 Content-Length 时先缓存响应体，达到 min_size 后才决定压缩
+type compressWriter struct {
+	http.ResponseWriter
+	compressor  *Compressor
+	encoding    string
+	statusCode  int
+	wroteHeader bool // 是否已调用 WriteHeader
+	decided     bool // 是否已决定是否压缩并写出响应头
+	buf         bytes.Buffer
+	encoder     io.WriteCloser
+}
+
+// WriteHeader 记录状态码，声明了 Content-Length 或不可压缩时立即决定
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.statusCode = statusCode
+
+	if !w.compressor.compressible(w.Header(), statusCode) {
+		w.decide(false)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	if length, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil {
+		w.decide(length >= w.compressor.minSize)
+	}
+}
+
+// Write 写出响应体，未决定时缓存到 min_size
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf.Write(data)
+	if int64(w.buf.Len()) >= w.compressor.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Flush 流式响应刷新时不再等待 min_size，直接按压缩输出
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.decide(true)
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 使用
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide 写出响应头与缓存的响应体
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		if encoder, err := newEncoder(w.encoding, w.ResponseWriter); err == nil {
+			w.encoder = encoder
+			w.Header().Set("Content-Encoding", w.encoding)
+			w.Header().Del("Content-Length")
+			w.Header().Del("Accept-Ranges")
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+// finish 输出未达到 min_size 的缓存响应体，或结束压缩流
+func (w *compressWriter) finish() {
+	if !w.wroteHeader {
+		return
+	}
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
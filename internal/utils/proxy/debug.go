@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// DebugGate 调试模式入口，校验请求是否允许查看转发决策
+// 请求通过共享密钥或用户白名单任一校验即可
+type DebugGate struct {
+	cfg            config.DebugConfig
+	userInfoHeader string
+	allowedUsers   map[string]bool
+}
+
+// NewDebugGate 创建调试模式入口，配置应已通过 ProxyConfig.Validate 填充默认值
+func NewDebugGate(cfg config.DebugConfig, userInfoHeader string) *DebugGate {
+	g := &DebugGate{
+		cfg:            cfg,
+		userInfoHeader: userInfoHeader,
+		allowedUsers:   make(map[string]bool, len(cfg.AllowedUsers)),
+	}
+	for _, user := range cfg.AllowedUsers {
+		g.allowedUsers[user] = true
+	}
+	return g
+}
+
+// ResponseHeader 返回 trace 模式下写入转发决策的响应头
+func (g *DebugGate) ResponseHeader() string {
+	return g.cfg.ResponseHeader
+}
+
+// Extract 返回请求的调试模式，并从请求中移除调试请求头、密钥与查询参数，避免转发到上游
+// 未启用、未携带调试标记、模式无效或未通过校验时返回空字符串，请求按正常流程转发
+func (g *DebugGate) Extract(r *http.Request) string {
+	if !g.cfg.Enabled {
+		return ""
+	}
+
+	mode := r.Header.Get(g.cfg.Header)
+	query := r.URL.Query()
+	if mode == "" {
+		mode = query.Get(g.cfg.QueryParam)
+	}
+	authorized := g.authorized(r)
+
+	r.Header.Del(g.cfg.Header)
+	r.Header.Del(g.cfg.SecretHeader)
+	if query.Has(g.cfg.QueryParam) {
+		query.Del(g.cfg.QueryParam)
+		r.URL.RawQuery = query.Encode()
+	}
+
+	if mode == "" {
+		return ""
+	}
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode != config.DebugModeDryRun && mode != config.DebugModeTrace {
+		logx.WithContext(r.Context()).Infof("Ignore unknown debug mode %q from %s", mode, ClientIP(r))
+		return ""
+	}
+	if !authorized {
+		logx.WithContext(r.Context()).Errorf("Reject debug %s request from %s: not authorized", mode, ClientIP(r))
+		return ""
+	}
+	logx.WithContext(r.Context()).Infof("Debug %s request %s %s from %s", mode, r.Method, r.URL.Path, ClientIP(r))
+	return mode
+}
+
+// authorized 请求携带正确的共享密钥或来自白名单用户
+func (g *DebugGate) authorized(r *http.Request) bool {
+	if g.cfg.Secret != "" {
+		secret := r.Header.Get(g.cfg.SecretHeader)
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(g.cfg.Secret)) == 1 {
+			return true
+		}
+	}
+	if len(g.allowedUsers) > 0 {
+		if user := utils.ParseJWTUserInfo(r, g.userInfoHeader); user != "" && g.allowedUsers[user] {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact 返回隐藏凭据类请求头与用户信息请求头取值后的副本，用于调试输出
+func (g *DebugGate) Redact(headers http.Header) http.Header {
+	return RedactHeaders(headers, g.userInfoHeader)
+}
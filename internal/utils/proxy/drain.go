@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/metrics"
+)
+
+// 优雅停机阶段，与 metrics.ShutdownPhase 的取值一致
+const (
+	phaseServing  = 0
+	phaseNotReady = 1
+	phaseDraining = 2
+	phaseClosed   = 3
+)
+
+// drainProgressInterval 排空期间输出进度日志的间隔
+const drainProgressInterval = time.Second
+
+// Drainer 优雅停机协调器
+// 统计进行中的请求，停机时先标记未就绪，再等待进行中请求完成，超时后取消剩余请求并关闭转发连接
+type Drainer struct {
+	cfg      config.GracefulShutdownConfig
+	phase    atomic.Int32
+	inflight atomic.Int64
+	idle     chan struct{} // 进行中请求归零时通知排空
+	abort    context.Context
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+	closers  []namedCloser
+}
+
+type namedCloser struct {
+	name  string
+	close func() error
+}
+
+// NewDrainer 创建优雅停机协调器
+func NewDrainer(cfg config.GracefulShutdownConfig) *Drainer {
+	abort, cancel := context.WithCancel(context.Background())
+	metrics.ShutdownPhase.Set(phaseServing)
+	return &Drainer{
+		cfg:    cfg.WithDefaults(),
+		idle:   make(chan struct{}, 1),
+		abort:  abort,
+		cancel: cancel,
+	}
+}
+
+// Config 返回填充默认值后的优雅停机配置
+func (d *Drainer) Config() config.GracefulShutdownConfig {
+	return d.cfg
+}
+
+// Ready 是否可以接收新请求，收到停机信号后返回 false
+func (d *Drainer) Ready() bool {
+	return d.phase.Load() == phaseServing
+}
+
+// Inflight 返回进行中的请求数
+func (d *Drainer) Inflight() int64 {
+	return d.inflight.Load()
+}
+
+// OnClose 注册排空结束后需要关闭的资源，按注册顺序关闭
+func (d *Drainer) OnClose(name string, close func() error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closers = append(d.closers, namedCloser{name: name, close: close})
+}
+
+// Handle 统计进行中的请求，排空超时后取消请求上下文
+func (d *Drainer) Handle(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d.inflight.Add(1)
+		metrics.InflightRequests.Inc()
+		defer func() {
+			metrics.InflightRequests.Dec()
+			if d.inflight.Add(-1) == 0 && d.phase.Load() >= phaseDraining {
+				select {
+				case d.idle <- struct{}{}:
+				default:
+				}
+			}
+		}()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(d.abort, cancel)
+		defer stop()
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// BeginShutdown 标记未就绪，健康检查开始返回 503，此时仍正常处理请求
+func (d *Drainer) BeginShutdown() {
+	if !d.phase.CompareAndSwap(phaseServing, phaseNotReady) {
+		return
+	}
+	metrics.ShutdownPhase.Set(phaseNotReady)
+	logx.Infof("Graceful shutdown: marked not ready, stop accepting new requests in %v", d.cfg.ReadinessDelay)
+}
+
+// Drain 等待进行中的请求完成，超过 drain_timeout 后取消剩余请求，最后关闭注册的资源
+func (d *Drainer) Drain() {
+	d.BeginShutdown()
+	if !d.phase.CompareAndSwap(phaseNotReady, phaseDraining) {
+		return
+	}
+	metrics.ShutdownPhase.Set(phaseDraining)
+
+	start := time.Now()
+	remaining := d.inflight.Load()
+	logx.Infof("Graceful shutdown: draining %d in-flight requests, deadline %v", remaining, d.cfg.DrainTimeout)
+
+	deadline := time.NewTimer(d.cfg.DrainTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(drainProgressInterval)
+	defer ticker.Stop()
+
+	for d.inflight.Load() > 0 {
+		select {
+		case <-d.idle:
+		case <-ticker.C:
+			logx.Infof("Graceful shutdown: %d in-flight requests remaining after %v", d.inflight.Load(), time.Since(start).Round(time.Millisecond))
+		case <-deadline.C:
+			aborted := d.inflight.Load()
+			logx.Errorf("Graceful shutdown: drain timeout after %v, aborting %d in-flight requests", d.cfg.DrainTimeout, aborted)
+			metrics.DrainedRequests.Add(float64(aborted), "aborted")
+			if completed := remaining - aborted; completed > 0 {
+				metrics.DrainedRequests.Add(float64(completed), "completed")
+			}
+			d.cancel()
+			d.close()
+			return
+		}
+	}
+
+	logx.Infof("Graceful shutdown: drained %d in-flight requests in %v", remaining, time.Since(start).Round(time.Millisecond))
+	metrics.DrainedRequests.Add(float64(remaining), "completed")
+	d.cancel()
+	d.close()
+}
+
+// close 关闭注册的资源
+func (d *Drainer) close() {
+	d.mu.Lock()
+	closers := d.closers
+	d.closers = nil
+	d.mu.Unlock()
+
+	for _, closer := range closers {
+		if err := closer.close(); err != nil {
+			logx.Errorf("Graceful shutdown: failed to close %s: %v", closer.name, err)
+			continue
+		}
+		logx.Infof("Graceful shutdown: closed %s", closer.name)
+	}
+	d.phase.Store(phaseClosed)
+	metrics.ShutdownPhase.Set(phaseClosed)
+}
@@ -4,76 +4,73 @@ import (
 	"encoding/json"
 	"net/http"
 	"time"
-)
-
-// ProxyError 代理错误响应
-type ProxyError struct {
-	Code      string    `json:"code"`
-	Message   string    `json:"message"`
-	Details   string    `json:"details,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
-}
 
-// ErrorCode 定义错误码常量
-const (
-	ErrorCodeBadRequest        = "PROXY_BAD_REQUEST"
-	ErrorCodeTargetUnreachable = "PROXY_TARGET_UNREACHABLE"
-	ErrorCodeTimeout           = "PROXY_TIMEOUT"
-	ErrorCodeInternalError     = "PROXY_INTERNAL_ERROR"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+	"github.com/zgsm-ai/codebase-indexer/internal/response"
 )
 
-// CreateProxyError 创建统一错误响应
-func CreateProxyError(code, message, details string) *ProxyError {
-	return &ProxyError{
-		Code:      code,
-		Message:   message,
-		Details:   details,
-		Timestamp: time.Now().UTC(),
-	}
-}
-
-// SendErrorResponse 发送错误响应
-func SendErrorResponse(w http.ResponseWriter, err *ProxyError, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// problemContentType RFC 7807 错误响应类型
+const problemContentType = "application/problem+json"
 
-	if err != nil {
-		json.NewEncoder(w).Encode(err)
-	}
+// problem RFC 7807 错误响应体，code、requestId、timestamp 为扩展字段
+type problem struct {
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Status    int       `json:"status"`
+	Detail    string    `json:"detail,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	Code      errs.Code `json:"code"`
+	RequestID string    `json:"requestId,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
-// NewBadRequestError 创建400错误
-func NewBadRequestError(details string) *ProxyError {
-	return CreateProxyError(
-		ErrorCodeBadRequest,
-		"Invalid request format",
-		details,
-	)
+// ErrorWriter 按配置的格式写出代理错误
+type ErrorWriter struct {
+	format          string
+	problemTypeBase string
 }
 
-// NewTargetUnreachableError 创建503错误
-func NewTargetUnreachableError(details string) *ProxyError {
-	return CreateProxyError(
-		ErrorCodeTargetUnreachable,
-		"Target service is unreachable",
-		details,
-	)
+// NewErrorWriter 创建错误响应写出器
+func NewErrorWriter(cfg config.ErrorsConfig) *ErrorWriter {
+	return &ErrorWriter{format: cfg.Format, problemTypeBase: cfg.ProblemTypeBase}
 }
 
-// NewTimeoutError 创建504错误
-func NewTimeoutError(details string) *ProxyError {
-	return CreateProxyError(
-		ErrorCodeTimeout,
-		"Request timeout",
-		details,
-	)
-}
+// Write 写出错误响应，状态码由错误码决定，非代理错误视为内部错误
+// 处于回退尝试中且错误可回退时交由回退链处理；附加了路由错误页且匹配错误码时，改为输出错误页
+func (ew *ErrorWriter) Write(w http.ResponseWriter, r *http.Request, err error) {
+	proxyErr := errs.AsProxyError(err)
+	proxyErr.RequestID = RequestID(r)
+	if catchFallback(r, proxyErr) || serveErrorPage(w, r, proxyErr) {
+		return
+	}
+	status := proxyErr.Status()
 
-// NewInternalError 创建500错误
-func NewInternalError(details string) *ProxyError {
-	return CreateProxyError(
-		ErrorCodeInternalError,
-		"Internal server error",
-		details,
-	)
+	if ew != nil && ew.format == config.ErrorFormatProblem {
+		problemType := "about:blank"
+		if ew.problemTypeBase != "" {
+			problemType = ew.problemTypeBase + string(proxyErr.Code)
+		}
+		w.Header().Set("Content-Type", problemContentType)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(&problem{
+			Type:      problemType,
+			Title:     proxyErr.Message,
+			Status:    status,
+			Detail:    proxyErr.Details,
+			Instance:  r.URL.Path,
+			Code:      proxyErr.Code,
+			RequestID: proxyErr.RequestID,
+			Timestamp: proxyErr.Timestamp,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&response.Response[*errs.ProxyError]{
+		Code:    proxyErr.Code.Number(),
+		Message: proxyErr.Message,
+		Data:    proxyErr,
+	})
 }
@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+)
+
+// maxReplayBodySize 为 redirect 错误页缓存的最大请求体
+const maxReplayBodySize = 10 * 1024 * 1024
+
+// errorPagesKey 上下文中路由错误页的键
+type errorPagesKey struct{}
+
+// RedirectFunc 将请求转发到 redirect 错误页配置的目标
+type RedirectFunc func(w http.ResponseWriter, r *http.Request, targetURL string)
+
+// ErrorPages 路由级错误页，按顺序匹配错误码
+type ErrorPages struct {
+	pages    []*errorPage
+	redirect RedirectFunc
+	replay   bool // 是否需要缓存请求体以便 redirect 重发
+}
+
+type errorPage struct {
+	config.ErrorPageConfig
+	codes map[errs.Code]bool // 为 nil 时匹配全部
+	tpl   *template.Template
+}
+
+// errorPagesContext 附加到请求上下文的错误页与缓存的请求体
+type errorPagesContext struct {
+	pages *ErrorPages
+	body  []byte
+	full  bool // 请求体是否完整缓存
+}
+
+// ErrorPageData 模板错误页可用的数据
+type ErrorPageData struct {
+	Code      errs.Code
+	Status    int
+	Message   string
+	Details   string
+	RequestID string
+	Method    string
+	Path      string
+	ClientID  string
+}
+
+// errorPageFuncs 模板函数，json 将值编码为 JSON 字面量，便于拼接 JSON 响应体
+var errorPageFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// NewErrorPages 根据路由配置创建错误页，未配置时返回 nil
+func NewErrorPages(cfgs []config.ErrorPageConfig, redirect RedirectFunc) (*ErrorPages, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	p := &ErrorPages{redirect: redirect}
+	for i, cfg := range cfgs {
+		page := &errorPage{ErrorPageConfig: cfg}
+		for _, code := range cfg.Codes {
+			if code == config.ErrorPageWildcard {
+				page.codes = nil
+				break
+			}
+			if page.codes == nil {
+				page.codes = make(map[errs.Code]bool, len(cfg.Codes))
+			}
+			page.codes[errs.Code(code)] = true
+		}
+		switch cfg.Type {
+		case config.ErrorPageTemplate:
+			tpl, err := template.New(fmt.Sprintf("error_pages[%d]", i)).Funcs(errorPageFuncs).Parse(cfg.Body)
+			if err != nil {
+				return nil, fmt.Errorf("error_pages[%d]: %w", i, err)
+			}
+			page.tpl = tpl
+		case config.ErrorPageRedirect:
+			if redirect == nil {
+				return nil, fmt.Errorf("error_pages[%d]: redirect is not supported here", i)
+			}
+			p.replay = true
+		}
+		p.pages = append(p.pages, page)
+	}
+	return p, nil
+}
+
+// WithErrorPages 将路由错误页附加到请求上下文，配置了 redirect 时缓存请求体以便重发
+func WithErrorPages(r *http.Request, pages *ErrorPages) *http.Request {
+	if pages == nil {
+		return r
+	}
+	value := &errorPagesContext{pages: pages}
+	if pages.replay {
+		body, err := PeekBody(r, maxReplayBodySize+1)
+		value.body = body
+		value.full = err == nil && len(body) <= maxReplayBodySize
+	}
+	return r.WithContext(context.WithValue(r.Context(), errorPagesKey{}, value))
+}
+
+// serveErrorPage 按上下文中的路由错误页输出错误，没有匹配的错误页时返回 false
+func serveErrorPage(w http.ResponseWriter, r *http.Request, proxyErr *errs.ProxyError) bool {
+	value, _ := r.Context().Value(errorPagesKey{}).(*errorPagesContext)
+	if value == nil || value.pages == nil {
+		return false
+	}
+	page := value.pages.match(proxyErr.Code)
+	if page == nil {
+		return false
+	}
+
+	status := page.Status
+	if status == 0 {
+		status = proxyErr.Status()
+	}
+	contentType := page.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	switch page.Type {
+	case config.ErrorPageRedirect:
+		if !value.full {
+			logx.WithContext(r.Context()).Errorf("Skip redirect error page for %s: request body too large to replay", proxyErr.Code)
+			return false
+		}
+		logx.WithContext(r.Context()).Infof("Redirecting request to %s after %s", page.URL, proxyErr.Code)
+		// 清除错误页，避免重发失败时再次触发
+		redirected := r.WithContext(context.WithValue(r.Context(), errorPagesKey{}, (*errorPagesContext)(nil)))
+		redirected.Body = io.NopCloser(bytes.NewReader(value.body))
+		redirected.ContentLength = int64(len(value.body))
+		value.pages.redirect(w, redirected, page.URL)
+	case config.ErrorPageTemplate:
+		var buf bytes.Buffer
+		err := page.tpl.Execute(&buf, &ErrorPageData{
+			Code:      proxyErr.Code,
+			Status:    status,
+			Message:   proxyErr.Message,
+			Details:   proxyErr.Details,
+			RequestID: proxyErr.RequestID,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			ClientID:  PeekClientID(r),
+		})
+		if err != nil {
+			logx.WithContext(r.Context()).Errorf("Failed to render error page for %s: %v", proxyErr.Code, err)
+			return false
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write(buf.Bytes())
+	default:
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		io.WriteString(w, page.Body)
+	}
+	return true
+}
+
+func (p *ErrorPages) match(code errs.Code) *errorPage {
+	for _, page := range p.pages {
+		if page.codes == nil || page.codes[code] {
+			return page
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+)
+
+// 回退相关响应头
+const (
+	StrategyHeader     = "X-Proxy-Strategy"      // 实际使用的转发策略
+	FallbackFromHeader = "X-Proxy-Fallback-From" // 失败后被跳过的策略，逗号分隔
+)
+
+// fallbackChainKey 上下文中路由回退链的键
+type fallbackChainKey struct{}
+
+// fallbackAttemptKey 上下文中当前回退尝试的键
+type fallbackAttemptKey struct{}
+
+// StrategyFunc 按策略转发请求
+type StrategyFunc func(strategy string, w http.ResponseWriter, r *http.Request)
+
+// FallbackChain 路由级回退链
+type FallbackChain struct {
+	chain    []string
+	triggers map[errs.Code]bool
+}
+
+// NewFallbackChain 根据路由配置创建回退链，未配置时返回 nil
+func NewFallbackChain(cfg config.FallbackConfig) *FallbackChain {
+	if len(cfg.Chain) == 0 {
+		return nil
+	}
+	on := cfg.On
+	if len(on) == 0 {
+		on = config.DefaultFallbackOn
+	}
+	c := &FallbackChain{chain: cfg.Chain, triggers: make(map[errs.Code]bool, len(on))}
+	for _, code := range on {
+		c.triggers[errs.Code(code)] = true
+	}
+	return c
+}
+
+// WithFallbackChain 将路由回退链附加到请求上下文
+func WithFallbackChain(r *http.Request, chain *FallbackChain) *http.Request {
+	if chain == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), fallbackChainKey{}, chain))
+}
+
+// FallbackChainFromContext 返回请求上下文中的路由回退链
+func FallbackChainFromContext(r *http.Request) *FallbackChain {
+	chain, _ := r.Context().Value(fallbackChainKey{}).(*FallbackChain)
+	return chain
+}
+
+// Run 从 start 策略开始转发请求，失败且错误码可回退时依次尝试链中其后的策略
+// start 不在链中或链为空时只执行 start，实际使用的策略写入 X-Proxy-Strategy 响应头
+func (c *FallbackChain) Run(w http.ResponseWriter, r *http.Request, start string, serve StrategyFunc) {
+	strategies := c.from(start)
+	if len(strategies) <= 1 {
+		w.Header().Set(StrategyHeader, start)
+		serve(start, w, r)
+		return
+	}
+
+	body, err := PeekBody(r, maxReplayBodySize+1)
+	if err != nil || len(body) > maxReplayBodySize {
+		logx.WithContext(r.Context()).Errorf("Skip fallback chain: request body too large to replay")
+		w.Header().Set(StrategyHeader, start)
+		serve(start, w, r)
+		return
+	}
+
+	var failed []string
+	for i, strategy := range strategies {
+		w.Header().Set(StrategyHeader, strategy)
+		req := r
+		if len(body) > 0 {
+			req = r.Clone(r.Context())
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		// 最后一个策略的错误正常输出
+		if i == len(strategies)-1 {
+			serve(strategy, w, req)
+			return
+		}
+
+		attempt := &fallbackAttempt{ResponseWriter: w, triggers: c.triggers}
+		serve(strategy, attempt, req.WithContext(context.WithValue(req.Context(), fallbackAttemptKey{}, attempt)))
+		if attempt.err == nil {
+			return
+		}
+		failed = append(failed, strategy)
+		w.Header().Set(FallbackFromHeader, strings.Join(failed, ", "))
+		logx.WithContext(r.Context()).Infof("Strategy %s failed with %s, falling back to %s", strategy, attempt.err.Code, strategies[i+1])
+	}
+}
+
+// Strategies 返回从 start 开始依次尝试的策略，start 不在链中或链为空时只有 start
+func (c *FallbackChain) Strategies(start string) []string {
+	if strategies := c.from(start); len(strategies) > 1 {
+		return strategies
+	}
+	return []string{start}
+}
+
+// from 返回从 start 开始、到 error 之前的策略
+func (c *FallbackChain) from(start string) []string {
+	if c == nil {
+		return nil
+	}
+	for i, strategy := range c.chain {
+		if strategy != start {
+			continue
+		}
+		var strategies []string
+		for _, next := range c.chain[i:] {
+			if next == config.StrategyError {
+				break
+			}
+			strategies = append(strategies, next)
+		}
+		return strategies
+	}
+	return nil
+}
+
+// fallbackAttempt 回退尝试的 ResponseWriter，记录是否已经开始写出响应
+type fallbackAttempt struct {
+	http.ResponseWriter
+	triggers map[errs.Code]bool
+	written  bool
+	err      *errs.ProxyError
+}
+
+// WriteHeader 标记响应已开始写出
+func (a *fallbackAttempt) WriteHeader(statusCode int) {
+	a.written = true
+	a.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write 标记响应已开始写出
+func (a *fallbackAttempt) Write(data []byte) (int, error) {
+	a.written = true
+	return a.ResponseWriter.Write(data)
+}
+
+// Flush 透传 Flush，保证流式响应可用
+func (a *fallbackAttempt) Flush() {
+	if flusher, ok := a.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 使用
+func (a *fallbackAttempt) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
+
+// catchFallback 回退尝试中尚未写出响应且错误码可回退时拦截错误，由回退链继续尝试下一个策略
+func catchFallback(r *http.Request, proxyErr *errs.ProxyError) bool {
+	attempt, _ := r.Context().Value(fallbackAttemptKey{}).(*fallbackAttempt)
+	if attempt == nil || attempt.written || attempt.err != nil || !attempt.triggers[proxyErr.Code] {
+		return false
+	}
+	attempt.err = proxyErr
+	return true
+}
@@ -0,0 +1,267 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/metrics"
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// 故障类型，用于日志与监控
+const (
+	FaultDelay    = "delay"
+	FaultAbort    = "abort"
+	FaultReset    = "reset"
+	FaultThrottle = "throttle"
+	FaultPartial  = "partial"
+)
+
+// FaultHeader 注入故障时写入的响应头，值为命中的规则名称
+const FaultHeader = "X-Proxy-Fault"
+
+// ErrPartialResponse 按 partial_bytes 截断响应后返回的错误，使转发停止复制响应体
+var ErrPartialResponse = errors.New("response cut by fault injection")
+
+// faultThrottleSlices 限速时每秒写出的分片数，即令牌桶容量为带宽的 1/faultThrottleSlices
+const faultThrottleSlices = 10
+
+// faultKey 上下文中故障注入状态的键
+type faultKey struct{}
+
+// FaultInjector 故障注入器，按规则对携带故障注入请求头的请求注入故障
+type FaultInjector struct {
+	header string
+	rules  []*faultRule
+}
+
+// faultRule 编译后的故障规则
+type faultRule struct {
+	config.FaultRuleConfig
+	routes     map[string]bool
+	strategies map[string]bool
+	clientIDs  map[string]bool
+}
+
+// faultState 请求上下文中的故障注入状态
+type faultState struct {
+	injector *FaultInjector
+	route    config.RouteConfig
+	selected map[string]bool // 请求头选择的规则，nil 表示全部规则
+	injected bool            // 已经注入过故障，回退链的后续尝试不再注入
+}
+
+// NewFaultInjector 根据配置创建故障注入器，未启用时返回 nil
+func NewFaultInjector(cfg config.FaultInjectionConfig) *FaultInjector {
+	if !cfg.Enabled {
+		return nil
+	}
+	f := &FaultInjector{header: cfg.Header, rules: make([]*faultRule, 0, len(cfg.Rules))}
+	for _, rule := range cfg.Rules {
+		f.rules = append(f.rules, &faultRule{
+			FaultRuleConfig: rule,
+			routes:          utils.SliceToSet(rule.Routes),
+			strategies:      utils.SliceToSet(rule.Strategies),
+			clientIDs:       utils.SliceToSet(rule.ClientIDs),
+		})
+	}
+	return f
+}
+
+// WithFaultInjector 读取并移除故障注入请求头，携带请求头时将故障注入状态附加到请求上下文
+// injector 为 nil（未启用）时请求头原样转发
+func WithFaultInjector(r *http.Request, injector *FaultInjector, route config.RouteConfig) *http.Request {
+	if injector == nil {
+		return r
+	}
+	value := strings.TrimSpace(r.Header.Get(injector.header))
+	r.Header.Del(injector.header)
+	if value == "" {
+		return r
+	}
+
+	state := &faultState{injector: injector, route: route}
+	if !strings.EqualFold(value, config.FaultAllRules) {
+		state.selected = make(map[string]bool)
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				state.selected[name] = true
+			}
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), faultKey{}, state))
+}
+
+// InjectFault 按请求上下文中的故障注入状态，对以 strategy 转发的请求注入故障
+// 请求已被结束（abort、reset 或等待期间客户端取消）时返回 true，否则返回用于写出响应的 ResponseWriter
+func InjectFault(w http.ResponseWriter, r *http.Request, strategy string) (http.ResponseWriter, bool) {
+	state, _ := r.Context().Value(faultKey{}).(*faultState)
+	if state == nil || state.injected {
+		return w, false
+	}
+	rule := state.match(r, strategy)
+	if rule == nil {
+		return w, false
+	}
+	state.injected = true
+
+	logx.WithContext(r.Context()).Infof("Inject fault rule %s into request %s %s, strategy: %s, client: %s", rule.Name, r.Method, r.URL.Path, strategy, PeekClientID(r))
+	w.Header().Set(FaultHeader, rule.Name)
+
+	if rule.Delay > 0 {
+		metrics.InjectedFaults.Inc(rule.Name, FaultDelay)
+		if !sleepContext(r.Context(), rule.Delay) {
+			return w, true
+		}
+	}
+	if rule.Abort != 0 {
+		metrics.InjectedFaults.Inc(rule.Name, FaultAbort)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(rule.Abort)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Fault injected",
+			"rule":    rule.Name,
+		})
+		return w, true
+	}
+	if rule.Reset {
+		metrics.InjectedFaults.Inc(rule.Name, FaultReset)
+		resetConnection(r, w)
+		return w, true
+	}
+	if rule.Bandwidth == 0 && rule.PartialBytes == 0 {
+		return w, false
+	}
+
+	fw := &faultWriter{ResponseWriter: w, r: r, remaining: -1}
+	if rule.Bandwidth > 0 {
+		metrics.InjectedFaults.Inc(rule.Name, FaultThrottle)
+		fw.bucket = NewTokenBucket(rule.Bandwidth, max(rule.Bandwidth/faultThrottleSlices, 1))
+	}
+	if rule.PartialBytes > 0 {
+		metrics.InjectedFaults.Inc(rule.Name, FaultPartial)
+		fw.remaining = rule.PartialBytes
+	}
+	return fw, false
+}
+
+// match 返回第一条命中且被抽中的规则
+func (s *faultState) match(r *http.Request, strategy string) *faultRule {
+	for _, rule := range s.injector.rules {
+		if s.selected != nil && !s.selected[rule.Name] {
+			continue
+		}
+		if len(rule.routes) > 0 && !rule.routes[s.route.PathPrefix] && (s.route.Name == "" || !rule.routes[s.route.Name]) {
+			continue
+		}
+		if len(rule.strategies) > 0 && !rule.strategies[strategy] {
+			continue
+		}
+		if len(rule.clientIDs) > 0 && !rule.clientIDs[PeekClientID(r)] {
+			continue
+		}
+		if rand.Float64()*100 >= rule.Percentage {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// faultWriter 按带宽限速写出响应体，配置了 partial_bytes 时写出指定字节数后断开连接
+type faultWriter struct {
+	http.ResponseWriter
+	r         *http.Request
+	bucket    *TokenBucket // 为 nil 时不限速
+	remaining int64        // 还可以写出的字节数，-1 表示不截断
+	cut       bool
+}
+
+// Write 按限速写出响应体，达到截断字节数时断开连接并返回 ErrPartialResponse
+func (w *faultWriter) Write(data []byte) (int, error) {
+	if w.cut {
+		return 0, ErrPartialResponse
+	}
+	cut := w.remaining >= 0 && int64(len(data)) >= w.remaining
+	if cut {
+		data = data[:w.remaining]
+	}
+
+	written, err := w.throttledWrite(data)
+	if w.remaining >= 0 {
+		w.remaining -= int64(written)
+	}
+	if err != nil {
+		return written, err
+	}
+	if cut {
+		w.cut = true
+		w.Flush()
+		logx.WithContext(w.r.Context()).Infof("Cut response of %s %s after partial bytes", w.r.Method, w.r.URL.Path)
+		resetConnection(w.r, w.ResponseWriter)
+		return written, ErrPartialResponse
+	}
+	return written, nil
+}
+
+// throttledWrite 未限速时直接写出，否则按令牌桶容量分片写出，每个分片写出后等待令牌
+func (w *faultWriter) throttledWrite(data []byte) (int, error) {
+	if w.bucket == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	written := 0
+	for written < len(data) {
+		end := min(written+w.bucket.Burst(), len(data))
+		n, err := w.ResponseWriter.Write(data[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		w.Flush()
+		if _, err := w.bucket.WaitN(w.r.Context(), n); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Flush 透传 Flush，保证流式响应可用
+func (w *faultWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 使用
+func (w *faultWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// resetConnection 接管并立即关闭客户端连接，不支持接管（如 HTTP/2）时中止处理器
+func resetConnection(r *http.Request, w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		logx.WithContext(r.Context()).Errorf("Failed to hijack connection, abort handler instead: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
+
+// sleepContext 等待 d，ctx 先结束时返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+// 转发相关请求头
+const (
+	HeaderXForwardedFor   = "X-Forwarded-For"
+	HeaderXForwardedProto = "X-Forwarded-Proto"
+	HeaderXForwardedHost  = "X-Forwarded-Host"
+	HeaderForwarded       = "Forwarded"
+)
+
+// clientIPKey 上下文中已解析客户端 IP 的键
+type clientIPKey struct{}
+
+// ForwardedHeaders 转发头处理器
+type ForwardedHeaders struct {
+	mode         string
+	rfc7239      bool
+	preserveHost bool
+	trusted      []*net.IPNet
+}
+
+// NewForwardedHeaders 根据配置创建转发头处理器
+func NewForwardedHeaders(cfg config.ForwardedHeadersConfig) (*ForwardedHeaders, error) {
+	f := &ForwardedHeaders{
+		mode:         cfg.Mode,
+		rfc7239:      cfg.RFC7239,
+		preserveHost: cfg.PreserveHost,
+	}
+	if f.mode == "" {
+		f.mode = config.ForwardedModeAppend
+	}
+	for _, cidr := range cfg.TrustedProxies {
+		ipNet, err := config.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		f.trusted = append(f.trusted, ipNet)
+	}
+	return f, nil
+}
+
+// PreserveHost 是否向上游保留原始 Host
+func (f *ForwardedHeaders) PreserveHost() bool {
+	return f != nil && f.preserveHost
+}
+
+// Apply 按配置在转发请求头 dst 上设置转发头，取值来自原始请求 r
+func (f *ForwardedHeaders) Apply(dst http.Header, r *http.Request) {
+	dst.Del(HeaderXForwardedFor)
+	dst.Del(HeaderXForwardedProto)
+	dst.Del(HeaderXForwardedHost)
+	dst.Del(HeaderForwarded)
+	if f == nil || f.mode == config.ForwardedModeDrop {
+		return
+	}
+
+	peer := peerIP(r)
+	trusted := f.isTrusted(peer)
+	proto, host := requestProto(r), r.Host
+	if trusted {
+		if v := firstValue(r.Header.Get(HeaderXForwardedProto)); v != "" {
+			proto = v
+		}
+		if v := firstValue(r.Header.Get(HeaderXForwardedHost)); v != "" {
+			host = v
+		}
+	}
+
+	if f.mode == config.ForwardedModeReplace {
+		client := f.ClientIP(r)
+		dst.Set(HeaderXForwardedFor, client)
+		dst.Set(HeaderXForwardedProto, proto)
+		dst.Set(HeaderXForwardedHost, host)
+		if f.rfc7239 {
+			dst.Set(HeaderForwarded, forwardedElement(client, proto, host))
+		}
+		return
+	}
+
+	// append：仅在直连方可信时保留已有值
+	xff := peer
+	if prior := strings.Join(r.Header.Values(HeaderXForwardedFor), ", "); trusted && prior != "" {
+		xff = prior + ", " + peer
+	}
+	dst.Set(HeaderXForwardedFor, xff)
+	dst.Set(HeaderXForwardedProto, proto)
+	dst.Set(HeaderXForwardedHost, host)
+	if f.rfc7239 {
+		forwarded := forwardedElement(peer, proto, host)
+		if prior := strings.Join(r.Header.Values(HeaderForwarded), ", "); trusted && prior != "" {
+			forwarded = prior + ", " + forwarded
+		}
+		dst.Set(HeaderForwarded, forwarded)
+	}
+}
+
+// ClientIP 解析真实客户端 IP：从直连地址开始沿 X-Forwarded-For 向左跳过可信代理，
+// 第一个不可信的地址即为客户端
+func (f *ForwardedHeaders) ClientIP(r *http.Request) string {
+	peer := peerIP(r)
+	if f == nil || !f.isTrusted(peer) {
+		return peer
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values(HeaderXForwardedFor) {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		client = hops[i]
+		if !f.isTrusted(client) {
+			break
+		}
+	}
+	return client
+}
+
+func (f *ForwardedHeaders) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range f.trusted {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithClientIP 将解析出的客户端 IP 附加到请求上下文
+func WithClientIP(r *http.Request, ip string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+}
+
+// ClientIP 返回客户端 IP，优先使用入口处解析并附加到上下文的值，否则为直连地址
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	return peerIP(r)
+}
+
+// peerIP 返回直连方 IP
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func requestProto(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func firstValue(v string) string {
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
+
+// forwardedElement 构建 RFC 7239 Forwarded 头的单个元素
+func forwardedElement(forIP, proto, host string) string {
+	node := forIP
+	if strings.Contains(forIP, ":") {
+		node = fmt.Sprintf("\"[%s]\"", forIP)
+	}
+	element := "for=" + node + ";proto=" + proto
+	if host != "" {
+		element += fmt.Sprintf(";host=%q", host)
+	}
+	return element
+}
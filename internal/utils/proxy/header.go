@@ -100,3 +100,37 @@ func IsSensitiveHeader(key string) bool {
 	}
 	return false
 }
+
+// RedactedValue 调试输出与录制文件中隐藏的请求头取值
+const RedactedValue = "[redacted]"
+
+// credentialHeaders 调试输出与录制文件中隐藏取值的凭据类请求头
+var credentialHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+	"X-Csrf-Token",
+}
+
+// RedactHeaders 返回隐藏凭据类请求头及 names 取值后的副本
+func RedactHeaders(headers http.Header, names ...string) http.Header {
+	redacted := headers.Clone()
+	for key := range redacted {
+		if matchHeaderName(key, credentialHeaders) || matchHeaderName(key, names) {
+			redacted[key] = []string{RedactedValue}
+		}
+	}
+	return redacted
+}
+
+func matchHeaderName(key string, names []string) bool {
+	for _, name := range names {
+		if name != "" && strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
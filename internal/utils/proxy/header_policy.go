@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// RequestIDHeader 请求 ID 请求头
+const RequestIDHeader = "X-Request-Id"
+
+// passThroughAllowlist pass_through 关闭时仍然转发的请求头，请求 ID 始终透传
+var passThroughAllowlist = []string{
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Content-Type",
+	"Content-Encoding",
+	"User-Agent",
+	RequestIDHeader,
+}
+
+// headerPolicyKey 上下文中转发规则头策略的键
+type headerPolicyKey struct{}
+
+// HeaderPolicy 按顺序执行的一组头策略
+type HeaderPolicy struct {
+	userInfoHeader string
+	policies       []config.HeaderPolicyConfig
+}
+
+// NewHeaderPolicy 创建头策略，policies 按顺序执行，空策略会被忽略
+func NewHeaderPolicy(userInfoHeader string, policies ...config.HeaderPolicyConfig) *HeaderPolicy {
+	p := &HeaderPolicy{userInfoHeader: userInfoHeader}
+	for _, policy := range policies {
+		if !policy.IsEmpty() {
+			p.policies = append(p.policies, policy)
+		}
+	}
+	return p
+}
+
+// IsEmpty 判断是否没有需要执行的策略
+func (p *HeaderPolicy) IsEmpty() bool {
+	return p == nil || len(p.policies) == 0
+}
+
+// WithHeaderPolicy 将转发规则的头策略附加到请求上下文，在目标自身的头策略之后执行
+func WithHeaderPolicy(r *http.Request, policy *HeaderPolicy) *http.Request {
+	if policy.IsEmpty() {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), headerPolicyKey{}, policy))
+}
+
+// ApplyRequest 对转发请求头执行请求头操作，模板变量取自原始请求 r
+// 随后执行附加在 r 上下文中的头策略
+func (p *HeaderPolicy) ApplyRequest(dst http.Header, r *http.Request) {
+	for _, policy := range p.chain(r) {
+		policy.owner.apply(dst, policy.Request, r)
+	}
+}
+
+// ApplyResponse 对响应头执行响应头操作，需在写出状态码之前调用
+// 随后执行附加在 r 上下文中的头策略
+func (p *HeaderPolicy) ApplyResponse(dst http.Header, r *http.Request) {
+	for _, policy := range p.chain(r) {
+		policy.owner.apply(dst, policy.Response, r)
+	}
+}
+
+// ownedPolicy 带所属 HeaderPolicy 的策略，模板变量按所属配置计算
+type ownedPolicy struct {
+	config.HeaderPolicyConfig
+	owner *HeaderPolicy
+}
+
+// chain 返回自身与上下文中附加的全部策略
+func (p *HeaderPolicy) chain(r *http.Request) []ownedPolicy {
+	var chain []ownedPolicy
+	for _, policy := range []*HeaderPolicy{p, headerPolicyFromContext(r)} {
+		if policy.IsEmpty() {
+			continue
+		}
+		for _, cfg := range policy.policies {
+			chain = append(chain, ownedPolicy{HeaderPolicyConfig: cfg, owner: policy})
+		}
+	}
+	return chain
+}
+
+func headerPolicyFromContext(r *http.Request) *HeaderPolicy {
+	if r == nil {
+		return nil
+	}
+	policy, _ := r.Context().Value(headerPolicyKey{}).(*HeaderPolicy)
+	return policy
+}
+
+func (p *HeaderPolicy) apply(dst http.Header, ops config.HeaderOpsConfig, r *http.Request) {
+	for key := range dst {
+		if shouldExclude(key, ops.Remove) {
+			dst.Del(key)
+		}
+	}
+	for from, to := range ops.Rename {
+		if values := dst.Values(from); len(values) > 0 {
+			dst.Del(from)
+			dst[http.CanonicalHeaderKey(to)] = values
+		}
+	}
+
+	expand := func(tpl string) string {
+		return utils.ExpandPathTemplate(tpl, func(name string) string {
+			return p.templateValue(name, r)
+		})
+	}
+	for key, tpl := range ops.Set {
+		dst.Set(key, expand(tpl))
+	}
+	for key, tpl := range ops.Add {
+		dst.Add(key, expand(tpl))
+	}
+}
+
+// templateValue 计算模板变量的值
+func (p *HeaderPolicy) templateValue(name string, r *http.Request) string {
+	switch name {
+	case config.HeaderVarClientIP:
+		return ClientIP(r)
+	case config.HeaderVarUser:
+		if p.userInfoHeader == "" {
+			return ""
+		}
+		return utils.ParseJWTUserInfo(r, p.userInfoHeader)
+	case config.HeaderVarClientID:
+		return PeekClientID(r)
+	case config.HeaderVarRequestID:
+		return RequestID(r)
+	default:
+		return ""
+	}
+}
+
+// RestrictHeaders pass_through 关闭时仅保留内容协商相关的请求头
+func RestrictHeaders(headers http.Header) {
+	for key := range headers {
+		allowed := false
+		for _, name := range passThroughAllowlist {
+			if strings.EqualFold(key, name) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			headers.Del(key)
+		}
+	}
+}
+
+// StripSensitiveHeaders 移除敏感请求头，用于转发到隧道的请求
+func StripSensitiveHeaders(headers http.Header) {
+	for key := range headers {
+		if IsSensitiveHeader(key) {
+			headers.Del(key)
+		}
+	}
+}
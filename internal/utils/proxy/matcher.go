@@ -0,0 +1,239 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// RequestMatcher 请求匹配器，所有条件同时满足时视为匹配
+type RequestMatcher struct {
+	pathType      string
+	path          string
+	pathRegex     *regexp.Regexp
+	methods       map[string]struct{}
+	headers       []*valueMatcher
+	query         []*valueMatcher
+	versionRange  *utils.SemverRange
+	versionHeader string
+}
+
+// valueMatcher 请求头或查询参数的值匹配器
+type valueMatcher struct {
+	name  string
+	op    string
+	value string
+	regex *regexp.Regexp
+}
+
+// NewRequestMatcher 根据配置创建请求匹配器
+func NewRequestMatcher(cfg config.RuleMatchConfig) (*RequestMatcher, error) {
+	m := &RequestMatcher{
+		pathType:      cfg.PathType,
+		path:          cfg.Path,
+		versionHeader: cfg.VersionHeader,
+	}
+	if m.pathType == "" {
+		m.pathType = config.PathMatchExact
+	}
+
+	switch m.pathType {
+	case config.PathMatchRegex:
+		re, err := regexp.Compile(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path regex %q: %w", cfg.Path, err)
+		}
+		m.pathRegex = re
+	case config.PathMatchGlob:
+		re, err := GlobToRegexp(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		m.pathRegex = re
+	}
+
+	if len(cfg.Methods) > 0 {
+		m.methods = make(map[string]struct{}, len(cfg.Methods))
+		for _, method := range cfg.Methods {
+			m.methods[strings.ToUpper(method)] = struct{}{}
+		}
+	}
+
+	for _, h := range cfg.Headers {
+		vm, err := newValueMatcher(h)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", h.Name, err)
+		}
+		m.headers = append(m.headers, vm)
+	}
+	for _, q := range cfg.Query {
+		vm, err := newValueMatcher(q)
+		if err != nil {
+			return nil, fmt.Errorf("query %s: %w", q.Name, err)
+		}
+		m.query = append(m.query, vm)
+	}
+
+	if cfg.Version != "" {
+		r, err := utils.ParseSemverRange(cfg.Version)
+		if err != nil {
+			return nil, err
+		}
+		m.versionRange = r
+		if m.versionHeader == "" {
+			m.versionHeader = config.DefaultVersionHeader
+		}
+	}
+
+	return m, nil
+}
+
+// Match 判断请求是否满足所有条件
+func (m *RequestMatcher) Match(r *http.Request) bool {
+	if !m.MatchPath(r.URL.Path) {
+		return false
+	}
+
+	if m.methods != nil {
+		if _, ok := m.methods[r.Method]; !ok {
+			return false
+		}
+	}
+
+	for _, h := range m.headers {
+		values, present := r.Header[http.CanonicalHeaderKey(h.name)]
+		if !h.match(values, present) {
+			return false
+		}
+	}
+
+	if len(m.query) > 0 {
+		query := r.URL.Query()
+		for _, q := range m.query {
+			values, present := query[q.name]
+			if !q.match(values, present) {
+				return false
+			}
+		}
+	}
+
+	if m.versionRange != nil {
+		v, err := utils.ParseSemver(r.Header.Get(m.versionHeader))
+		if err != nil || !m.versionRange.Contains(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchPath 判断路径是否匹配，未配置路径时总是匹配
+func (m *RequestMatcher) MatchPath(p string) bool {
+	if m.path == "" {
+		return true
+	}
+	switch m.pathType {
+	case config.PathMatchPrefix:
+		return strings.HasPrefix(p, m.path)
+	case config.PathMatchGlob, config.PathMatchRegex:
+		return m.pathRegex.MatchString(p)
+	default:
+		return p == m.path
+	}
+}
+
+func newValueMatcher(cfg config.ValueMatchConfig) (*valueMatcher, error) {
+	vm := &valueMatcher{name: cfg.Name, op: cfg.Op, value: cfg.Value}
+	if vm.op == "" {
+		if vm.value == "" {
+			vm.op = config.ValueOpExists
+		} else {
+			vm.op = config.ValueOpEquals
+		}
+	}
+	if vm.op == config.ValueOpRegex {
+		re, err := regexp.Compile(cfg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", cfg.Value, err)
+		}
+		vm.regex = re
+	}
+	return vm, nil
+}
+
+// match 判断取值是否满足条件，多值时任意一个满足即可
+func (vm *valueMatcher) match(values []string, present bool) bool {
+	nonEmpty := present && len(values) > 0 && values[0] != ""
+	switch vm.op {
+	case config.ValueOpExists:
+		return nonEmpty
+	case config.ValueOpAbsent:
+		return !nonEmpty
+	case config.ValueOpNotEquals:
+		for _, v := range values {
+			if v == vm.value {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, v := range values {
+		switch vm.op {
+		case config.ValueOpEquals:
+			if v == vm.value {
+				return true
+			}
+		case config.ValueOpPrefix:
+			if strings.HasPrefix(v, vm.value) {
+				return true
+			}
+		case config.ValueOpSuffix:
+			if strings.HasSuffix(v, vm.value) {
+				return true
+			}
+		case config.ValueOpContains:
+			if strings.Contains(v, vm.value) {
+				return true
+			}
+		case config.ValueOpRegex:
+			if vm.regex.MatchString(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GlobToRegexp 将路径 glob 转换为正则：** 匹配任意多级路径，* 匹配单级路径中的任意字符，? 匹配单个字符
+func GlobToRegexp(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+	return re, nil
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+func TestRequestMatcherPath(t *testing.T) {
+	tests := []struct {
+		pathType string
+		pattern  string
+		path     string
+		want     bool
+	}{
+		{"", "/api/v1/search", "/api/v1/search", true},
+		{"", "/api/v1/search", "/api/v1/search/semantic", false},
+		{config.PathMatchPrefix, "/api/v1", "/api/v1/search", true},
+		{config.PathMatchPrefix, "/api/v1", "/api/v2/search", false},
+		{config.PathMatchGlob, "/api/*/search", "/api/v1/search", true},
+		{config.PathMatchGlob, "/api/*/search", "/api/v1/x/search", false},
+		{config.PathMatchGlob, "/api/**/search", "/api/v1/x/search", true},
+		{config.PathMatchGlob, "/api/v?/files", "/api/v2/files", true},
+		{config.PathMatchGlob, "/api/v1.0/*", "/api/v1x0/files", false},
+		{config.PathMatchRegex, `^/api/v[0-9]+/files$`, "/api/v12/files", true},
+		{config.PathMatchRegex, `^/api/v[0-9]+/files$`, "/api/vx/files", false},
+		{config.PathMatchExact, "", "/anything", true},
+	}
+
+	for _, tt := range tests {
+		m, err := NewRequestMatcher(config.RuleMatchConfig{Path: tt.pattern, PathType: tt.pathType})
+		require.NoError(t, err, tt.pattern)
+		assert.Equal(t, tt.want, m.MatchPath(tt.path), "%s %s %s", tt.pathType, tt.pattern, tt.path)
+	}
+}
+
+func TestRequestMatcherConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		match  config.RuleMatchConfig
+		method string
+		target string
+		header http.Header
+		want   bool
+	}{
+		{"method matches case-insensitively", config.RuleMatchConfig{Methods: []string{"post"}}, http.MethodPost, "/", nil, true},
+		{"method mismatch", config.RuleMatchConfig{Methods: []string{"POST"}}, http.MethodGet, "/", nil, false},
+		{"header exists", headerMatch("X-Env", "", ""), http.MethodGet, "/", http.Header{"X-Env": {"dev"}}, true},
+		{"header exists but empty", headerMatch("X-Env", "", ""), http.MethodGet, "/", http.Header{"X-Env": {""}}, false},
+		{"header absent", headerMatch("X-Env", config.ValueOpAbsent, ""), http.MethodGet, "/", nil, true},
+		{"header equals", headerMatch("X-Env", "", "dev"), http.MethodGet, "/", http.Header{"X-Env": {"dev"}}, true},
+		{"header equals any value", headerMatch("X-Env", "", "dev"), http.MethodGet, "/", http.Header{"X-Env": {"prod", "dev"}}, true},
+		{"header not equals", headerMatch("X-Env", config.ValueOpNotEquals, "dev"), http.MethodGet, "/", http.Header{"X-Env": {"dev"}}, false},
+		{"header prefix", headerMatch("X-Env", config.ValueOpPrefix, "de"), http.MethodGet, "/", http.Header{"X-Env": {"dev"}}, true},
+		{"header suffix", headerMatch("X-Env", config.ValueOpSuffix, "ev"), http.MethodGet, "/", http.Header{"X-Env": {"dev"}}, true},
+		{"header contains", headerMatch("X-Env", config.ValueOpContains, "e"), http.MethodGet, "/", http.Header{"X-Env": {"dev"}}, true},
+		{"header regex", headerMatch("x-env", config.ValueOpRegex, `^d.v$`), http.MethodGet, "/", http.Header{"X-Env": {"dev"}}, true},
+		{"header regex mismatch", headerMatch("X-Env", config.ValueOpRegex, `^d.v$`), http.MethodGet, "/", http.Header{"X-Env": {"prod"}}, false},
+		{"query equals", config.RuleMatchConfig{Query: []config.ValueMatchConfig{{Name: "mode", Value: "fast"}}}, http.MethodGet, "/?mode=fast", nil, true},
+		{"query missing", config.RuleMatchConfig{Query: []config.ValueMatchConfig{{Name: "mode"}}}, http.MethodGet, "/", nil, false},
+		{"version in range", config.RuleMatchConfig{Version: ">=2.3.0"}, http.MethodGet, "/", http.Header{"X-Costrict-Version": {"2.4.0"}}, true},
+		{"version below range", config.RuleMatchConfig{Version: ">=2.3.0"}, http.MethodGet, "/", http.Header{"X-Costrict-Version": {"2.2.0"}}, false},
+		{"version missing", config.RuleMatchConfig{Version: ">=2.3.0"}, http.MethodGet, "/", nil, false},
+		{"custom version header", config.RuleMatchConfig{Version: "^1.0.0", VersionHeader: "X-Plugin-Version"}, http.MethodGet, "/", http.Header{"X-Plugin-Version": {"1.5.0"}}, true},
+		{
+			"all conditions must match",
+			config.RuleMatchConfig{Path: "/api", PathType: config.PathMatchPrefix, Methods: []string{"GET"}, Query: []config.ValueMatchConfig{{Name: "mode", Value: "fast"}}},
+			http.MethodGet, "/api/search?mode=slow", nil, false,
+		},
+	}
+
+	for _, tt := range tests {
+		m, err := NewRequestMatcher(tt.match)
+		require.NoError(t, err, tt.name)
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		for key, values := range tt.header {
+			r.Header[key] = values
+		}
+		assert.Equal(t, tt.want, m.Match(r), tt.name)
+	}
+}
+
+func TestNewRequestMatcherErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		match config.RuleMatchConfig
+	}{
+		{"invalid path regex", config.RuleMatchConfig{Path: "/api/(", PathType: config.PathMatchRegex}},
+		{"invalid header regex", headerMatch("X-Env", config.ValueOpRegex, "[a-")},
+		{"invalid query regex", config.RuleMatchConfig{Query: []config.ValueMatchConfig{{Name: "q", Op: config.ValueOpRegex, Value: "*"}}}},
+		{"invalid version range", config.RuleMatchConfig{Version: ">=two"}},
+	}
+
+	for _, tt := range tests {
+		_, err := NewRequestMatcher(tt.match)
+		assert.Error(t, err, tt.name)
+	}
+}
+
+func headerMatch(name, op, value string) config.RuleMatchConfig {
+	return config.RuleMatchConfig{Headers: []config.ValueMatchConfig{{Name: name, Op: op, Value: value}}}
+}
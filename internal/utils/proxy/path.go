@@ -16,8 +16,10 @@ func RewritePath(path string, rules []RewriteRule) string {
 
 // RewriteRule 路径重写规则
 type RewriteRule struct {
-	From string
-	To   string
+	Type     string // 规则类型，为空时按前缀处理
+	From     string
+	To       string
+	SetQuery map[string]string
 }
 
 // CleanPath 清理路径，移除多余斜杠
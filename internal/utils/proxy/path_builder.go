@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -12,7 +13,7 @@ import (
 
 // PathBuilder 路径构建器接口
 type PathBuilder interface {
-	BuildPath(originalPath string) (string, error)
+	BuildPath(ctx context.Context, originalPath string) (string, error)
 }
 
 // RewritePathBuilder 路径重写构建器
@@ -28,7 +29,7 @@ func NewRewritePathBuilder(rules []RewriteRule) *RewritePathBuilder {
 }
 
 // BuildPath 根据重写规则构建路径
-func (b *RewritePathBuilder) BuildPath(originalPath string) (string, error) {
+func (b *RewritePathBuilder) BuildPath(ctx context.Context, originalPath string) (string, error) {
 	if len(b.rules) == 0 {
 		return originalPath, nil
 	}
@@ -36,7 +37,7 @@ func (b *RewritePathBuilder) BuildPath(originalPath string) (string, error) {
 	for _, rule := range b.rules {
 		if strings.HasPrefix(originalPath, rule.From) {
 			newPath := strings.Replace(originalPath, rule.From, rule.To, 1)
-			logx.Infof("Path rewritten: %s -> %s", originalPath, newPath)
+			logx.WithContext(ctx).Infof("Path rewritten: %s -> %s", originalPath, newPath)
 			return newPath, nil
 		}
 	}
@@ -57,7 +58,7 @@ func NewFullPathBuilder(targetURL string) *FullPathBuilder {
 }
 
 // BuildPath 保持原始路径不变
-func (b *FullPathBuilder) BuildPath(originalPath string) (string, error) {
+func (b *FullPathBuilder) BuildPath(ctx context.Context, originalPath string) (string, error) {
 	if originalPath == "" {
 		originalPath = "/"
 	}
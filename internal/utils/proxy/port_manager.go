@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
 )
 
 // PortResponse 接口响应结构
@@ -103,7 +105,7 @@ func (pm *PortManager) GetPort(ctx context.Context, clientID, appName string, he
 	if cached, exists := pm.cache[cacheKey]; exists {
 		if time.Since(pm.lastUpdate[cacheKey]) < pm.cacheExp {
 			pm.mu.RUnlock()
-			logx.Infof("Using cached port for client %s, app %s: %d", clientID, appName, cached.Port)
+			logx.WithContext(ctx).Infof("Using cached port for client %s, app %s: %d", clientID, appName, cached.Port)
 			return &cached, nil
 		}
 	}
@@ -116,7 +118,7 @@ func (pm *PortManager) GetPort(ctx context.Context, clientID, appName string, he
 	// 创建请求
 	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, errs.NewProxyErrorf(errs.CodeInternal, "failed to create port request: %v", err)
 	}
 
 	// 复制原始请求头到端口管理器请求中
@@ -131,18 +133,24 @@ func (pm *PortManager) GetPort(ctx context.Context, clientID, appName string, he
 			}
 		}
 	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
 
 	// 发送请求
 	resp, err := pm.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch port: %w", err)
+		return nil, errs.NewProxyErrorf(errs.CodeTunnelManagerUnavailable, "failed to fetch port: %v", err)
 	}
 	defer resp.Body.Close()
 
 	// 解析响应
 	var portResp PortResponse
 	if err := json.NewDecoder(resp.Body).Decode(&portResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		if resp.StatusCode != http.StatusOK {
+			return nil, portStatusError(clientID, resp.StatusCode)
+		}
+		return nil, errs.NewProxyErrorf(errs.CodeTunnelManagerUnavailable, "failed to decode port response: %v", err)
 	}
 
 	// 打印详细的请求和响应信息
@@ -150,12 +158,12 @@ func (pm *PortManager) GetPort(ctx context.Context, clientID, appName string, he
 	for key, values := range req.Header {
 		headersStr += fmt.Sprintf("%s: %s; ", key, strings.Join(values, ","))
 	}
-	logx.Infof("Port request completed - URL: %s, Headers: [%s], StatusCode: %d, Response: %+v", 
+	logx.WithContext(ctx).Infof("Port request completed - URL: %s, Headers: [%s], StatusCode: %d, Response: %+v", 
 		requestURL, headersStr, resp.StatusCode, portResp)
 
 	// 检查响应状态
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, portStatusError(clientID, resp.StatusCode)
 	}
 
 	// 更新缓存
@@ -164,48 +172,53 @@ func (pm *PortManager) GetPort(ctx context.Context, clientID, appName string, he
 	pm.lastUpdate[cacheKey] = time.Now()
 	pm.mu.Unlock()
 
-	logx.Infof("Successfully fetched port for client %s, app %s: %d", clientID, appName, portResp.Port)
+	logx.WithContext(ctx).Infof("Successfully fetched port for client %s, app %s: %d", clientID, appName, portResp.Port)
 	return &portResp, nil
 }
 
-// GetPortFromHeaders 从请求获取端口信息
-// 对于 GET 请求，从 params 中获取 clientId
-// 对于其他请求，从 body 中获取 clientId
-func (pm *PortManager) GetPortFromHeaders(ctx context.Context, method string, headers http.Header, params map[string][]string, body []byte) (*PortResponse, error) {
-	var clientID string
-
-	// 根据请求方法从不同位置获取 clientId
-	if method == "GET" {
-		// 从 GET 请求的 params 中获取 clientId
-		if clientIds, exists := params["clientId"]; exists && len(clientIds) > 0 {
-			clientID = clientIds[0]
-		}
-	} else {
-		// 从其他请求的 body 中获取 clientId
-		if len(body) > 0 {
-			// 尝试解析 JSON body
-			var requestBody map[string]interface{}
-			if err := json.Unmarshal(body, &requestBody); err == nil {
-				if id, exists := requestBody["clientId"]; exists {
-					if idStr, ok := id.(string); ok {
-						clientID = idStr
-					}
-				}
-			}
-		}
+// portStatusError 将端口管理器的非 200 响应归类为代理错误，404 表示该客户端没有隧道
+func portStatusError(clientID string, status int) error {
+	if status == http.StatusNotFound {
+		return errs.NewProxyErrorf(errs.CodeTunnelNotFound, "no tunnel for client %s", clientID)
 	}
+	return errs.NewProxyErrorf(errs.CodeTunnelManagerUnavailable, "unexpected status code: %d", status)
+}
 
+// GetPortForRequest 从请求获取端口信息
+// clientId 按请求上下文中的提取规则查找，默认依次查找 JSON 请求体、查询参数和请求头
+func (pm *PortManager) GetPortForRequest(ctx context.Context, r *http.Request) (*PortResponse, error) {
+	clientID := PeekClientID(r)
 	if clientID == "" {
-		// 如果从 params 或 body 中获取不到，尝试从 headers 中获取（向后兼容）
-		clientID = headers.Get("clientId")
-		if clientID == "" {
-			return nil, fmt.Errorf("clientId is required in params (for GET) or body (for other methods) or headers")
-		}
+		return nil, errs.NewProxyError(errs.CodeClientIDMissing, errClientIDMissing(r))
 	}
 
 	appName := "codebase-indexer"
 
-	return pm.GetPort(ctx, clientID, appName, headers)
+	return pm.GetPort(ctx, clientID, appName, r.Header)
+}
+
+// Ping 检查端口管理器是否可达，响应状态码小于 500 视为可达
+func (pm *PortManager) Ping(ctx context.Context, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pm.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := pm.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close 关闭与端口管理器之间的空闲连接
+func (pm *PortManager) Close() error {
+	pm.httpClient.CloseIdleConnections()
+	return nil
 }
 
 // BuildTargetURL 构建目标URL
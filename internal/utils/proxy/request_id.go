@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// maxRequestIDLength 接受的外部请求 ID 最大长度
+const maxRequestIDLength = 128
+
+// requestIDKey 上下文中请求 ID 的键
+type requestIDKey struct{}
+
+// NewRequestID 生成新的请求 ID
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// ResolveRequestID 返回请求携带的合法请求 ID，不存在或不合法时生成新的
+func ResolveRequestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	return NewRequestID()
+}
+
+// WithRequestID 将请求 ID 附加到上下文，之后通过 logx.WithContext 输出的日志都会带上 requestId 字段
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return logx.ContextWithFields(ctx, logx.Field("requestId", id))
+}
+
+// RequestIDFromContext 返回上下文中的请求 ID
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID 返回请求的请求 ID，优先使用上下文中的值
+func RequestID(r *http.Request) string {
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		return id
+	}
+	return r.Header.Get(RequestIDHeader)
+}
+
+// validRequestID 仅接受长度受限的可打印字符，避免日志与响应头注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c <= ' ' || c > '~' || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
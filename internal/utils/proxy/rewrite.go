@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/pkg/utils"
+)
+
+// Rewriter 路径重写器，按顺序应用第一条匹配的重写规则
+type Rewriter struct {
+	stripPrefixes []string
+	rules         []*compiledRewriteRule
+}
+
+// compiledRewriteRule 编译后的重写规则
+type compiledRewriteRule struct {
+	RewriteRule
+	re *regexp.Regexp // prefix 规则为空
+}
+
+// NewRewriter 创建路径重写器
+func NewRewriter(stripPrefixes []string, rules []RewriteRule) (*Rewriter, error) {
+	rewriter := &Rewriter{stripPrefixes: stripPrefixes}
+	for i, rule := range rules {
+		compiled := &compiledRewriteRule{RewriteRule: rule}
+		var err error
+		switch rule.Type {
+		case "", config.RewriteTypePrefix:
+		case config.RewriteTypeRegex:
+			compiled.re, err = regexp.Compile(rule.From)
+		case config.RewriteTypePattern:
+			compiled.re, err = utils.CompilePathPattern(rule.From)
+		default:
+			err = fmt.Errorf("invalid type: %s", rule.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rewrite rule %d: %w", i, err)
+		}
+		rewriter.rules = append(rewriter.rules, compiled)
+	}
+	return rewriter, nil
+}
+
+// StripPrefix 移除第一个匹配的代理前缀
+func (r *Rewriter) StripPrefix(path string) string {
+	for _, prefix := range r.stripPrefixes {
+		if path == prefix || strings.HasPrefix(path, strings.TrimRight(prefix, "/")+"/") {
+			return strings.TrimPrefix(path, strings.TrimRight(prefix, "/"))
+		}
+	}
+	return path
+}
+
+// Rewrite 应用第一条匹配的规则，返回重写后的路径与查询参数
+// 查询参数未被规则修改时原样返回，保留原始编码与顺序
+func (r *Rewriter) Rewrite(path, rawQuery string) (string, string) {
+	for _, rule := range r.rules {
+		start, end, lookup, ok := rule.match(path)
+		if !ok {
+			continue
+		}
+
+		query, _ := url.ParseQuery(rawQuery)
+		queryChanged := false
+		// 被模板引用的查询参数视为移动到了路径或其他参数中，转发时移除
+		consumed := make(map[string]struct{})
+		expand := func(tpl string) string {
+			return utils.ExpandPathTemplate(tpl, func(name string) string {
+				if key, isQuery := strings.CutPrefix(name, config.RewriteQueryParamPrefix); isQuery {
+					consumed[key] = struct{}{}
+					return query.Get(key)
+				}
+				return lookup(name)
+			})
+		}
+
+		// 仅替换匹配到的部分，前缀规则保留剩余路径
+		newPath := path[:start] + expand(rule.To) + path[end:]
+
+		setQuery := make(map[string]string, len(rule.SetQuery))
+		for key, tpl := range rule.SetQuery {
+			setQuery[key] = expand(tpl)
+		}
+		for key := range consumed {
+			query.Del(key)
+			queryChanged = true
+		}
+		for key, value := range setQuery {
+			query.Set(key, value)
+			queryChanged = true
+		}
+
+		if queryChanged {
+			rawQuery = query.Encode()
+		}
+		return newPath, rawQuery
+	}
+	return path, rawQuery
+}
+
+// match 匹配路径，返回匹配区间与捕获组查询函数
+func (c *compiledRewriteRule) match(path string) (int, int, func(name string) string, bool) {
+	if c.re == nil {
+		if !strings.HasPrefix(path, c.From) {
+			return 0, 0, nil, false
+		}
+		return 0, len(c.From), func(string) string { return "" }, true
+	}
+
+	loc := c.re.FindStringSubmatchIndex(path)
+	if loc == nil {
+		return 0, 0, nil, false
+	}
+	return loc[0], loc[1], func(name string) string {
+		idx := c.re.SubexpIndex(name)
+		if idx < 0 {
+			var err error
+			if idx, err = strconv.Atoi(name); err != nil || idx > c.re.NumSubexp() {
+				return ""
+			}
+		}
+		if loc[2*idx] < 0 {
+			return ""
+		}
+		return path[loc[2*idx]:loc[2*idx+1]]
+	}, true
+}
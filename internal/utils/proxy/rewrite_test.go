@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+func TestRewriterStripPrefixDefaults(t *testing.T) {
+	// 未配置 strip_prefixes 时仍移除此前固定去除的代理前缀
+	cfg := &config.ProxyConfig{
+		Routes: []config.RouteConfig{{PathPrefix: "/", Target: config.TargetConfig{URL: "http://localhost:8080"}}},
+	}
+	require.NoError(t, cfg.Validate())
+	rewriter, err := NewRewriter(cfg.Rewrite.StripPrefixes, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/proxy/codebase-indexer/api/v1/files", "/codebase-indexer/api/v1/files"},
+		{"/proxy/codebase-indexer/api/v1/files", "/codebase-indexer/api/v1/files"},
+		{"/proxy", ""},
+		{"/proxyfoo/files", "/proxyfoo/files"},
+		{"/codebase-indexer/api/v1/files", "/codebase-indexer/api/v1/files"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, rewriter.StripPrefix(tt.path), tt.path)
+	}
+}
+
+func TestRewriterStripPrefixConfigured(t *testing.T) {
+	tests := []struct {
+		prefixes []string
+		path     string
+		want     string
+	}{
+		{[]string{"/gateway"}, "/gateway/files", "/files"},
+		{[]string{"/gateway"}, "/proxy/files", "/proxy/files"},
+		{[]string{"/"}, "/proxy/files", "/proxy/files"},
+	}
+
+	for _, tt := range tests {
+		rewriter, err := NewRewriter(tt.prefixes, nil)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, rewriter.StripPrefix(tt.path), "%v %s", tt.prefixes, tt.path)
+	}
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+// ForwardRule 编译后的转发规则
+type ForwardRule struct {
+	Name    string
+	Target  config.ForwardTargetConfig
+	Headers *HeaderPolicy // 命中规则时附加的头策略
+	matcher *RequestMatcher
+}
+
+// RuleEngine 转发规则引擎，按顺序匹配规则，全部未命中时返回默认目标
+type RuleEngine struct {
+	rules       []*ForwardRule
+	defaultRule *ForwardRule
+}
+
+// NewRuleEngine 根据代理配置创建规则引擎
+// 启用 forward_rules 时先加载其规则；启用 header_based_forward 时追加等价的兼容规则；
+// 仅当 forward_rules 启用时才有默认目标
+func NewRuleEngine(cfg *config.ProxyConfig) (*RuleEngine, error) {
+	engine := &RuleEngine{}
+
+	if cfg.ForwardRules.Enabled {
+		for i, rule := range cfg.ForwardRules.Rules {
+			name := rule.Name
+			if name == "" {
+				name = fmt.Sprintf("rule-%d", i)
+			}
+			if err := engine.add(name, rule.Match, rule.Target, NewHeaderPolicy(cfg.UserInfoHeader, rule.Headers)); err != nil {
+				return nil, fmt.Errorf("forward_rules.rules[%d]: %w", i, err)
+			}
+		}
+	}
+
+	if cfg.HeaderBasedForward.Enabled {
+		for i, pathConfig := range cfg.HeaderBasedForward.Paths {
+			headers := NewHeaderPolicy(cfg.UserInfoHeader, pathConfig.Headers)
+			withHeader := config.RuleMatchConfig{
+				Path:    pathConfig.Path,
+				Headers: []config.ValueMatchConfig{{Name: cfg.HeaderBasedForward.HeaderName, Op: config.ValueOpExists}},
+			}
+			if err := engine.add(fmt.Sprintf("header-based-%d-with-header", i), withHeader,
+				config.ForwardTargetConfig{Type: config.TargetTypeURL, URL: pathConfig.WithHeaderURL}, headers); err != nil {
+				return nil, err
+			}
+			if err := engine.add(fmt.Sprintf("header-based-%d-without-header", i), config.RuleMatchConfig{Path: pathConfig.Path},
+				config.ForwardTargetConfig{Type: config.TargetTypeURL, URL: pathConfig.WithoutHeaderURL}, headers); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if cfg.ForwardRules.Enabled {
+		engine.defaultRule = &ForwardRule{Name: "default", Target: cfg.ForwardRules.Default}
+	}
+
+	return engine, nil
+}
+
+func (e *RuleEngine) add(name string, match config.RuleMatchConfig, target config.ForwardTargetConfig, headers *HeaderPolicy) error {
+	matcher, err := NewRequestMatcher(match)
+	if err != nil {
+		return err
+	}
+	e.rules = append(e.rules, &ForwardRule{Name: name, Target: target, Headers: headers, matcher: matcher})
+	return nil
+}
+
+// Match 返回第一条命中的规则；未命中且配置了默认目标时返回默认规则
+func (e *RuleEngine) Match(r *http.Request) (*ForwardRule, bool) {
+	for _, rule := range e.rules {
+		if rule.matcher.Match(r) {
+			return rule, true
+		}
+	}
+	if e.defaultRule != nil {
+		return e.defaultRule, true
+	}
+	return nil, false
+}
+
+// Rules 返回按顺序排列的规则
+func (e *RuleEngine) Rules() []*ForwardRule {
+	return e.rules
+}
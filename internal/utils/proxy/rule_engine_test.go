@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+func TestRuleEngineMatchPrecedence(t *testing.T) {
+	cfg := &config.ProxyConfig{
+		ForwardRules: config.ForwardRulesConfig{
+			Enabled: true,
+			Rules: []config.ForwardRuleConfig{
+				{
+					Name:   "new-plugin",
+					Match:  config.RuleMatchConfig{Path: "/api/**", PathType: config.PathMatchGlob, Version: ">=2.0.0"},
+					Target: config.ForwardTargetConfig{Type: config.TargetTypeDynamic},
+				},
+				{
+					Match:  config.RuleMatchConfig{Path: "/api", PathType: config.PathMatchPrefix},
+					Target: config.ForwardTargetConfig{Type: config.TargetTypeURL, URL: "http://shared"},
+				},
+			},
+			Default: config.ForwardTargetConfig{Type: config.TargetTypeStatic},
+		},
+		HeaderBasedForward: config.HeaderBasedForwardConfig{
+			Enabled:    true,
+			HeaderName: "X-Tenant",
+			Paths: []config.HeaderBasedForwardPathConfig{
+				{Path: "/legacy", WithHeaderURL: "http://tenant", WithoutHeaderURL: "http://public"},
+			},
+		},
+	}
+	engine, err := NewRuleEngine(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		rule   string
+	}{
+		{"first matching rule wins", "/api/search", http.Header{"X-Costrict-Version": {"2.1.0"}}, "new-plugin"},
+		{"falls through to next rule", "/api/search", http.Header{"X-Costrict-Version": {"1.9.0"}}, "rule-1"},
+		{"header based rule with header", "/legacy", http.Header{"X-Tenant": {"a"}}, "header-based-0-with-header"},
+		{"header based rule without header", "/legacy", nil, "header-based-0-without-header"},
+		{"default target", "/other", nil, "default"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		for key, values := range tt.header {
+			r.Header[key] = values
+		}
+		rule, ok := engine.Match(r)
+		require.True(t, ok, tt.name)
+		assert.Equal(t, tt.rule, rule.Name, tt.name)
+	}
+}
+
+func TestRuleEngineWithoutDefault(t *testing.T) {
+	engine, err := NewRuleEngine(&config.ProxyConfig{
+		HeaderBasedForward: config.HeaderBasedForwardConfig{
+			Enabled:    true,
+			HeaderName: "X-Tenant",
+			Paths:      []config.HeaderBasedForwardPathConfig{{Path: "/legacy", WithHeaderURL: "http://tenant", WithoutHeaderURL: "http://public"}},
+		},
+	})
+	require.NoError(t, err)
+
+	_, ok := engine.Match(httptest.NewRequest(http.MethodGet, "/other", nil))
+
+	assert.False(t, ok)
+}
+
+func TestNewRuleEngineInvalidRule(t *testing.T) {
+	_, err := NewRuleEngine(&config.ProxyConfig{
+		ForwardRules: config.ForwardRulesConfig{
+			Enabled: true,
+			Rules: []config.ForwardRuleConfig{
+				{Match: config.RuleMatchConfig{Path: "/api/(", PathType: config.PathMatchRegex}},
+			},
+			Default: config.ForwardTargetConfig{Type: config.TargetTypeDynamic},
+		},
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "forward_rules.rules[0]")
+}
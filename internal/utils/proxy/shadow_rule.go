@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+// ShadowRule 编译后的影子规则
+type ShadowRule struct {
+	Name       string
+	URL        string
+	SampleRate float64
+	matcher    *RequestMatcher
+}
+
+// NewShadowRules 根据配置创建影子规则列表
+func NewShadowRules(cfg config.ShadowConfig) ([]*ShadowRule, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	rules := make([]*ShadowRule, 0, len(cfg.Rules))
+	for _, ruleConfig := range cfg.Rules {
+		matcher, err := NewRequestMatcher(ruleConfig.Match)
+		if err != nil {
+			return nil, fmt.Errorf("shadow rule %s: %w", ruleConfig.Name, err)
+		}
+		rules = append(rules, &ShadowRule{
+			Name:       ruleConfig.Name,
+			URL:        ruleConfig.URL,
+			SampleRate: ruleConfig.SampleRate,
+			matcher:    matcher,
+		})
+	}
+	return rules, nil
+}
+
+// MatchShadowRule 返回第一条匹配请求的影子规则
+func MatchShadowRule(rules []*ShadowRule, r *http.Request) *ShadowRule {
+	for _, rule := range rules {
+		if rule.matcher.Match(r) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Sampled 按采样比例判断本次请求是否需要发送影子请求
+func (s *ShadowRule) Sampled() bool {
+	return s.SampleRate > 0 && rand.Float64() < s.SampleRate
+}
@@ -0,0 +1,276 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/errs"
+)
+
+// 超时阶段
+const (
+	PhaseConnect        = "connect"
+	PhaseTLS            = "tls"
+	PhaseResponseHeader = "response_header"
+	PhaseTotal          = "total"
+)
+
+// timeoutBudgetKey 上下文中路由超时预算的键
+type timeoutBudgetKey struct{}
+
+// timeoutsKey 上下文中本次请求各阶段超时的键
+type timeoutsKey struct{}
+
+// TimeoutError 某个阶段超时
+type TimeoutError struct {
+	Phase string
+	After time.Duration
+}
+
+// Error 实现error接口
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout after %s", e.Phase, e.After)
+}
+
+// Timeout 实现 net.Error
+func (e *TimeoutError) Timeout() bool { return true }
+
+// Temporary 实现 net.Error
+func (e *TimeoutError) Temporary() bool { return true }
+
+// TimeoutBudget 按路由与请求方法逐层覆盖的超时预算
+type TimeoutBudget struct {
+	global        config.TimeoutsConfig
+	targetTimeout time.Duration
+	route         config.TimeoutsConfig
+}
+
+// NewTimeoutBudget 创建超时预算，targetTimeout 为路由 target.timeout，作为总超时
+func NewTimeoutBudget(global config.TimeoutsConfig, targetTimeout time.Duration, route config.TimeoutsConfig) *TimeoutBudget {
+	return &TimeoutBudget{global: global, targetTimeout: targetTimeout, route: route}
+}
+
+// WithTimeoutBudget 将路由超时预算附加到请求上下文，优先于处理器自身的预算
+func WithTimeoutBudget(r *http.Request, budget *TimeoutBudget) *http.Request {
+	if budget == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), timeoutBudgetKey{}, budget))
+}
+
+// Resolve 计算请求各阶段的超时，客户端通过请求头只能缩短总超时
+func (b *TimeoutBudget) Resolve(r *http.Request) config.TimeoutPhasesConfig {
+	phases := b.global.Default
+	phases = mergePhases(phases, config.TimeoutPhasesConfig{Total: b.targetTimeout})
+	phases = mergePhases(phases, b.global.Methods[r.Method])
+	phases = mergePhases(phases, b.route.Default)
+	phases = mergePhases(phases, b.route.Methods[r.Method])
+
+	if b.global.ClientHeader == "" {
+		return phases
+	}
+	if hint, ok := parseClientTimeout(r.Header.Get(b.global.ClientHeader)); ok {
+		if b.global.MaxClient > 0 && hint > b.global.MaxClient {
+			hint = b.global.MaxClient
+		}
+		if phases.Total == 0 || hint < phases.Total {
+			phases.Total = hint
+		}
+	}
+	return phases
+}
+
+// Start 解析本次请求的超时并返回带总超时的上下文，请求上下文中附加的路由预算优先
+func (b *TimeoutBudget) Start(r *http.Request) (context.Context, context.CancelFunc) {
+	if budget, ok := r.Context().Value(timeoutBudgetKey{}).(*TimeoutBudget); ok {
+		b = budget
+	}
+	var phases config.TimeoutPhasesConfig
+	if b != nil {
+		phases = b.Resolve(r)
+	}
+
+	ctx := context.WithValue(r.Context(), timeoutsKey{}, phases)
+	if phases.Total > 0 {
+		return context.WithTimeoutCause(ctx, phases.Total, &TimeoutError{Phase: PhaseTotal, After: phases.Total})
+	}
+	return context.WithCancel(ctx)
+}
+
+// NewTimeoutTransport 创建按请求上下文中的超时执行连接、TLS 握手与等待响应头的 Transport
+// base.DialContext 为空时使用默认的 net.Dialer
+func NewTimeoutTransport(base *http.Transport) http.RoundTripper {
+	transport := base.Clone()
+	dial := base.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{KeepAlive: 30 * time.Second}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialWithTimeout(ctx, dial, network, addr)
+	}
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialWithTimeout(ctx, dial, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return handshakeWithTimeout(ctx, conn, addr, base.TLSClientConfig)
+	}
+	return &timeoutTransport{base: transport}
+}
+
+// timeoutTransport 在请求发送完毕后开始计算响应头超时
+type timeoutTransport struct {
+	base *http.Transport
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	phases := timeoutsFromContext(req.Context())
+	if phases.ResponseHeader <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	timeoutErr := &TimeoutError{Phase: PhaseResponseHeader, After: phases.ResponseHeader}
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(phases.ResponseHeader, func() { cancel(timeoutErr) })
+	timer.Stop()
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest:         func(httptrace.WroteRequestInfo) { timer.Reset(phases.ResponseHeader) },
+		GotFirstResponseByte: func() { timer.Stop() },
+	})
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	timer.Stop()
+	if err != nil {
+		cancel(nil)
+		if errors.Is(context.Cause(ctx), timeoutErr) {
+			return nil, timeoutErr
+		}
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: func() { cancel(nil) }}
+	return resp, nil
+}
+
+// cancelOnClose 响应体关闭时释放响应头超时的上下文
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+// Close 关闭响应体
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// CloseIdleConnections 关闭空闲连接
+func (t *timeoutTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// UpstreamError 将转发上游的错误归类为代理错误，超时错误在详情中注明超时的阶段
+func UpstreamError(ctx context.Context, err error) *errs.ProxyError {
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		errors.As(context.Cause(ctx), &timeoutErr)
+	}
+	if timeoutErr != nil {
+		return errs.NewProxyError(errs.CodeTimeout, timeoutErr.Error())
+	}
+	return errs.FromUpstream(err)
+}
+
+func timeoutsFromContext(ctx context.Context) config.TimeoutPhasesConfig {
+	phases, _ := ctx.Value(timeoutsKey{}).(config.TimeoutPhasesConfig)
+	return phases
+}
+
+func dialWithTimeout(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error), network, addr string) (net.Conn, error) {
+	phases := timeoutsFromContext(ctx)
+	if phases.Connect <= 0 {
+		return dial(ctx, network, addr)
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, phases.Connect)
+	defer cancel()
+	conn, err := dial(dialCtx, network, addr)
+	if err != nil && errors.Is(dialCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, &TimeoutError{Phase: PhaseConnect, After: phases.Connect}
+	}
+	return conn, err
+}
+
+func handshakeWithTimeout(ctx context.Context, conn net.Conn, addr string, base *tls.Config) (net.Conn, error) {
+	phases := timeoutsFromContext(ctx)
+	cfg := base.Clone()
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		cfg.ServerName = host
+	}
+
+	handshakeCtx := ctx
+	if phases.TLS > 0 {
+		var cancel context.CancelFunc
+		handshakeCtx, cancel = context.WithTimeout(ctx, phases.TLS)
+		defer cancel()
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+		conn.Close()
+		if phases.TLS > 0 && errors.Is(handshakeCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, &TimeoutError{Phase: PhaseTLS, After: phases.TLS}
+		}
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// parseClientTimeout 解析客户端请求的超时，支持 Go 时长格式（如 5s）或毫秒数
+func parseClientTimeout(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if ms <= 0 {
+			return 0, false
+		}
+		return time.Duration(ms) * time.Millisecond, true
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+func mergePhases(base, override config.TimeoutPhasesConfig) config.TimeoutPhasesConfig {
+	if override.Connect > 0 {
+		base.Connect = override.Connect
+	}
+	if override.TLS > 0 {
+		base.TLS = override.TLS
+	}
+	if override.ResponseHeader > 0 {
+		base.ResponseHeader = override.ResponseHeader
+	}
+	if override.Total > 0 {
+		base.Total = override.Total
+	}
+	return base
+}
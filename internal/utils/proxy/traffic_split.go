@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+)
+
+// TrafficSplit 编译后的分流规则
+type TrafficSplit struct {
+	Name        string
+	Sticky      string
+	Mirror      config.MirrorConfig
+	Backends    []config.WeightedBackendConfig
+	matcher     *RequestMatcher
+	totalWeight int
+}
+
+// NewTrafficSplits 根据配置创建分流规则列表
+func NewTrafficSplits(cfg config.TrafficSplitConfig) ([]*TrafficSplit, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	splits := make([]*TrafficSplit, 0, len(cfg.Splits))
+	for _, splitConfig := range cfg.Splits {
+		matcher, err := NewRequestMatcher(splitConfig.Match)
+		if err != nil {
+			return nil, fmt.Errorf("traffic split %s: %w", splitConfig.Name, err)
+		}
+		split := &TrafficSplit{
+			Name:     splitConfig.Name,
+			Sticky:   splitConfig.Sticky,
+			Mirror:   splitConfig.Mirror,
+			Backends: splitConfig.Backends,
+			matcher:  matcher,
+		}
+		for _, backend := range splitConfig.Backends {
+			split.totalWeight += backend.Weight
+		}
+		if split.totalWeight <= 0 {
+			return nil, fmt.Errorf("traffic split %s: total weight must be positive", splitConfig.Name)
+		}
+		splits = append(splits, split)
+	}
+	return splits, nil
+}
+
+// MatchTrafficSplit 返回第一条匹配请求的分流规则
+func MatchTrafficSplit(splits []*TrafficSplit, r *http.Request) *TrafficSplit {
+	for _, split := range splits {
+		if split.matcher.Match(r) {
+			return split
+		}
+	}
+	return nil
+}
+
+// Pick 选择后端；stickyKey 非空时按其哈希固定分配，否则随机分配
+func (s *TrafficSplit) Pick(stickyKey string) *config.WeightedBackendConfig {
+	var bucket int
+	if stickyKey != "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(s.Name + ":" + stickyKey))
+		bucket = int(h.Sum32() % uint32(s.totalWeight))
+	} else {
+		bucket = rand.IntN(s.totalWeight)
+	}
+
+	for i := range s.Backends {
+		if bucket < s.Backends[i].Weight {
+			return &s.Backends[i]
+		}
+		bucket -= s.Backends[i].Weight
+	}
+	return &s.Backends[len(s.Backends)-1]
+}
@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zgsm-ai/codebase-indexer/internal/config"
+	"github.com/zgsm-ai/codebase-indexer/internal/metrics"
+)
+
+// defaultPoolName 未单独登记的上游共用的连接池名称
+const defaultPoolName = "default"
+
+// TransportRegistry 按上游主机管理转发连接池，所有转发客户端共用
+// 登记了路由连接池配置的上游主机使用独立的连接池，其余上游（动态隧道、转发规则中的 URL 等）共用全局连接池
+type TransportRegistry struct {
+	global      config.TransportConfig
+	defaultPool *transportPool
+	mu          sync.RWMutex
+	pools       map[string]*transportPool // 按上游主机索引
+	client      *http.Client
+}
+
+// TransportStats 连接池统计
+type TransportStats struct {
+	Pool   string `json:"pool"`
+	Idle   int64  `json:"idle"`
+	Active int64  `json:"active"`
+	Dials  int64  `json:"dials"`
+	Errors int64  `json:"dial_errors"`
+}
+
+// transportPool 单个连接池
+type transportPool struct {
+	name      string
+	cfg       config.TransportConfig
+	transport http.RoundTripper
+	open      atomic.Int64 // 已建立的连接数
+	active    atomic.Int64 // 进行中的请求数
+	dials     atomic.Int64
+	dialErrs  atomic.Int64
+}
+
+// NewTransportRegistry 创建连接池注册表，global 应已通过 ProxyConfig.Validate 填充默认值
+func NewTransportRegistry(global config.TransportConfig) *TransportRegistry {
+	r := &TransportRegistry{
+		global: global,
+		pools:  make(map[string]*transportPool),
+	}
+	r.defaultPool = newTransportPool(defaultPoolName, global)
+	r.client = &http.Client{Transport: r}
+	return r
+}
+
+// Register 为上游地址登记路由连接池配置，route 为空时使用全局连接池
+// 同一主机只能登记一份配置，后登记的不同配置会被忽略
+func (r *TransportRegistry) Register(upstream string, route config.TransportConfig) {
+	if route.IsZero() {
+		return
+	}
+	u, err := url.Parse(upstream)
+	if err != nil || u.Host == "" {
+		logx.Errorf("Skip transport config for invalid upstream %s", upstream)
+		return
+	}
+	host := poolKey(u)
+	cfg := r.global.Merge(route)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if pool, ok := r.pools[host]; ok {
+		if pool.cfg != cfg {
+			logx.Errorf("Transport config for %s conflicts with an earlier route, keeping the first one", host)
+		}
+		return
+	}
+	r.pools[host] = newTransportPool(host, cfg)
+	logx.Infof("Registered transport pool for %s: max_idle_conns_per_host=%d, max_conns_per_host=%d", host, cfg.MaxIdleConnsPerHost, cfg.MaxConnsPerHost)
+}
+
+// Client 返回使用注册表转发的客户端，超时由请求上下文中的超时预算控制
+func (r *TransportRegistry) Client() *http.Client {
+	return r.client
+}
+
+// RoundTrip 实现 http.RoundTripper，按上游主机选择连接池
+func (r *TransportRegistry) RoundTrip(req *http.Request) (*http.Response, error) {
+	pool := r.pool(req.URL)
+	pool.active.Add(1)
+	pool.report()
+	resp, err := pool.transport.RoundTrip(req)
+	if err != nil {
+		pool.done()
+		return nil, err
+	}
+	resp.Body = &poolBody{ReadCloser: resp.Body, pool: pool}
+	return resp, nil
+}
+
+// CloseIdleConnections 关闭所有连接池的空闲连接
+func (r *TransportRegistry) CloseIdleConnections() {
+	for _, pool := range r.allPools() {
+		if closer, ok := pool.transport.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+}
+
+// Close 关闭所有连接池的空闲连接
+func (r *TransportRegistry) Close() error {
+	r.CloseIdleConnections()
+	return nil
+}
+
+// Stats 返回各连接池的统计，按名称排序
+func (r *TransportRegistry) Stats() []TransportStats {
+	pools := r.allPools()
+	stats := make([]TransportStats, 0, len(pools))
+	for _, pool := range pools {
+		stats = append(stats, pool.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Pool < stats[j].Pool })
+	return stats
+}
+
+func (r *TransportRegistry) pool(u *url.URL) *transportPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if pool, ok := r.pools[poolKey(u)]; ok {
+		return pool
+	}
+	return r.defaultPool
+}
+
+func (r *TransportRegistry) allPools() []*transportPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pools := []*transportPool{r.defaultPool}
+	for _, pool := range r.pools {
+		pools = append(pools, pool)
+	}
+	return pools
+}
+
+// newTransportPool 按配置创建连接池，连接数通过包装拨号统计
+func newTransportPool(name string, cfg config.TransportConfig) *transportPool {
+	pool := &transportPool{name: name, cfg: cfg}
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: cfg.TCPKeepAlive}
+	pool.transport = NewTimeoutTransport(&http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		DisableKeepAlives:   cfg.DisableKeepAlives,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			pool.dials.Add(1)
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				pool.dialErrs.Add(1)
+				metrics.UpstreamDials.Inc(pool.name, "error")
+				return nil, err
+			}
+			metrics.UpstreamDials.Inc(pool.name, "ok")
+			pool.open.Add(1)
+			pool.report()
+			return &poolConn{Conn: conn, pool: pool}, nil
+		},
+	})
+	return pool
+}
+
+// done 请求结束（响应体关闭或转发失败）
+func (p *transportPool) done() {
+	p.active.Add(-1)
+	p.report()
+}
+
+func (p *transportPool) stats() TransportStats {
+	active := p.active.Load()
+	idle := p.open.Load() - active
+	if idle < 0 {
+		idle = 0
+	}
+	return TransportStats{Pool: p.name, Idle: idle, Active: active, Dials: p.dials.Load(), Errors: p.dialErrs.Load()}
+}
+
+// report 更新连接池指标
+func (p *transportPool) report() {
+	stats := p.stats()
+	metrics.UpstreamConnections.Set(float64(stats.Idle), p.name, "idle")
+	metrics.UpstreamConnections.Set(float64(stats.Active), p.name, "active")
+}
+
+// poolConn 连接关闭时更新连接池的连接数
+type poolConn struct {
+	net.Conn
+	pool   *transportPool
+	closed atomic.Bool
+}
+
+// Close 关闭连接
+func (c *poolConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.pool.open.Add(-1)
+		c.pool.report()
+	}
+	return c.Conn.Close()
+}
+
+// poolBody 响应体关闭时结束连接池中的请求
+type poolBody struct {
+	io.ReadCloser
+	pool   *transportPool
+	closed atomic.Bool
+}
+
+// Close 关闭响应体
+func (b *poolBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.closed.CompareAndSwap(false, true) {
+		b.pool.done()
+	}
+	return err
+}
+
+// poolKey 返回连接池索引的上游主机，补全默认端口
+func poolKey(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	if u.Port() != "" {
+		return host
+	}
+	if u.Scheme == "https" {
+		return host + ":443"
+	}
+	return host + ":80"
+}
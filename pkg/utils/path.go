@@ -8,10 +8,9 @@ import (
 
 // ToUnixPath 将相对路径转换为 Unix 风格（使用 / 分隔符，去除冗余路径元素）
 func ToUnixPath(rawPath string) string {
-	// path.Clean 会自动处理为 Unix 风格路径，去除多余的 /、. 和 ..
-	filePath := path.Clean(rawPath)
-	filePath = filepath.ToSlash(filePath)
-	return filePath
+	// 先将 Windows 分隔符统一为 /，path.Clean 只识别 /，否则无法去除多余的 /、. 和 ..
+	filePath := strings.ReplaceAll(rawPath, "\\", "/")
+	return path.Clean(filePath)
 }
 
 // PathEqual 比较路径是否相等，/ \ 转为 /
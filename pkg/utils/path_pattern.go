@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// pathTemplateParam 匹配模板中的 {name} 占位符
+var pathTemplateParam = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+// CompilePathPattern 将带命名参数的路径模式编译为正则表达式
+// :name 匹配单个路径段，*name 匹配剩余路径（可为空，只能出现在末尾）
+// 例如 /codebase-indexer/api/v1/:resource/*rest
+func CompilePathPattern(pattern string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("path pattern must start with '/': %s", pattern)
+	}
+
+	segments := strings.Split(pattern[1:], "/")
+	names := make(map[string]struct{}, len(segments))
+	var b strings.Builder
+	b.WriteString("^")
+	for i, segment := range segments {
+		var name string
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name = segment[1:]
+			if name == "" {
+				return nil, fmt.Errorf("path pattern %s has an unnamed parameter", pattern)
+			}
+			if _, ok := names[name]; ok {
+				return nil, fmt.Errorf("path pattern %s has duplicate parameter %s", pattern, name)
+			}
+			names[name] = struct{}{}
+		}
+
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			if i != len(segments)-1 {
+				return nil, fmt.Errorf("wildcard parameter *%s must be the last segment of %s", name, pattern)
+			}
+			// 剩余路径可为空，此时连同前导 / 一起省略
+			fmt.Fprintf(&b, "(?:/(?P<%s>.*))?", name)
+		case strings.HasPrefix(segment, ":"):
+			fmt.Fprintf(&b, "/(?P<%s>[^/]+)", name)
+		default:
+			b.WriteString("/" + regexp.QuoteMeta(segment))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
+
+// PathTemplateParams 返回模板中引用的全部参数名
+func PathTemplateParams(tpl string) []string {
+	matches := pathTemplateParam.FindAllStringSubmatch(tpl, -1)
+	params := make([]string, 0, len(matches))
+	for _, m := range matches {
+		params = append(params, m[1])
+	}
+	return params
+}
+
+// ExpandPathTemplate 展开模板中的 {name} 占位符，lookup 未找到的参数替换为空字符串
+func ExpandPathTemplate(tpl string, lookup func(name string) string) string {
+	return pathTemplateParam.ReplaceAllStringFunc(tpl, func(m string) string {
+		return lookup(m[1 : len(m)-1])
+	})
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilePathPattern(t *testing.T) {
+	re, err := CompilePathPattern("/codebase-indexer/api/v1/:resource/*rest")
+	require.NoError(t, err)
+
+	tests := []struct {
+		path     string
+		match    bool
+		resource string
+		rest     string
+	}{
+		{"/codebase-indexer/api/v1/files/a/b.go", true, "files", "a/b.go"},
+		{"/codebase-indexer/api/v1/files", true, "files", ""},
+		{"/codebase-indexer/api/v1/", false, "", ""},
+		{"/codebase-indexer/api/v2/files/a", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		groups := re.FindStringSubmatch(tt.path)
+		if !tt.match {
+			assert.Nil(t, groups, tt.path)
+			continue
+		}
+		require.NotNil(t, groups, tt.path)
+		assert.Equal(t, tt.resource, groups[re.SubexpIndex("resource")], tt.path)
+		assert.Equal(t, tt.rest, groups[re.SubexpIndex("rest")], tt.path)
+	}
+}
+
+func TestCompilePathPatternInvalid(t *testing.T) {
+	for _, pattern := range []string{"no-slash", "/a/:", "/a/:x/:x", "/a/*rest/b"} {
+		_, err := CompilePathPattern(pattern)
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestExpandPathTemplate(t *testing.T) {
+	tpl := "/v2/{resource}/{query.repo}/{1}"
+	assert.Equal(t, []string{"resource", "query.repo", "1"}, PathTemplateParams(tpl))
+
+	values := map[string]string{"resource": "files", "query.repo": "r1", "1": "x"}
+	got := ExpandPathTemplate(tpl, func(name string) string { return values[name] })
+	assert.Equal(t, "/v2/files/r1/x", got)
+}
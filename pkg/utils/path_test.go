@@ -18,7 +18,9 @@ func TestToUnixPath(t *testing.T) {
 		{"with dot", "a/./b/c", "a/b/c"},
 		{"with parent", "a/b/../c", "a/c"},
 		{"mixed separators", "a\\b\\c", "a/b/c"}, // 自动转换为 /
-		{"root absolute", "/a/b/c", "/a/b/c"},    // 转为相对路径
+		{"windows parent", "a\\b\\..\\c", "a/c"},
+		{"windows drive", "C:\\work\\repo\\", "C:/work/repo"},
+		{"root absolute", "/a/b/c", "/a/b/c"}, // 转为相对路径
 		{"current dir", ".", "."},
 		{"parent dir", "..", ".."},
 		{"complex", "../../a/./b//c/..", "../../a/b"},
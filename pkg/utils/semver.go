@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Semver 语义化版本号，仅比较 major.minor.patch 与预发布标识
+type Semver struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseSemver 解析版本号，支持 v 前缀、缺省的 minor/patch 以及 -prerelease、+build 后缀
+func ParseSemver(s string) (Semver, error) {
+	var v Semver
+	raw := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if raw == "" {
+		return v, fmt.Errorf("invalid version: %q", s)
+	}
+	if i := strings.IndexByte(raw, '+'); i >= 0 {
+		raw = raw[:i]
+	}
+	if i := strings.IndexByte(raw, '-'); i >= 0 {
+		v.Prerelease = raw[i+1:]
+		raw = raw[:i]
+	}
+
+	parts := strings.Split(raw, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version: %q", s)
+	}
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version: %q", s)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// Compare 比较两个版本，返回 -1、0 或 1；带预发布标识的版本低于对应正式版本
+func (v Semver) Compare(o Semver) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	case v.Prerelease < o.Prerelease:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// String 返回版本号字符串
+func (v Semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// semverComparator 单个版本比较条件
+type semverComparator struct {
+	op      string
+	version Semver
+}
+
+func (c semverComparator) match(v Semver) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+// SemverRange 版本范围，如 ">=2.3.0 <3.0.0 || ^4.1.0"
+// 空格或逗号分隔的条件之间为“与”，|| 分隔的条件组之间为“或”
+type SemverRange struct {
+	sets [][]semverComparator
+}
+
+// ParseSemverRange 解析版本范围，支持 >、>=、<、<=、=、!=、^、~ 运算符
+func ParseSemverRange(s string) (*SemverRange, error) {
+	r := &SemverRange{}
+	for _, group := range strings.Split(s, "||") {
+		fields := strings.FieldsFunc(group, func(c rune) bool { return c == ' ' || c == ',' })
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid version range: %q", s)
+		}
+		var set []semverComparator
+		for _, field := range fields {
+			comparators, err := parseSemverComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version range %q: %w", s, err)
+			}
+			set = append(set, comparators...)
+		}
+		r.sets = append(r.sets, set)
+	}
+	return r, nil
+}
+
+// parseSemverComparator 解析单个条件，^ 与 ~ 会展开为上下界两个条件
+func parseSemverComparator(field string) ([]semverComparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(field, candidate) {
+			op = candidate
+			break
+		}
+	}
+	v, err := ParseSemver(strings.TrimPrefix(field, op))
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "^":
+		upper := Semver{Major: v.Major + 1}
+		if v.Major == 0 {
+			upper = Semver{Minor: v.Minor + 1}
+		}
+		return []semverComparator{{op: ">=", version: v}, {op: "<", version: upper}}, nil
+	case "~":
+		return []semverComparator{{op: ">=", version: v}, {op: "<", version: Semver{Major: v.Major, Minor: v.Minor + 1}}}, nil
+	case "":
+		op = "="
+	}
+	return []semverComparator{{op: op, version: v}}, nil
+}
+
+// Contains 判断版本是否在范围内
+func (r *SemverRange) Contains(v Semver) bool {
+	for _, set := range r.sets {
+		matched := true
+		for _, c := range set {
+			if !c.match(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
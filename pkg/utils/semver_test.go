@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Semver
+		wantErr bool
+	}{
+		{"2.3.0", Semver{Major: 2, Minor: 3}, false},
+		{"v1.2.3", Semver{Major: 1, Minor: 2, Patch: 3}, false},
+		{"1.2", Semver{Major: 1, Minor: 2}, false},
+		{"1.2.3-beta.1+build5", Semver{Major: 1, Minor: 2, Patch: 3, Prerelease: "beta.1"}, false},
+		{"", Semver{}, true},
+		{"1.x", Semver{}, true},
+		{"1.2.3.4", Semver{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSemver(tt.input)
+		if tt.wantErr {
+			assert.Error(t, err, tt.input)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, got, tt.input)
+	}
+}
+
+func TestSemverRangeContains(t *testing.T) {
+	tests := []struct {
+		rng     string
+		version string
+		want    bool
+	}{
+		{">=2.3.0", "2.3.0", true},
+		{">=2.3.0", "2.2.9", false},
+		{">=2.3.0", "2.3.0-rc.1", false},
+		{">=2.3.0 <3.0.0", "2.9.1", true},
+		{">=2.3.0, <3.0.0", "3.0.0", false},
+		{"<2.0.0 || >=3.1.0", "3.1.0", true},
+		{"<2.0.0 || >=3.1.0", "2.5.0", false},
+		{"^2.3.0", "2.9.0", true},
+		{"^2.3.0", "3.0.0", false},
+		{"^0.3.0", "0.4.0", false},
+		{"~2.3.0", "2.3.7", true},
+		{"~2.3.0", "2.4.0", false},
+		{"2.3.0", "2.3.0", true},
+		{"!=2.3.0", "2.3.0", false},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseSemverRange(tt.rng)
+		require.NoError(t, err, tt.rng)
+		v, err := ParseSemver(tt.version)
+		require.NoError(t, err, tt.version)
+		assert.Equal(t, tt.want, r.Contains(v), "%s contains %s", tt.rng, tt.version)
+	}
+}
+
+func TestParseSemverRangeInvalid(t *testing.T) {
+	for _, rng := range []string{"", ">=", ">=abc", "1.0.0 ||"} {
+		_, err := ParseSemverRange(rng)
+		assert.Error(t, err, rng)
+	}
+}
@@ -35,3 +35,12 @@ func DeDuplicate(slice []string) []string {
 	}
 	return result
 }
+
+// SliceToSet 切片转集合，用于按值快速查找
+func SliceToSet[T comparable](slice []T) map[T]bool {
+	set := make(map[T]bool, len(slice))
+	for _, v := range slice {
+		set[v] = true
+	}
+	return set
+}
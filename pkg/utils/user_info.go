@@ -68,3 +68,26 @@ func parseJWTPayload(jwtStr string) (map[string]interface{}, error) {
 	}
 	return payloadMap, nil
 }
+
+// ParseJWTClaims 解析 JWT 的 payload，不校验签名
+// 支持标准的 header.payload.signature 格式（可带 Bearer 前缀），以及网关注入的 base64 编码 JSON
+func ParseJWTClaims(token string) (map[string]interface{}, error) {
+	token = strings.TrimSpace(token)
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return parseJWTPayload(token)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode error: %w", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(decoded, &claims); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w", err)
+	}
+	return claims, nil
+}